@@ -0,0 +1,413 @@
+// Package api exposes order, inventory and customer operations of the
+// order package as a JSON HTTP API.
+//
+// All requests need a static bearer token and name the store profile
+// they act on in the path, e.g.
+//
+//	POST /profiles/dev/orders
+//	{"order": {...}, "options": {"VerifyProduct": true}}
+//
+// Options are decoded into the option structs of the order package and
+// default to the defaults of the corresponding CLI command. Write requests
+// are only allowed for whitelisted stores.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OfficiallyEQL/orderer/order"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// maxBodySize is the maximum accepted request body size.
+const maxBodySize = 10 << 20
+
+// Config holds the bearer tokens of API clients by client name and the
+// store profiles by profile name.
+type Config struct {
+	Tokens   map[string]string  `json:"tokens"`
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+// Profile is a Shopify store with its Admin API token.
+type Profile struct {
+	Store string `json:"store"`
+	Token string `json:"token"`
+}
+
+// Server is an http.Handler serving the API.
+type Server struct {
+	profiles  map[string]Profile // by profile name
+	newClient func(context.Context, Profile) order.Client
+	tokens    map[string]string // client name by token
+	whitelist map[string]bool
+	routes    []route
+	// Log receives a line per request if set.
+	Log io.Writer
+	// Timeout is the deadline of the operation of a request, see
+	// order.OperationContext.
+	Timeout time.Duration
+}
+
+// route maps a request method and path, relative to the profile, to a
+// handler. Write routes are rejected for stores not in the whitelist.
+type route struct {
+	method  string
+	path    string
+	write   bool
+	handler func(client order.Client, r *http.Request) (int, interface{}, error)
+}
+
+// requestError is an invalid request, reported with status 400.
+type requestError struct {
+	err error
+}
+
+type orderRequest[T any] struct {
+	Order   *goshopify.Order `json:"order"`
+	Options T                `json:"options"`
+}
+
+type deleteRequest struct {
+	Name    string              `json:"name"`
+	Options order.DeleteOptions `json:"options"`
+}
+
+type inventoryRequest struct {
+	InventoryItemID int64 `json:"inventory_item_id"`
+	VariantID       int64 `json:"variant_id"`
+	LocationID      int64 `json:"location_id"`
+	Amount          int   `json:"amount"`
+	Available       int   `json:"available"`
+}
+
+// ReadConfig reads a JSON config file. Environment variables in the file,
+// e.g. ${SHOPIFY_TOKEN}, are expanded so that tokens can be kept out of
+// it.
+func ReadConfig(filename string) (*Config, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := json.Unmarshal([]byte(os.ExpandEnv(string(b))), cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return cfg, nil
+}
+
+// NewServer returns a server for the profiles and tokens of cfg, creating
+// a client for the profile of each request with newClient. The requests of
+// the client must use the given context, see order.WithContext.
+func NewServer(cfg *Config, whitelist map[string]bool, newClient func(context.Context, Profile) order.Client) (*Server, error) {
+	if len(cfg.Tokens) == 0 {
+		return nil, fmt.Errorf("no API tokens configured")
+	}
+	s := &Server{
+		profiles:  map[string]Profile{},
+		newClient: newClient,
+		tokens:    map[string]string{},
+		whitelist: whitelist,
+	}
+	for name, token := range cfg.Tokens {
+		if token == "" {
+			return nil, fmt.Errorf("empty API token for %q", name)
+		}
+		s.tokens[token] = name
+	}
+	for name, p := range cfg.Profiles {
+		if p.Store == "" || p.Token == "" {
+			return nil, fmt.Errorf("profile %q: store and token are required", name)
+		}
+		s.profiles[name] = p
+	}
+	s.routes = []route{
+		{http.MethodPost, "orders", true, createOrder},
+		{http.MethodPost, "orders/merge", true, mergeOrder},
+		{http.MethodPost, "orders/replace", true, replaceOrder},
+		{http.MethodPost, "orders/delete", true, deleteOrders},
+		{http.MethodGet, "inventory_levels", false, getInventoryLevels},
+		{http.MethodPost, "inventory_levels/adjust", true, adjustInventoryLevel},
+		{http.MethodPost, "inventory_levels/set", true, setInventoryLevel},
+		{http.MethodGet, "customers", false, listCustomers},
+		{http.MethodPost, "customers/merge", true, mergeCustomer},
+	}
+	return s, nil
+}
+
+func (e requestError) Error() string {
+	return e.err.Error()
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	caller, ok := s.authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid bearer token"))
+		return
+	}
+	status := s.serve(w, r)
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, "%s %s %s %d\n", r.Method, r.URL.Path, caller, status)
+	}
+}
+
+// serve dispatches an authenticated request and returns the response
+// status.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) int {
+	profile, path, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/profiles/"), "/")
+	if !ok || !strings.HasPrefix(r.URL.Path, "/profiles/") {
+		return writeError(w, http.StatusNotFound, fmt.Errorf("path %q not found", r.URL.Path))
+	}
+	p, ok := s.profiles[profile]
+	if !ok {
+		return writeError(w, http.StatusNotFound, fmt.Errorf("profile %q not found", profile))
+	}
+	var matched *route
+	for i, rt := range s.routes {
+		if rt.path == strings.TrimSuffix(path, "/") {
+			matched = &s.routes[i]
+			if rt.method == r.Method {
+				break
+			}
+		}
+	}
+	if matched == nil {
+		return writeError(w, http.StatusNotFound, fmt.Errorf("path %q not found", r.URL.Path))
+	}
+	if matched.method != r.Method {
+		return writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+	if matched.write && !s.whitelist[p.Store] {
+		return writeError(w, http.StatusForbidden, fmt.Errorf("write request for non whitelisted shop %q", p.Store))
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	// The request is completed even if the API client disconnects.
+	ctx, cancel := order.OperationContext(order.WithOperationTimeout(r.Context(), s.Timeout))
+	defer cancel()
+	client := s.newClient(ctx, p)
+	status, v, err := matched.handler(client, r)
+	if err != nil {
+		return writeError(w, errorStatus(err), err)
+	}
+	writeJSON(w, status, v)
+	return status
+}
+
+// authenticate returns the name of the client with the request's bearer
+// token.
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(header, "Bearer ")
+	if token == header || token == "" {
+		return "", false
+	}
+	for t, name := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func createOrder(client order.Client, r *http.Request) (int, interface{}, error) {
+	req := orderRequest[order.CreateOptions]{}
+	if err := decodeOrderRequest(r, &req); err != nil {
+		return 0, nil, err
+	}
+	o, err := order.Create(client, req.Order, req.Options)
+	return http.StatusCreated, o, err
+}
+
+func mergeOrder(client order.Client, r *http.Request) (int, interface{}, error) {
+	req := orderRequest[order.MergeOptions]{}
+	if err := decodeOrderRequest(r, &req); err != nil {
+		return 0, nil, err
+	}
+	result, err := order.Merge(client, req.Order, req.Options)
+	return http.StatusOK, result, err
+}
+
+func replaceOrder(client order.Client, r *http.Request) (int, interface{}, error) {
+	req := orderRequest[order.CreateOptions]{}
+	if err := decodeOrderRequest(r, &req); err != nil {
+		return 0, nil, err
+	}
+	o, err := order.Replace(client, req.Order, req.Options)
+	return http.StatusOK, o, err
+}
+
+// deleteOrders deletes the orders with the given name. Unlike order.Delete
+// a name is required.
+func deleteOrders(client order.Client, r *http.Request) (int, interface{}, error) {
+	req := deleteRequest{Options: order.DeleteOptions{Max: -1}}
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+	if req.Name == "" {
+		return 0, nil, requestError{fmt.Errorf("order name is required")}
+	}
+	ids, err := order.Delete(client, req.Name, req.Options)
+	return http.StatusOK, map[string][]int64{"deleted": ids}, err
+}
+
+func getInventoryLevels(client order.Client, r *http.Request) (int, interface{}, error) {
+	req := inventoryRequest{}
+	var err error
+	q := r.URL.Query()
+	if req.InventoryItemID, err = queryID(q.Get("inventory_item_id")); err != nil {
+		return 0, nil, err
+	}
+	if req.VariantID, err = queryID(q.Get("variant_id")); err != nil {
+		return 0, nil, err
+	}
+	if err := req.validate(); err != nil {
+		return 0, nil, err
+	}
+	levels, err := order.GetIventoryLevels(client, req.InventoryItemID, req.VariantID)
+	return http.StatusOK, levels, err
+}
+
+func adjustInventoryLevel(client order.Client, r *http.Request) (int, interface{}, error) {
+	req := inventoryRequest{}
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+	if err := req.validate(); err != nil {
+		return 0, nil, err
+	}
+	level, err := order.AdjustIventoryLevel(client, req.LocationID, req.InventoryItemID, req.VariantID, req.Amount)
+	return http.StatusOK, level, err
+}
+
+func setInventoryLevel(client order.Client, r *http.Request) (int, interface{}, error) {
+	req := inventoryRequest{}
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+	if err := req.validate(); err != nil {
+		return 0, nil, err
+	}
+	if req.LocationID == 0 {
+		return 0, nil, requestError{fmt.Errorf("location_id is required")}
+	}
+	itemID, err := order.InventoryItemID(client, req.InventoryItemID, req.VariantID)
+	if err != nil {
+		return 0, nil, err
+	}
+	level, err := order.SetInventoryLevel(client, req.LocationID, itemID, req.Available)
+	return http.StatusOK, level, err
+}
+
+func listCustomers(client order.Client, r *http.Request) (int, interface{}, error) {
+	email, phone := r.URL.Query().Get("email"), r.URL.Query().Get("phone")
+	var customers []goshopify.Customer
+	var err error
+	switch {
+	case email != "" && phone == "", phone != "" && email == "":
+		customers, err = order.FindCustomers(client, email, phone)
+	default:
+		return 0, nil, requestError{fmt.Errorf("either email or phone is required")}
+	}
+	if customers == nil {
+		customers = []goshopify.Customer{}
+	}
+	return http.StatusOK, customers, err
+}
+
+func mergeCustomer(client order.Client, r *http.Request) (int, interface{}, error) {
+	req := struct {
+		Customer *goshopify.Customer `json:"customer"`
+	}{}
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+	if req.Customer == nil {
+		return 0, nil, requestError{fmt.Errorf("customer is required")}
+	}
+	customer, err := order.CustomerMerge(client, req.Customer)
+	return http.StatusOK, customer, err
+}
+
+func (req inventoryRequest) validate() error {
+	if (req.InventoryItemID == 0) == (req.VariantID == 0) {
+		return requestError{fmt.Errorf("either inventory_item_id or variant_id is required")}
+	}
+	return nil
+}
+
+func decodeOrderRequest[T any](r *http.Request, req *orderRequest[T]) error {
+	if err := decode(r, req); err != nil {
+		return err
+	}
+	if req.Order == nil {
+		return requestError{fmt.Errorf("order is required")}
+	}
+	return nil
+}
+
+func decode(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return requestError{fmt.Errorf("invalid request body: %w", err)}
+	}
+	return nil
+}
+
+func queryID(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, requestError{fmt.Errorf("invalid ID %q", s)}
+	}
+	return id, nil
+}
+
+// errorStatus returns 400 for invalid requests, the status of Shopify
+// errors caused by the request, i.e. 400, 404 for missing resources and
+// 422, 502 for other Shopify errors, e.g. 401, 403 or 429 of the
+// profile's credentials or rate limit, and 422 otherwise, e.g. for orders
+// failing validation.
+func errorStatus(err error) int {
+	var reqErr requestError
+	if errors.As(err, &reqErr) {
+		return http.StatusBadRequest
+	}
+	var rateErr goshopify.RateLimitError
+	if errors.As(err, &rateErr) {
+		return http.StatusBadGateway
+	}
+	var rerr goshopify.ResponseError
+	if errors.As(err, &rerr) {
+		switch rerr.Status {
+		case http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity:
+			return rerr.Status
+		}
+		return http.StatusBadGateway
+	}
+	return http.StatusUnprocessableEntity
+}
+
+func writeError(w http.ResponseWriter, status int, err error) int {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+	return status
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	"github.com/OfficiallyEQL/orderer/order"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, srv *fake.Server) *Server {
+	t.Helper()
+	cfg := &Config{
+		Tokens: map[string]string{"ci": "secret"},
+		Profiles: map[string]Profile{
+			"dev":  {Store: "eql-dev", Token: "t1"},
+			"prod": {Store: "eql", Token: "t2"},
+		},
+	}
+	s, err := NewServer(cfg, map[string]bool{"eql-dev": true}, func(context.Context, Profile) order.Client { return order.NewShopifyClient(srv.Client()) })
+	require.NoError(t, err)
+	return s
+}
+
+func do(t *testing.T, s *Server, method, path, body string, v interface{}) int {
+	t.Helper()
+	r := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if v != nil {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), v), w.Body.String())
+	}
+	return w.Code
+}
+
+func TestServerAuth(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	s := newTestServer(t, srv)
+	log := &bytes.Buffer{}
+	s.Log = log
+
+	r := httptest.NewRequest(http.MethodGet, "/profiles/dev/customers?email=a@example.com", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	r.Header.Set("Authorization", "Bearer wrong")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Empty(t, log.String())
+
+	resp := map[string]string{}
+	require.Equal(t, http.StatusNotFound, do(t, s, http.MethodGet, "/profiles/staging/customers?email=a@example.com", "", &resp))
+	require.Equal(t, `profile "staging" not found`, resp["error"])
+	require.Equal(t, http.StatusNotFound, do(t, s, http.MethodGet, "/profiles/dev/products", "", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, do(t, s, http.MethodGet, "/profiles/dev/orders", "", nil))
+	require.Equal(t, http.StatusForbidden, do(t, s, http.MethodPost, "/profiles/prod/orders", `{"order": {"name": "order1"}}`, &resp))
+	require.Equal(t, `write request for non whitelisted shop "eql"`, resp["error"])
+	require.Equal(t, http.StatusOK, do(t, s, http.MethodGet, "/profiles/prod/customers?email=a@example.com", "", nil))
+	require.Contains(t, log.String(), "POST /profiles/prod/orders ci 403\n")
+}
+
+func TestServerOrders(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	s := newTestServer(t, srv)
+
+	created := goshopify.Order{}
+	require.Equal(t, http.StatusCreated, do(t, s, http.MethodPost, "/profiles/dev/orders", `{"order": {"name": "order1", "email": "jay@example.com"}}`, &created))
+	require.Equal(t, "order1", created.Name)
+	require.Len(t, srv.Orders(), 1)
+
+	resp := map[string]string{}
+	require.Equal(t, http.StatusBadRequest, do(t, s, http.MethodPost, "/profiles/dev/orders", `{"order": {"name": "order2"}, "options": {"Unknown": true}}`, &resp))
+	require.Contains(t, resp["error"], "invalid request body")
+	require.Equal(t, http.StatusBadRequest, do(t, s, http.MethodPost, "/profiles/dev/orders", `{}`, &resp))
+	require.Equal(t, "order is required", resp["error"])
+	require.Equal(t, http.StatusUnprocessableEntity, do(t, s, http.MethodPost, "/profiles/dev/orders", `{"order": {"name": "order1"}, "options": {"Unique": true}}`, &resp))
+
+	replaced := goshopify.Order{}
+	require.Equal(t, http.StatusOK, do(t, s, http.MethodPost, "/profiles/dev/orders/replace", `{"order": {"name": "order1", "email": "mary@example.com"}}`, &replaced))
+	require.NotEqual(t, created.ID, replaced.ID)
+	require.Len(t, srv.Orders(), 1)
+
+	require.Equal(t, http.StatusBadRequest, do(t, s, http.MethodPost, "/profiles/dev/orders/delete", `{}`, &resp))
+	require.Equal(t, "order name is required", resp["error"])
+	deleted := map[string][]int64{}
+	require.Equal(t, http.StatusOK, do(t, s, http.MethodPost, "/profiles/dev/orders/delete", `{"name": "order1"}`, &deleted))
+	require.Equal(t, []int64{replaced.ID}, deleted["deleted"])
+	require.Empty(t, srv.Orders())
+}
+
+func TestServerInventoryAndCustomers(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	srv.AddProducts(goshopify.Product{ID: 1, Title: "Tee", Variants: []goshopify.Variant{{ID: 2, ProductID: 1, InventoryItemId: 3, Sku: "TEE-M"}}})
+	srv.AddCustomers(goshopify.Customer{ID: 1, Email: "mary@example.com"}, goshopify.Customer{ID: 2, Email: "mary@example.com.au"})
+	s := newTestServer(t, srv)
+
+	level := order.InventoryLevel{}
+	require.Equal(t, http.StatusOK, do(t, s, http.MethodPost, "/profiles/dev/inventory_levels/set", `{"variant_id": 2, "location_id": 1, "available": 5}`, &level))
+	require.Equal(t, order.InventoryLevel{InventoryItemID: 3, LocationID: 1, Available: 5}, level)
+	require.Equal(t, http.StatusOK, do(t, s, http.MethodPost, "/profiles/dev/inventory_levels/adjust", `{"inventory_item_id": 3, "location_id": 1, "amount": -2}`, &level))
+	require.Equal(t, 3, level.Available)
+	levels := []order.InventoryLevel{}
+	require.Equal(t, http.StatusOK, do(t, s, http.MethodGet, "/profiles/dev/inventory_levels?variant_id=2", "", &levels))
+	require.Equal(t, []order.InventoryLevel{{InventoryItemID: 3, LocationID: 1, Available: 3}}, levels)
+	require.Equal(t, http.StatusBadRequest, do(t, s, http.MethodGet, "/profiles/dev/inventory_levels", "", nil))
+
+	customers := []goshopify.Customer{}
+	require.Equal(t, http.StatusOK, do(t, s, http.MethodGet, "/profiles/dev/customers?email=mary@example.com", "", &customers))
+	require.Len(t, customers, 1)
+	require.Equal(t, int64(1), customers[0].ID)
+	customer := goshopify.Customer{}
+	require.Equal(t, http.StatusOK, do(t, s, http.MethodPost, "/profiles/dev/customers/merge", `{"customer": {"email": "mary@example.com", "first_name": "Mary"}}`, &customer))
+	require.Equal(t, int64(1), customer.ID)
+	require.Equal(t, "Mary", srv.Customers()[0].FirstName)
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := map[int]error{
+		http.StatusBadRequest:          requestError{fmt.Errorf("order is required")},
+		http.StatusNotFound:            fmt.Errorf("cannot get order: %w", goshopify.ResponseError{Status: http.StatusNotFound}),
+		http.StatusUnprocessableEntity: goshopify.ResponseError{Status: http.StatusUnprocessableEntity},
+		http.StatusBadGateway:          goshopify.RateLimitError{ResponseError: goshopify.ResponseError{Status: http.StatusTooManyRequests}},
+	}
+	for want, err := range tests {
+		require.Equal(t, want, errorStatus(err), err.Error())
+	}
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusInternalServerError} {
+		require.Equal(t, http.StatusBadGateway, errorStatus(goshopify.ResponseError{Status: status}), status)
+	}
+	require.Equal(t, http.StatusUnprocessableEntity, errorStatus(fmt.Errorf("invalid transactions")))
+}
+
+func TestReadConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "api.json")
+	b := []byte(`{"tokens": {"ci": "${ORDERER_TEST_API_TOKEN}"}, "profiles": {"dev": {"store": "eql-dev", "token": "t1"}}}`)
+	require.NoError(t, os.WriteFile(filename, b, 0o600))
+	t.Setenv("ORDERER_TEST_API_TOKEN", "secret")
+	cfg, err := ReadConfig(filename)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"ci": "secret"}, cfg.Tokens)
+	require.Equal(t, Profile{Store: "eql-dev", Token: "t1"}, cfg.Profiles["dev"])
+
+	_, err = NewServer(&Config{Tokens: map[string]string{"ci": ""}}, nil, nil)
+	require.ErrorContains(t, err, `empty API token for "ci"`)
+}
@@ -0,0 +1,224 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request, _ []int64) {
+	req := struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": err.Error()})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case strings.Contains(req.Query, "bulkOperationRunQuery"):
+		s.runBulk(w, req.Variables)
+	case strings.Contains(req.Query, "on BulkOperation"):
+		s.bulkNode(w, req.Variables)
+	case strings.Contains(req.Query, "customerRequestDataErasure"):
+		s.requestErasure(w, req.Variables)
+	case strings.Contains(req.Query, "inventoryBulkAdjustQuantityAtLocation"):
+		s.bulkAdjustInventory(w, req.Variables)
+	case strings.Contains(req.Query, "productVariants"):
+		s.productVariants(w, req.Variables)
+	case strings.Contains(req.Query, "inventoryLevels"):
+		s.locationInventoryLevels(w, req.Variables)
+	case strings.Contains(req.Query, "locations("):
+		s.graphQLLocations(w, req.Variables)
+	case strings.Contains(req.Query, "location(id"):
+		s.graphQLLocation(w, req.Variables)
+	default:
+		writeGraphQLError(w, "unsupported query")
+	}
+}
+
+func (s *Server) runBulk(w http.ResponseWriter, vars map[string]interface{}) {
+	query, _ := vars["query"].(string)
+	if s.bulk != nil && s.bulk.status == "RUNNING" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+			"bulkOperationRunQuery": map[string]interface{}{
+				"bulkOperation": nil,
+				"userErrors": []map[string]interface{}{
+					{"field": nil, "message": "A bulk query operation for this app and shop is already in progress"},
+				},
+			},
+		}})
+		return
+	}
+	s.bulk = &bulkOperation{id: s.nextID, query: query, status: "RUNNING"}
+	s.nextID++
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+		"bulkOperationRunQuery": map[string]interface{}{
+			"bulkOperation": s.bulkJSON(),
+			"userErrors":    []interface{}{},
+		},
+	}})
+}
+
+// bulkNode answers node(id:) queries for the most recent bulk operation.
+func (s *Server) bulkNode(w http.ResponseWriter, vars map[string]interface{}) {
+	var op interface{}
+	if s.bulk != nil && gidID(vars["id"]) == s.bulk.id {
+		s.pollBulk()
+		op = s.bulkJSON()
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"node": op}})
+}
+
+// pollBulk completes the running bulk operation after Polls status
+// queries.
+func (s *Server) pollBulk() {
+	if s.bulk.status == "RUNNING" {
+		s.bulk.polls++
+		if s.bulk.polls > s.Polls {
+			s.bulk.status = "COMPLETED"
+		}
+	}
+}
+
+func (s *Server) bulkJSON() map[string]interface{} {
+	op := map[string]interface{}{
+		"id":          fmt.Sprintf("gid://shopify/BulkOperation/%d", s.bulk.id),
+		"status":      s.bulk.status,
+		"errorCode":   nil,
+		"objectCount": "0",
+		"url":         nil,
+	}
+	if s.bulk.status == "COMPLETED" {
+		rows := s.bulkRows()
+		op["objectCount"] = fmt.Sprint(len(rows))
+		if len(rows) != 0 {
+			op["url"] = fmt.Sprintf("%s/bulk/%d.jsonl", s.URL, s.bulk.id)
+		}
+	}
+	return op
+}
+
+func (s *Server) handleBulkResult(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bulk == nil || r.URL.Path != fmt.Sprintf("/bulk/%d.jsonl", s.bulk.id) {
+		http.NotFound(w, r)
+		return
+	}
+	enc := json.NewEncoder(w)
+	for _, row := range s.bulkRows() {
+		_ = enc.Encode(row)
+	}
+}
+
+// bulkRows returns the rows of the current bulk operation's result.
+func (s *Server) bulkRows() []map[string]interface{} {
+	if strings.Contains(s.bulk.query, "customers {") {
+		return s.bulkCustomerRows()
+	}
+	return s.bulkOrderRows()
+}
+
+// bulkCustomerRows returns customer IDs with the metafield selected by
+// the query, i.e. customers { ... metafield(namespace: "ns", key: "k") }.
+func (s *Server) bulkCustomerRows() []map[string]interface{} {
+	var namespace, key string
+	if m := metafieldSelection.FindStringSubmatch(s.bulk.query); m != nil {
+		namespace, key = m[1], m[2]
+	}
+	var rows []map[string]interface{}
+	for _, c := range s.customers {
+		row := map[string]interface{}{"id": fmt.Sprintf("gid://shopify/Customer/%d", c.ID), "metafield": nil}
+		for _, mf := range s.metafields[fmt.Sprintf("customers/%d", c.ID)] {
+			if mf.Namespace == namespace && mf.Key == key {
+				row["metafield"] = map[string]interface{}{"value": mf.Value}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// bulkOrderRows returns orders and line items as flattened JSONL rows in
+// the format of Shopify's bulk operation results, where line items
+// reference their order by __parentId.
+func (s *Server) bulkOrderRows() []map[string]interface{} {
+	var rows []map[string]interface{}
+	for _, o := range s.orders {
+		if !matchesBulkQuery(s.bulk.query, o) {
+			continue
+		}
+		orderGID := fmt.Sprintf("gid://shopify/Order/%d", o.ID)
+		row := map[string]interface{}{
+			"id":                       orderGID,
+			"name":                     o.Name,
+			"email":                    o.Email,
+			"phone":                    o.Phone,
+			"note":                     o.Note,
+			"createdAt":                o.CreatedAt,
+			"processedAt":              o.ProcessedAt,
+			"currencyCode":             o.Currency,
+			"displayFinancialStatus":   strings.ToUpper(o.FinancialStatus),
+			"displayFulfillmentStatus": strings.ToUpper(o.FulfillmentStatus),
+		}
+		if o.Tags != "" {
+			row["tags"] = strings.Split(o.Tags, ", ")
+		}
+		if o.TotalPrice != nil {
+			row["totalPriceSet"] = map[string]interface{}{"shopMoney": map[string]interface{}{"amount": o.TotalPrice.String()}}
+		}
+		rows = append(rows, row)
+		for _, li := range o.LineItems {
+			row := map[string]interface{}{
+				"id":         fmt.Sprintf("gid://shopify/LineItem/%d", li.ID),
+				"title":      li.Title,
+				"sku":        li.SKU,
+				"quantity":   li.Quantity,
+				"__parentId": orderGID,
+			}
+			if li.VariantID != 0 {
+				row["variant"] = map[string]interface{}{"id": fmt.Sprintf("gid://shopify/ProductVariant/%d", li.VariantID)}
+			}
+			if li.Price != nil {
+				row["originalUnitPriceSet"] = map[string]interface{}{"shopMoney": map[string]interface{}{"amount": li.Price.String()}}
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// matchesBulkQuery supports the name filter used by orderer, i.e.
+// orders(query: "name:\"#1001\"").
+func matchesBulkQuery(query string, o goshopify.Order) bool {
+	const prefix = "(query: "
+	idx := strings.Index(query, prefix)
+	if idx == -1 {
+		return true
+	}
+	var filter string
+	if err := json.NewDecoder(strings.NewReader(query[idx+len(prefix):])).Decode(&filter); err != nil {
+		return false
+	}
+	name := strings.TrimPrefix(filter, "name:")
+	if unquoted, err := strconv.Unquote(name); err == nil {
+		name = unquoted
+	}
+	return o.Name == name
+}
+
+var metafieldSelection = regexp.MustCompile(`metafield\(namespace: "([^"]*)", key: "([^"]*)"\)`)
+
+func writeGraphQLError(w http.ResponseWriter, msg string) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"errors": []map[string]string{{"message": msg}},
+	})
+}
@@ -0,0 +1,257 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+func (s *Server) customerRoutes() []route {
+	return []route{
+		{http.MethodGet, "customers", s.handleListCustomers},
+		{http.MethodGet, "customers/search", s.handleSearchCustomers},
+		{http.MethodPost, "customers", s.handleCreateCustomer},
+		{http.MethodGet, "customers/*", s.handleGetCustomer},
+		{http.MethodPut, "customers/*", s.handleUpdateCustomer},
+		{http.MethodDelete, "customers/*", s.handleDeleteCustomer},
+		{http.MethodGet, "customers/*/addresses", s.handleListCustomerAddresses},
+		{http.MethodPost, "customers/*/addresses", s.handleCreateCustomerAddress},
+		{http.MethodGet, "customers/*/orders", s.handleListCustomerOrders},
+	}
+}
+
+// AddCustomers adds customers to the store, keeping their IDs.
+func (s *Server) AddCustomers(customers ...goshopify.Customer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.customers = append(s.customers, customers...)
+}
+
+// Customers returns a copy of all customers held by the server.
+func (s *Server) Customers() []goshopify.Customer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]goshopify.Customer(nil), s.customers...)
+}
+
+// customer returns the customer with the given ID or nil.
+func (s *Server) customer(id int64) *goshopify.Customer {
+	for i := range s.customers {
+		if s.customers[i].ID == id {
+			return &s.customers[i]
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleListCustomers(w http.ResponseWriter, r *http.Request, _ []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	customers := append([]goshopify.Customer{}, s.customers...)
+	writeJSON(w, http.StatusOK, goshopify.CustomersResource{Customers: customers})
+}
+
+// handleSearchCustomers supports the field queries used by orderer, e.g.
+// query=email:"jo@example.com". Like Shopify's search, email queries also
+// match similar emails such as "jo@example.com.au".
+func (s *Server) handleSearchCustomers(w http.ResponseWriter, r *http.Request, _ []int64) {
+	field, value, _ := strings.Cut(r.URL.Query().Get("query"), ":")
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	customers := []goshopify.Customer{}
+	for _, c := range s.customers {
+		switch {
+		case field == "email" && strings.Contains(strings.ToLower(c.Email), strings.ToLower(value)),
+			field == "phone" && c.Phone == value:
+			customers = append(customers, c)
+		}
+	}
+	writeJSON(w, http.StatusOK, goshopify.CustomersResource{Customers: customers})
+}
+
+func (s *Server) handleCreateCustomer(w http.ResponseWriter, r *http.Request, _ []int64) {
+	resource := goshopify.CustomerResource{}
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil || resource.Customer == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": "invalid customer"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := *resource.Customer
+	for _, existing := range s.customers {
+		if c.Email != "" && strings.EqualFold(existing.Email, c.Email) {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string][]string{"email": {"has already been taken"}})
+			return
+		}
+	}
+	c.ID = s.nextID
+	s.nextID++
+	addresses := c.Addresses
+	c.Addresses = nil
+	for _, a := range addresses {
+		s.addCustomerAddress(&c, *a)
+	}
+	owner := fmt.Sprintf("customers/%d", c.ID)
+	for _, mf := range c.Metafields {
+		mf.ID = s.nextID
+		s.nextID++
+		s.metafields[owner] = append(s.metafields[owner], mf)
+	}
+	c.Metafields = nil
+	s.customers = append(s.customers, c)
+	writeJSON(w, http.StatusCreated, goshopify.CustomerResource{Customer: &c})
+}
+
+func (s *Server) handleGetCustomer(w http.ResponseWriter, r *http.Request, ids []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.customer(ids[0])
+	if c == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, goshopify.CustomerResource{Customer: c})
+}
+
+// handleUpdateCustomer updates the top-level customer attributes that are
+// set in the request.
+func (s *Server) handleUpdateCustomer(w http.ResponseWriter, r *http.Request, ids []int64) {
+	resource := goshopify.CustomerResource{}
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil || resource.Customer == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": "invalid customer"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.customer(ids[0])
+	if c == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	update := resource.Customer
+	for _, f := range []struct{ dst, src *string }{
+		{&c.FirstName, &update.FirstName},
+		{&c.LastName, &update.LastName},
+		{&c.Email, &update.Email},
+		{&c.Phone, &update.Phone},
+		{&c.Note, &update.Note},
+		{&c.Tags, &update.Tags},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	writeJSON(w, http.StatusOK, goshopify.CustomerResource{Customer: c})
+}
+
+// ErasureRequested reports whether data erasure has been requested for
+// the customer with the given ID.
+func (s *Server) ErasureRequested(id int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.erasures[id]
+}
+
+// customerOrders returns the orders of the customer with the given ID.
+func (s *Server) customerOrders(id int64) []goshopify.Order {
+	orders := []goshopify.Order{}
+	for _, o := range s.orders {
+		if o.Customer != nil && o.Customer.ID == id {
+			orders = append(orders, o)
+		}
+	}
+	return orders
+}
+
+func (s *Server) handleListCustomerOrders(w http.ResponseWriter, r *http.Request, ids []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, goshopify.OrdersResource{Orders: s.customerOrders(ids[0])})
+}
+
+// handleDeleteCustomer rejects the deletion of customers with orders like
+// Shopify does.
+func (s *Server) handleDeleteCustomer(w http.ResponseWriter, r *http.Request, ids []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.customerOrders(ids[0])) != 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string][]string{"base": {"Error deleting customer"}})
+		return
+	}
+	for i := range s.customers {
+		if s.customers[i].ID == ids[0] {
+			s.customers = append(s.customers[:i], s.customers[i+1:]...)
+			delete(s.metafields, fmt.Sprintf("customers/%d", ids[0]))
+			writeJSON(w, http.StatusOK, map[string]string{})
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+}
+
+func (s *Server) handleListCustomerAddresses(w http.ResponseWriter, r *http.Request, ids []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.customer(ids[0])
+	if c == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	addresses := []goshopify.CustomerAddress{}
+	for _, a := range c.Addresses {
+		addresses = append(addresses, *a)
+	}
+	writeJSON(w, http.StatusOK, goshopify.CustomerAddressesResource{Addresses: addresses})
+}
+
+func (s *Server) handleCreateCustomerAddress(w http.ResponseWriter, r *http.Request, ids []int64) {
+	resource := goshopify.CustomerAddressResource{}
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil || resource.Address == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": "invalid address"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.customer(ids[0])
+	if c == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	a := s.addCustomerAddress(c, *resource.Address)
+	writeJSON(w, http.StatusCreated, goshopify.CustomerAddressResource{Address: a})
+}
+
+func (s *Server) requestErasure(w http.ResponseWriter, vars map[string]interface{}) {
+	gid, _ := vars["customerId"].(string)
+	id, err := strconv.ParseInt(strings.TrimPrefix(gid, "gid://shopify/Customer/"), 10, 64)
+	userErrors := []map[string]interface{}{}
+	if err != nil || s.customer(id) == nil {
+		userErrors = append(userErrors, map[string]interface{}{"field": []string{"customerId"}, "message": "Customer does not exist"})
+	} else {
+		s.erasures[id] = true
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+		"customerRequestDataErasure": map[string]interface{}{"customerId": gid, "userErrors": userErrors},
+	}})
+}
+
+// addCustomerAddress adds a to the customer's addresses. The first address
+// becomes the default address.
+func (s *Server) addCustomerAddress(c *goshopify.Customer, a goshopify.CustomerAddress) *goshopify.CustomerAddress {
+	a.ID = s.nextID
+	s.nextID++
+	a.CustomerID = c.ID
+	a.Default = c.DefaultAddress == nil
+	c.Addresses = append(c.Addresses, &a)
+	if a.Default {
+		c.DefaultAddress = &a
+	}
+	return &a
+}
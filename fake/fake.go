@@ -0,0 +1,362 @@
+// Package fake provides a local stand-in for the parts of the Shopify
+// Admin API used by orderer so that it can be exercised offline.
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// Server is a fake Shopify store serving orders from memory.
+type Server struct {
+	*httptest.Server
+	// Polls is the number of status polls for which a bulk operation
+	// remains running before it completes.
+	Polls int
+	// LocationID is the location all fulfillment orders are assigned to.
+	LocationID int64
+
+	mu        sync.Mutex
+	orders    []goshopify.Order
+	customers []goshopify.Customer
+	erasures  map[int64]bool // customer data erasure requests
+	bulk      *bulkOperation
+	nextID    int64
+	fulfilled map[int64]int // fulfilled quantity by line item ID
+	// metafields by owner, e.g. "orders/1"
+	metafields map[string][]goshopify.Metafield
+	products   []goshopify.Product
+	locations  []goshopify.Location
+	inventory  map[inventoryKey]int
+	webhooks   []goshopify.Webhook
+}
+
+type bulkOperation struct {
+	id     int64
+	query  string
+	status string
+	polls  int
+}
+
+func NewServer(orders ...goshopify.Order) *Server {
+	s := &Server{orders: orders, nextID: 1000, LocationID: 1, fulfilled: map[int64]int{}, erasures: map[int64]bool{}, metafields: map[string][]goshopify.Metafield{}, inventory: map[inventoryKey]int{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/api/", s.handleAPI)
+	mux.HandleFunc("/bulk/", s.handleBulkResult)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Client returns a goshopify client sending all requests to the fake
+// server.
+func (s *Server) Client() *goshopify.Client {
+	return Client(s.URL)
+}
+
+// Client returns a goshopify client sending all requests to the server at
+// serverURL regardless of the shop name.
+func Client(serverURL string) *goshopify.Client {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		panic(err)
+	}
+	client := goshopify.NewClient(goshopify.App{}, "fake", "token", goshopify.WithVersion("2022-10"))
+	client.Client.Transport = rewriteTransport{url: u}
+	return client
+}
+
+type rewriteTransport struct {
+	url *url.URL
+}
+
+func (rt rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = rt.url.Scheme
+	req.URL.Host = rt.url.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// route maps a request method and API path, relative to
+// /admin/api/<version>/ and without .json suffix, to a handler. A "*"
+// path segment matches a numeric ID, which is passed to the handler.
+type route struct {
+	method  string
+	pattern string
+	handler func(w http.ResponseWriter, r *http.Request, ids []int64)
+}
+
+func (s *Server) routes() []route {
+	routes := []route{
+		{http.MethodPost, "graphql", s.handleGraphQL},
+		{http.MethodGet, "orders", s.handleListOrders},
+		{http.MethodPost, "orders", s.handleCreateOrder},
+		{http.MethodGet, "orders/*", s.handleGetOrder},
+		{http.MethodGet, "orders/*/fulfillment_orders", s.handleListFulfillmentOrders},
+		{http.MethodGet, "orders/*/transactions", s.handleListTransactions},
+		{http.MethodPost, "orders/*/transactions", s.handleCreateTransaction},
+		{http.MethodPost, "orders/*/refunds/calculate", s.handleCalculateRefund},
+		{http.MethodPost, "orders/*/refunds", s.handleCreateRefund},
+		{http.MethodPost, "orders/*/cancel", s.handleCancelOrder},
+		{http.MethodPost, "orders/*/close", s.handleCloseOrder},
+		{http.MethodPost, "orders/*/open", s.handleOpenOrder},
+		{http.MethodPost, "fulfillments", s.handleCreateFulfillment},
+		{http.MethodPut, "orders/*", s.handleUpdateOrder},
+		{http.MethodDelete, "orders/*", s.handleDeleteOrder},
+	}
+	routes = append(routes, s.customerRoutes()...)
+	routes = append(routes, s.productRoutes()...)
+	routes = append(routes, s.webhookRoutes()...)
+	return append(routes, s.metafieldRoutes()...)
+}
+
+func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/admin/api/")
+	if idx := strings.Index(path, "/"); idx != -1 {
+		path = path[idx+1:] // strip version
+	}
+	segments := strings.Split(strings.TrimSuffix(path, ".json"), "/")
+	for _, rt := range s.routes() {
+		if rt.method != r.Method {
+			continue
+		}
+		if ids, ok := match(rt.pattern, segments); ok {
+			rt.handler(w, r, ids)
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+}
+
+func match(pattern string, segments []string) ([]int64, bool) {
+	patternSegments := strings.Split(pattern, "/")
+	if len(patternSegments) != len(segments) {
+		return nil, false
+	}
+	var ids []int64
+	for i, p := range patternSegments {
+		if p != "*" {
+			if p != segments[i] {
+				return nil, false
+			}
+			continue
+		}
+		id, err := strconv.ParseInt(segments[i], 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+// order returns the order with the given ID or nil.
+func (s *Server) order(id int64) *goshopify.Order {
+	for i := range s.orders {
+		if s.orders[i].ID == id {
+			return &s.orders[i]
+		}
+	}
+	return nil
+}
+
+// Orders returns a copy of all orders held by the server.
+func (s *Server) Orders() []goshopify.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]goshopify.Order(nil), s.orders...)
+}
+
+func (s *Server) handleCreateOrder(w http.ResponseWriter, r *http.Request, _ []int64) {
+	resource := goshopify.OrderResource{}
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil || resource.Order == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": "invalid order"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := *resource.Order
+	o.ID = s.nextID
+	s.nextID++
+	now := time.Now()
+	o.CreatedAt = &now
+	for i := range o.LineItems {
+		o.LineItems[i].ID = s.nextID
+		s.nextID++
+	}
+	for i := range o.Transactions {
+		o.Transactions[i].ID = s.nextID
+		o.Transactions[i].OrderID = o.ID
+		s.nextID++
+	}
+	owner := fmt.Sprintf("orders/%d", o.ID)
+	for _, mf := range o.Metafields {
+		mf.ID = s.nextID
+		s.nextID++
+		s.metafields[owner] = append(s.metafields[owner], mf)
+	}
+	o.Metafields = nil
+	s.orders = append(s.orders, o)
+	writeJSON(w, http.StatusCreated, goshopify.OrderResource{Order: &o})
+}
+
+// handleUpdateOrder updates top-level order attributes supported by the
+// Shopify order update endpoint.
+func (s *Server) handleUpdateOrder(w http.ResponseWriter, r *http.Request, ids []int64) {
+	resource := goshopify.OrderResource{}
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil || resource.Order == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": "invalid order"})
+		return
+	}
+	update := resource.Order
+	s.updateOrder(w, ids[0], func(o *goshopify.Order) {
+		if update.Email != "" {
+			o.Email = update.Email
+		}
+		if update.Phone != "" {
+			o.Phone = update.Phone
+		}
+		if update.Note != "" {
+			o.Note = update.Note
+		}
+		if update.Tags != "" {
+			o.Tags = update.Tags
+		}
+		if update.Customer != nil {
+			o.Customer = update.Customer
+		}
+		if update.ShippingAddress != nil {
+			o.ShippingAddress = update.ShippingAddress
+		}
+	})
+}
+
+func (s *Server) handleDeleteOrder(w http.ResponseWriter, r *http.Request, ids []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.orders {
+		if s.orders[i].ID == ids[0] {
+			s.orders = append(s.orders[:i], s.orders[i+1:]...)
+			writeJSON(w, http.StatusOK, map[string]string{})
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+}
+
+func (s *Server) updateOrder(w http.ResponseWriter, id int64, update func(o *goshopify.Order)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.order(id)
+	if o == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	update(o)
+	writeJSON(w, http.StatusOK, goshopify.OrderResource{Order: o})
+}
+
+func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request, ids []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.order(ids[0])
+	if o == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, goshopify.OrderResource{Order: o})
+}
+
+// handleListOrders filters orders by name and customer_id and pages
+// through them with limit.
+func (s *Server) handleListOrders(w http.ResponseWriter, r *http.Request, _ []int64) {
+	query, offset, ok := pageQuery(w, r)
+	if !ok {
+		return
+	}
+	name := query.Get("name")
+	customerID, _ := strconv.ParseInt(query.Get("customer_id"), 10, 64)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	orders := []goshopify.Order{}
+	for _, o := range s.orders {
+		if name != "" && o.Name != name {
+			continue
+		}
+		if customerID != 0 && (o.Customer == nil || o.Customer.ID != customerID) {
+			continue
+		}
+		orders = append(orders, o)
+	}
+	start, end := s.page(w, r, query, offset, len(orders))
+	writeJSON(w, http.StatusOK, goshopify.OrdersResource{Orders: orders[start:end]})
+}
+
+// pageQuery returns the filter query of a list request and the offset of
+// the requested page. Like Shopify, the filters of the first request are
+// carried in the page_info of the following pages.
+func pageQuery(w http.ResponseWriter, r *http.Request) (url.Values, int, bool) {
+	query := r.URL.Query()
+	pageInfo := query.Get("page_info")
+	if pageInfo == "" {
+		return query, 0, true
+	}
+	query, err := url.ParseQuery(pageInfo)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": "invalid page_info"})
+		return nil, 0, false
+	}
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	return query, offset, true
+}
+
+// page returns the bounds of the requested page of n items and sets the
+// Link header if there is a next page.
+func (s *Server) page(w http.ResponseWriter, r *http.Request, query url.Values, offset, n int) (int, int) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if offset > n {
+		offset = n
+	}
+	if limit <= 0 || offset+limit >= n {
+		return offset, n
+	}
+	query.Set("offset", strconv.Itoa(offset+limit))
+	next := url.Values{"page_info": {query.Encode()}, "limit": {strconv.Itoa(limit)}}
+	w.Header().Set("Link", fmt.Sprintf(`<%s%s?%s>; rel="next"`, s.URL, r.URL.Path, next.Encode()))
+	return offset, offset + limit
+}
+
+// matchQuery reports whether all query parameters given in fields match
+// the corresponding field value.
+func matchQuery(query url.Values, fields map[string]string) bool {
+	for param, value := range fields {
+		if v := query.Get(param); v != "" && v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// splitIDs parses comma-separated IDs, ignoring invalid ones.
+func splitIDs(s string) []int64 {
+	var ids []int64
+	for _, v := range strings.Split(s, ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
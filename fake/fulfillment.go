@@ -0,0 +1,131 @@
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+type fulfillmentOrder struct {
+	ID                 int64                      `json:"id"`
+	OrderID            int64                      `json:"order_id"`
+	AssignedLocationID int64                      `json:"assigned_location_id"`
+	Status             string                     `json:"status"`
+	LineItems          []fulfillmentOrderLineItem `json:"line_items"`
+}
+
+type fulfillmentOrderLineItem struct {
+	ID                  int64 `json:"id"`
+	FulfillmentOrderID  int64 `json:"fulfillment_order_id"`
+	LineItemID          int64 `json:"line_item_id"`
+	VariantID           int64 `json:"variant_id"`
+	Quantity            int   `json:"quantity"`
+	FulfillableQuantity int   `json:"fulfillable_quantity"`
+}
+
+// fulfillmentOrders returns a single fulfillment order per order, assigned
+// to LocationID, with a fulfillment order line item per line item sharing
+// the line item's ID.
+func (s *Server) fulfillmentOrders(o *goshopify.Order) []fulfillmentOrder {
+	fo := fulfillmentOrder{ID: o.ID, OrderID: o.ID, AssignedLocationID: s.LocationID, Status: "closed"}
+	for _, li := range o.LineItems {
+		fulfillable := li.Quantity - s.fulfilled[li.ID]
+		if fulfillable > 0 {
+			fo.Status = "open"
+		}
+		fo.LineItems = append(fo.LineItems, fulfillmentOrderLineItem{
+			ID:                  li.ID,
+			FulfillmentOrderID:  fo.ID,
+			LineItemID:          li.ID,
+			VariantID:           li.VariantID,
+			Quantity:            li.Quantity,
+			FulfillableQuantity: fulfillable,
+		})
+	}
+	return []fulfillmentOrder{fo}
+}
+
+func (s *Server) handleListFulfillmentOrders(w http.ResponseWriter, r *http.Request, ids []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.order(ids[0])
+	if o == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"fulfillment_orders": s.fulfillmentOrders(o)})
+}
+
+func (s *Server) handleCreateFulfillment(w http.ResponseWriter, r *http.Request, _ []int64) {
+	req := struct {
+		Fulfillment struct {
+			LineItemsByFulfillmentOrder []struct {
+				FulfillmentOrderID        int64 `json:"fulfillment_order_id"`
+				FulfillmentOrderLineItems []struct {
+					ID       int64 `json:"id"`
+					Quantity int   `json:"quantity"`
+				} `json:"fulfillment_order_line_items"`
+			} `json:"line_items_by_fulfillment_order"`
+			TrackingInfo struct {
+				Company string `json:"company"`
+				Number  string `json:"number"`
+				URL     string `json:"url"`
+			} `json:"tracking_info"`
+			NotifyCustomer bool `json:"notify_customer"`
+		} `json:"fulfillment"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": err.Error()})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	f := goshopify.Fulfillment{
+		ID:              s.nextID,
+		LocationID:      s.LocationID,
+		Status:          "success",
+		CreatedAt:       &now,
+		TrackingCompany: req.Fulfillment.TrackingInfo.Company,
+		TrackingNumber:  req.Fulfillment.TrackingInfo.Number,
+		TrackingUrl:     req.Fulfillment.TrackingInfo.URL,
+		NotifyCustomer:  req.Fulfillment.NotifyCustomer,
+	}
+	s.nextID++
+	for _, byFO := range req.Fulfillment.LineItemsByFulfillmentOrder {
+		o := s.order(byFO.FulfillmentOrderID)
+		if o == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+			return
+		}
+		f.OrderID = o.ID
+		for _, item := range byFO.FulfillmentOrderLineItems {
+			li := lineItem(o, item.ID)
+			if li == nil || item.Quantity > li.Quantity-s.fulfilled[li.ID] {
+				writeJSON(w, http.StatusUnprocessableEntity, map[string][]string{"errors": {"Invalid fulfillment order line item quantity requested."}})
+				return
+			}
+			s.fulfilled[li.ID] += item.Quantity
+			fli := *li
+			fli.Quantity = item.Quantity
+			f.LineItems = append(f.LineItems, fli)
+		}
+		o.FulfillmentStatus = "partial"
+		if s.fulfillmentOrders(o)[0].Status == "closed" {
+			o.FulfillmentStatus = "fulfilled"
+		}
+		o.Fulfillments = append(o.Fulfillments, f)
+	}
+	writeJSON(w, http.StatusCreated, goshopify.FulfillmentResource{Fulfillment: &f})
+}
+
+func lineItem(o *goshopify.Order, id int64) *goshopify.LineItem {
+	for i := range o.LineItems {
+		if o.LineItems[i].ID == id {
+			return &o.LineItems[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// metafieldRoutes returns the routes for metafields of orders, customers
+// and variants, e.g. orders/1/metafields.json.
+func (s *Server) metafieldRoutes() []route {
+	var routes []route
+	for _, resource := range []string{"orders", "customers", "variants"} {
+		resource := resource
+		owner := func(ids []int64) string { return fmt.Sprintf("%s/%d", resource, ids[0]) }
+		routes = append(routes,
+			route{http.MethodGet, resource + "/*/metafields", func(w http.ResponseWriter, r *http.Request, ids []int64) {
+				s.handleListMetafields(w, r, owner(ids))
+			}},
+			route{http.MethodPost, resource + "/*/metafields", func(w http.ResponseWriter, r *http.Request, ids []int64) {
+				s.handleSaveMetafield(w, r, owner(ids), 0)
+			}},
+			route{http.MethodPut, resource + "/*/metafields/*", func(w http.ResponseWriter, r *http.Request, ids []int64) {
+				s.handleSaveMetafield(w, r, owner(ids), ids[1])
+			}},
+			route{http.MethodDelete, resource + "/*/metafields/*", func(w http.ResponseWriter, r *http.Request, ids []int64) {
+				s.handleDeleteMetafield(w, owner(ids), ids[1])
+			}},
+		)
+	}
+	return routes
+}
+
+// Metafields returns the metafields of the given resource, e.g.
+// Metafields("orders", 1).
+func (s *Server) Metafields(resource string, id int64) []goshopify.Metafield {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]goshopify.Metafield(nil), s.metafields[fmt.Sprintf("%s/%d", resource, id)]...)
+}
+
+func (s *Server) handleListMetafields(w http.ResponseWriter, r *http.Request, owner string) {
+	query, offset, ok := pageQuery(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	metafields := s.metafields[owner]
+	if metafields == nil {
+		metafields = []goshopify.Metafield{}
+	}
+	start, end := s.page(w, r, query, offset, len(metafields))
+	writeJSON(w, http.StatusOK, goshopify.MetafieldsResource{Metafields: metafields[start:end]})
+}
+
+func (s *Server) handleSaveMetafield(w http.ResponseWriter, r *http.Request, owner string, id int64) {
+	resource := goshopify.MetafieldResource{}
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil || resource.Metafield == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": "invalid metafield"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mf := *resource.Metafield
+	if id != 0 {
+		for i := range s.metafields[owner] {
+			if s.metafields[owner][i].ID == id {
+				if mf.Type != "" && mf.Type != s.metafields[owner][i].Type {
+					writeJSON(w, http.StatusUnprocessableEntity, map[string][]string{"errors": {"type can't be changed"}})
+					return
+				}
+				s.metafields[owner][i].Value = mf.Value
+				writeJSON(w, http.StatusOK, goshopify.MetafieldResource{Metafield: &s.metafields[owner][i]})
+				return
+			}
+		}
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	for _, existing := range s.metafields[owner] {
+		if existing.Namespace == mf.Namespace && existing.Key == mf.Key {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string][]string{"errors": {"key must be unique within this namespace on this resource"}})
+			return
+		}
+	}
+	mf.ID = s.nextID
+	s.nextID++
+	s.metafields[owner] = append(s.metafields[owner], mf)
+	writeJSON(w, http.StatusCreated, goshopify.MetafieldResource{Metafield: &mf})
+}
+
+func (s *Server) handleDeleteMetafield(w http.ResponseWriter, owner string, id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	metafields := s.metafields[owner]
+	for i := range metafields {
+		if metafields[i].ID == id {
+			s.metafields[owner] = append(metafields[:i], metafields[i+1:]...)
+			writeJSON(w, http.StatusOK, map[string]string{})
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+}
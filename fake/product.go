@@ -0,0 +1,628 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// inventoryKey identifies the inventory level of an item at a location.
+type inventoryKey struct {
+	inventoryItemID int64
+	locationID      int64
+}
+
+type inventoryLevel struct {
+	InventoryItemID int64 `json:"inventory_item_id"`
+	LocationID      int64 `json:"location_id"`
+	Available       int   `json:"available"`
+}
+
+func (s *Server) productRoutes() []route {
+	return []route{
+		{http.MethodGet, "products", s.handleListProducts},
+		{http.MethodPost, "products", s.handleCreateProduct},
+		{http.MethodGet, "products/*", s.handleGetProduct},
+		{http.MethodPut, "products/*", s.handleUpdateProduct},
+		{http.MethodDelete, "products/*", s.handleDeleteProduct},
+		{http.MethodGet, "products/*/variants", s.handleListVariants},
+		{http.MethodPost, "products/*/variants", s.handleCreateVariant},
+		{http.MethodDelete, "products/*/variants/*", s.handleDeleteVariant},
+		{http.MethodGet, "variants/*", s.handleGetVariant},
+		{http.MethodPut, "variants/*", s.handleUpdateVariant},
+		{http.MethodGet, "locations", s.handleListLocations},
+		{http.MethodGet, "inventory_levels", s.handleListInventoryLevels},
+		{http.MethodPost, "inventory_levels/set", s.handleSetInventoryLevel},
+		{http.MethodPost, "inventory_levels/adjust", s.handleAdjustInventoryLevel},
+		{http.MethodPost, "inventory_levels/connect", s.handleConnectInventoryLevel},
+		{http.MethodDelete, "inventory_levels", s.handleDeleteInventoryLevel},
+	}
+}
+
+// AddProducts adds products to the store, keeping their IDs and the IDs
+// and inventory item IDs of their variants.
+func (s *Server) AddProducts(products ...goshopify.Product) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.products = append(s.products, products...)
+}
+
+// Products returns a copy of all products held by the server.
+func (s *Server) Products() []goshopify.Product {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]goshopify.Product(nil), s.products...)
+}
+
+// Inventory returns the available quantity of an inventory item at a
+// location.
+func (s *Server) Inventory(inventoryItemID, locationID int64) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inventory[inventoryKey{inventoryItemID, locationID}]
+}
+
+// product returns the product with the given ID or nil.
+func (s *Server) product(id int64) *goshopify.Product {
+	for i := range s.products {
+		if s.products[i].ID == id {
+			return &s.products[i]
+		}
+	}
+	return nil
+}
+
+// variant returns the variant with the given ID and its product or nil.
+func (s *Server) variant(id int64) (*goshopify.Variant, *goshopify.Product) {
+	for i := range s.products {
+		p := &s.products[i]
+		for j := range p.Variants {
+			if p.Variants[j].ID == id {
+				return &p.Variants[j], p
+			}
+		}
+	}
+	return nil, nil
+}
+
+// newVariant assigns IDs to v as the variant of product p.
+func (s *Server) newVariant(p *goshopify.Product, v *goshopify.Variant) {
+	v.ID = s.nextID
+	v.InventoryItemId = s.nextID + 1
+	s.nextID += 2
+	v.ProductID = p.ID
+	v.InventoryQuantity = 0
+	if v.Title == "" {
+		v.Title = "Default Title"
+	}
+}
+
+func (s *Server) handleListProducts(w http.ResponseWriter, r *http.Request, _ []int64) {
+	query, offset, ok := pageQuery(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	products := []goshopify.Product{}
+	for _, p := range s.products {
+		if matchQuery(query, map[string]string{"handle": p.Handle, "vendor": p.Vendor, "product_type": p.ProductType}) {
+			products = append(products, p)
+		}
+	}
+	start, end := s.page(w, r, query, offset, len(products))
+	writeJSON(w, http.StatusOK, goshopify.ProductsResource{Products: products[start:end]})
+}
+
+// handleCreateProduct creates a product with its variants. Like Shopify,
+// a product without variants gets a default variant.
+func (s *Server) handleCreateProduct(w http.ResponseWriter, r *http.Request, _ []int64) {
+	resource := goshopify.ProductResource{}
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil || resource.Product == nil || resource.Product.Title == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string][]string{"title": {"can't be blank"}})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := *resource.Product
+	p.ID = s.nextID
+	s.nextID++
+	if p.Handle == "" {
+		p.Handle = "product-" + strconv.FormatInt(p.ID, 10)
+	}
+	if len(p.Variants) == 0 {
+		p.Variants = []goshopify.Variant{{}}
+	}
+	p.Variants = append([]goshopify.Variant(nil), p.Variants...)
+	for i := range p.Variants {
+		s.newVariant(&p, &p.Variants[i])
+	}
+	s.products = append(s.products, p)
+	writeJSON(w, http.StatusCreated, goshopify.ProductResource{Product: &p})
+}
+
+func (s *Server) handleGetProduct(w http.ResponseWriter, r *http.Request, ids []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.product(ids[0])
+	if p == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, goshopify.ProductResource{Product: p})
+}
+
+// handleUpdateProduct updates the top-level product attributes that are
+// set in the request.
+func (s *Server) handleUpdateProduct(w http.ResponseWriter, r *http.Request, ids []int64) {
+	resource := goshopify.ProductResource{}
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil || resource.Product == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": "invalid product"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.product(ids[0])
+	if p == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	update := resource.Product
+	for _, f := range []struct{ dst, src *string }{
+		{&p.Title, &update.Title},
+		{&p.BodyHTML, &update.BodyHTML},
+		{&p.Vendor, &update.Vendor},
+		{&p.ProductType, &update.ProductType},
+		{&p.Handle, &update.Handle},
+		{&p.Tags, &update.Tags},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	writeJSON(w, http.StatusOK, goshopify.ProductResource{Product: p})
+}
+
+func (s *Server) handleDeleteProduct(w http.ResponseWriter, r *http.Request, ids []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.products {
+		if s.products[i].ID == ids[0] {
+			s.products = append(s.products[:i], s.products[i+1:]...)
+			writeJSON(w, http.StatusOK, map[string]string{})
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+}
+
+func (s *Server) handleListVariants(w http.ResponseWriter, r *http.Request, ids []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.product(ids[0])
+	if p == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, goshopify.VariantsResource{Variants: append([]goshopify.Variant{}, p.Variants...)})
+}
+
+func (s *Server) handleCreateVariant(w http.ResponseWriter, r *http.Request, ids []int64) {
+	resource := goshopify.VariantResource{}
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil || resource.Variant == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": "invalid variant"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.product(ids[0])
+	if p == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	v := *resource.Variant
+	s.newVariant(p, &v)
+	p.Variants = append(p.Variants, v)
+	writeJSON(w, http.StatusCreated, goshopify.VariantResource{Variant: &v})
+}
+
+func (s *Server) handleGetVariant(w http.ResponseWriter, r *http.Request, ids []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, _ := s.variant(ids[0])
+	if v == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, goshopify.VariantResource{Variant: v})
+}
+
+// handleUpdateVariant updates the variant attributes that are set in the
+// request.
+func (s *Server) handleUpdateVariant(w http.ResponseWriter, r *http.Request, ids []int64) {
+	resource := goshopify.VariantResource{}
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil || resource.Variant == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": "invalid variant"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, _ := s.variant(ids[0])
+	if v == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	update := resource.Variant
+	for _, f := range []struct{ dst, src *string }{
+		{&v.Title, &update.Title},
+		{&v.Sku, &update.Sku},
+		{&v.Barcode, &update.Barcode},
+		{&v.Option1, &update.Option1},
+		{&v.Option2, &update.Option2},
+		{&v.Option3, &update.Option3},
+		{&v.InventoryPolicy, &update.InventoryPolicy},
+		{&v.InventoryManagement, &update.InventoryManagement},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	if update.Price != nil {
+		v.Price = update.Price
+	}
+	if update.CompareAtPrice != nil {
+		v.CompareAtPrice = update.CompareAtPrice
+	}
+	writeJSON(w, http.StatusOK, goshopify.VariantResource{Variant: v})
+}
+
+// handleDeleteVariant deletes a variant, refusing to delete the last
+// variant of a product like Shopify does.
+func (s *Server) handleDeleteVariant(w http.ResponseWriter, r *http.Request, ids []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.product(ids[0])
+	if p == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	for i := range p.Variants {
+		if p.Variants[i].ID == ids[1] {
+			if len(p.Variants) == 1 {
+				writeJSON(w, http.StatusUnprocessableEntity, map[string][]string{"base": {"Cannot delete the last variant of a product"}})
+				return
+			}
+			p.Variants = append(p.Variants[:i], p.Variants[i+1:]...)
+			writeJSON(w, http.StatusOK, map[string]string{})
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+}
+
+// AddLocations adds locations to the store. Without locations the store
+// has a single active location with ID LocationID.
+func (s *Server) AddLocations(locations ...goshopify.Location) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locations = append(s.locations, locations...)
+}
+
+func (s *Server) handleListLocations(w http.ResponseWriter, r *http.Request, _ []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, goshopify.LocationsResource{Locations: s.allLocations()})
+}
+
+// allLocations returns the added locations or the default location.
+func (s *Server) allLocations() []goshopify.Location {
+	if len(s.locations) == 0 {
+		return []goshopify.Location{{ID: s.LocationID, Name: "Shop location", Active: true}}
+	}
+	return s.locations
+}
+
+// graphQLLocations answers locations queries with all locations on a
+// single page.
+func (s *Server) graphQLLocations(w http.ResponseWriter, _ map[string]interface{}) {
+	edges := []map[string]interface{}{}
+	for _, l := range s.allLocations() {
+		edges = append(edges, map[string]interface{}{"cursor": strconv.FormatInt(l.ID, 10), "node": locationJSON(l)})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+		"locations": map[string]interface{}{"edges": edges, "pageInfo": map[string]interface{}{"hasNextPage": false}},
+	}})
+}
+
+func (s *Server) graphQLLocation(w http.ResponseWriter, vars map[string]interface{}) {
+	id := gidID(vars["id"])
+	var location interface{}
+	for _, l := range s.allLocations() {
+		if l.ID == id {
+			location = locationJSON(l)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"location": location}})
+}
+
+// locationJSON returns the GraphQL representation of l. Legacy locations
+// belong to a fulfillment service and do not fulfill online orders.
+func locationJSON(l goshopify.Location) map[string]interface{} {
+	var service interface{}
+	if l.Legacy {
+		service = map[string]interface{}{"serviceName": "Fulfillment service"}
+	}
+	return map[string]interface{}{
+		"id":                   fmt.Sprintf("gid://shopify/Location/%d", l.ID),
+		"name":                 l.Name,
+		"isActive":             l.Active,
+		"fulfillsOnlineOrders": l.Active && !l.Legacy,
+		"hasActiveInventory":   l.Active,
+		"fulfillmentService":   service,
+		"address": map[string]interface{}{
+			"address1": l.Address1,
+			"address2": l.Address2,
+			"city":     l.City,
+			"province": l.Province,
+			"zip":      l.Zip,
+			"country":  l.Country,
+			"phone":    l.Phone,
+		},
+	}
+}
+
+// handleListInventoryLevels lists the inventory levels of the
+// comma-separated inventory_item_ids and location_ids.
+func (s *Server) handleListInventoryLevels(w http.ResponseWriter, r *http.Request, _ []int64) {
+	itemIDs := map[int64]bool{}
+	for _, id := range splitIDs(r.URL.Query().Get("inventory_item_ids")) {
+		itemIDs[id] = true
+	}
+	locationIDs := map[int64]bool{}
+	for _, id := range splitIDs(r.URL.Query().Get("location_ids")) {
+		locationIDs[id] = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	levels := []inventoryLevel{}
+	for key, available := range s.inventory {
+		if (len(itemIDs) == 0 || itemIDs[key.inventoryItemID]) && (len(locationIDs) == 0 || locationIDs[key.locationID]) {
+			levels = append(levels, inventoryLevel{InventoryItemID: key.inventoryItemID, LocationID: key.locationID, Available: available})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string][]inventoryLevel{"inventory_levels": levels})
+}
+
+func (s *Server) handleSetInventoryLevel(w http.ResponseWriter, r *http.Request, _ []int64) {
+	level := inventoryLevel{}
+	if err := json.NewDecoder(r.Body).Decode(&level); err != nil || level.InventoryItemID == 0 || level.LocationID == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": "invalid inventory level"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inventory[inventoryKey{level.InventoryItemID, level.LocationID}] = level.Available
+	writeJSON(w, http.StatusOK, map[string]inventoryLevel{"inventory_level": level})
+}
+
+// handleAdjustInventoryLevel adjusts the available quantity of an item
+// that is stocked at the location.
+func (s *Server) handleAdjustInventoryLevel(w http.ResponseWriter, r *http.Request, _ []int64) {
+	adjustment := struct {
+		InventoryItemID     int64 `json:"inventory_item_id"`
+		LocationID          int64 `json:"location_id"`
+		AvailableAdjustment int   `json:"available_adjustment"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&adjustment); err != nil || adjustment.InventoryItemID == 0 || adjustment.LocationID == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": "invalid inventory level adjustment"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := inventoryKey{adjustment.InventoryItemID, adjustment.LocationID}
+	available, ok := s.inventory[key]
+	if !ok {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string][]string{"errors": {"Inventory item is not stocked at the location"}})
+		return
+	}
+	s.inventory[key] = available + adjustment.AvailableAdjustment
+	writeJSON(w, http.StatusOK, map[string]inventoryLevel{"inventory_level": {InventoryItemID: key.inventoryItemID, LocationID: key.locationID, Available: s.inventory[key]}})
+}
+
+// handleConnectInventoryLevel stocks an item at a location with an
+// available quantity of 0, keeping an existing inventory level.
+func (s *Server) handleConnectInventoryLevel(w http.ResponseWriter, r *http.Request, _ []int64) {
+	level := inventoryLevel{}
+	if err := json.NewDecoder(r.Body).Decode(&level); err != nil || level.InventoryItemID == 0 || level.LocationID == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": "invalid inventory level"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := inventoryKey{level.InventoryItemID, level.LocationID}
+	level.Available = s.inventory[key]
+	s.inventory[key] = level.Available
+	writeJSON(w, http.StatusCreated, map[string]inventoryLevel{"inventory_level": level})
+}
+
+// handleDeleteInventoryLevel disconnects an item from a location. As in
+// Shopify, an item must stay stocked at one location at least.
+func (s *Server) handleDeleteInventoryLevel(w http.ResponseWriter, r *http.Request, _ []int64) {
+	itemID, _ := strconv.ParseInt(r.URL.Query().Get("inventory_item_id"), 10, 64)
+	locationID, _ := strconv.ParseInt(r.URL.Query().Get("location_id"), 10, 64)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := inventoryKey{itemID, locationID}
+	if _, ok := s.inventory[key]; !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	locations := 0
+	for k := range s.inventory {
+		if k.inventoryItemID == itemID {
+			locations++
+		}
+	}
+	if locations == 1 {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string][]string{"base": {"The inventory item must be stocked at one location at least"}})
+		return
+	}
+	delete(s.inventory, key)
+	writeJSON(w, http.StatusOK, map[string]string{})
+}
+
+// locationInventoryLevels answers location(id) { inventoryLevels } queries
+// with the levels ordered by inventory item ID. Cursors are offsets.
+func (s *Server) locationInventoryLevels(w http.ResponseWriter, vars map[string]interface{}) {
+	locationID := gidID(vars["id"])
+	if locationID == 0 {
+		writeGraphQLError(w, fmt.Sprintf("invalid location id %v", vars["id"]))
+		return
+	}
+	first, _ := vars["first"].(float64)
+	after, _ := vars["after"].(string)
+	offset, _ := strconv.Atoi(after)
+	variants := map[int64]goshopify.Variant{}
+	for _, p := range s.products {
+		for _, v := range p.Variants {
+			v.Title = p.Title + " - " + v.Title
+			variants[v.InventoryItemId] = v
+		}
+	}
+	var keys []inventoryKey
+	for key := range s.inventory {
+		if key.locationID == locationID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].inventoryItemID < keys[j].inventoryItemID })
+	end := len(keys)
+	if offset > end {
+		offset = end
+	}
+	if first > 0 && offset+int(first) < end {
+		end = offset + int(first)
+	}
+	edges := []map[string]interface{}{}
+	for i, key := range keys[offset:end] {
+		item := map[string]interface{}{"id": fmt.Sprintf("gid://shopify/InventoryItem/%d", key.inventoryItemID), "sku": "", "variant": nil}
+		if v, ok := variants[key.inventoryItemID]; ok {
+			item["sku"] = v.Sku
+			item["variant"] = map[string]interface{}{"id": fmt.Sprintf("gid://shopify/ProductVariant/%d", v.ID), "displayName": v.Title}
+		}
+		edges = append(edges, map[string]interface{}{
+			"cursor": strconv.Itoa(offset + i + 1),
+			"node":   map[string]interface{}{"available": s.inventory[key], "item": item},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+		"location": map[string]interface{}{
+			"inventoryLevels": map[string]interface{}{
+				"edges":    edges,
+				"pageInfo": map[string]interface{}{"hasNextPage": end < len(keys)},
+			},
+		},
+	}})
+}
+
+// productVariants answers productVariants(query) queries for filters of
+// the form sku:X, optionally combined with OR. Cursors are offsets.
+func (s *Server) productVariants(w http.ResponseWriter, vars map[string]interface{}) {
+	filter, _ := vars["filter"].(string)
+	first, _ := vars["first"].(float64)
+	after, _ := vars["after"].(string)
+	offset, _ := strconv.Atoi(after)
+	skus := map[string]bool{}
+	for _, f := range strings.Split(filter, " OR ") {
+		sku := strings.TrimPrefix(strings.TrimSpace(f), "sku:")
+		if unquoted, err := strconv.Unquote(sku); err == nil {
+			sku = unquoted
+		}
+		skus[sku] = true
+	}
+	edges := []map[string]interface{}{}
+	for _, p := range s.products {
+		for _, v := range p.Variants {
+			if v.Sku == "" || !skus[v.Sku] {
+				continue
+			}
+			edges = append(edges, map[string]interface{}{
+				"cursor": strconv.Itoa(len(edges) + 1),
+				"node": map[string]interface{}{
+					"id":            fmt.Sprintf("gid://shopify/ProductVariant/%d", v.ID),
+					"title":         v.Title,
+					"sku":           v.Sku,
+					"inventoryItem": map[string]interface{}{"id": fmt.Sprintf("gid://shopify/InventoryItem/%d", v.InventoryItemId)},
+				},
+			})
+		}
+	}
+	end := len(edges)
+	if offset > end {
+		offset = end
+	}
+	if first > 0 && offset+int(first) < end {
+		end = offset + int(first)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+		"productVariants": map[string]interface{}{
+			"edges":    edges[offset:end],
+			"pageInfo": map[string]interface{}{"hasNextPage": end < len(edges)},
+		},
+	}})
+}
+
+// bulkAdjustInventory applies inventoryBulkAdjustQuantityAtLocation. All
+// items must be stocked at the location, otherwise nothing is adjusted.
+func (s *Server) bulkAdjustInventory(w http.ResponseWriter, vars map[string]interface{}) {
+	locationID := gidID(vars["locationId"])
+	adjustments, _ := vars["inventoryItemAdjustments"].([]interface{})
+	deltas := map[inventoryKey]int{}
+	var keys []inventoryKey
+	for _, a := range adjustments {
+		adjustment, _ := a.(map[string]interface{})
+		key := inventoryKey{gidID(adjustment["inventoryItemId"]), locationID}
+		delta, _ := adjustment["availableDelta"].(float64)
+		if _, ok := s.inventory[key]; !ok {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+				"inventoryBulkAdjustQuantityAtLocation": map[string]interface{}{
+					"inventoryLevels": nil,
+					"userErrors": []map[string]interface{}{
+						{"field": []string{"inventoryItemAdjustments"}, "message": fmt.Sprintf("Inventory item %d is not stocked at the location", key.inventoryItemID)},
+					},
+				},
+			}})
+			return
+		}
+		if _, ok := deltas[key]; !ok {
+			keys = append(keys, key)
+		}
+		deltas[key] += int(delta)
+	}
+	levels := []map[string]interface{}{}
+	for _, key := range keys {
+		s.inventory[key] += deltas[key]
+		levels = append(levels, map[string]interface{}{
+			"available": s.inventory[key],
+			"item":      map[string]interface{}{"id": fmt.Sprintf("gid://shopify/InventoryItem/%d", key.inventoryItemID)},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+		"inventoryBulkAdjustQuantityAtLocation": map[string]interface{}{
+			"inventoryLevels": levels,
+			"userErrors":      []interface{}{},
+		},
+	}})
+}
+
+// gidID returns the numeric ID of a GraphQL global ID or 0.
+func gidID(v interface{}) int64 {
+	gid, _ := v.(string)
+	id, _ := strconv.ParseInt(gid[strings.LastIndex(gid, "/")+1:], 10, 64)
+	return id
+}
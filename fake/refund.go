@@ -0,0 +1,170 @@
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/shopspring/decimal"
+)
+
+type refund struct {
+	ID       int64  `json:"id,omitempty"`
+	OrderID  int64  `json:"order_id,omitempty"`
+	Currency string `json:"currency,omitempty"`
+	Note     string `json:"note,omitempty"`
+	Shipping *struct {
+		FullRefund bool             `json:"full_refund,omitempty"`
+		Amount     *decimal.Decimal `json:"amount,omitempty"`
+	} `json:"shipping,omitempty"`
+	RefundLineItems []struct {
+		LineItemID  int64            `json:"line_item_id"`
+		Quantity    int              `json:"quantity"`
+		RestockType string           `json:"restock_type,omitempty"`
+		Subtotal    *decimal.Decimal `json:"subtotal,omitempty"`
+	} `json:"refund_line_items,omitempty"`
+	Transactions []goshopify.Transaction `json:"transactions,omitempty"`
+}
+
+func (s *Server) handleListTransactions(w http.ResponseWriter, r *http.Request, ids []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.order(ids[0])
+	if o == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, goshopify.TransactionsResource{Transactions: o.Transactions})
+}
+
+func (s *Server) handleCreateTransaction(w http.ResponseWriter, r *http.Request, ids []int64) {
+	resource := goshopify.TransactionResource{}
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil || resource.Transaction == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": "invalid transaction"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.order(ids[0])
+	if o == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	t := *resource.Transaction
+	t.ID = s.nextID
+	t.OrderID = o.ID
+	if t.Status == "" {
+		t.Status = "success"
+	}
+	s.nextID++
+	o.Transactions = append(o.Transactions, t)
+	writeJSON(w, http.StatusCreated, goshopify.TransactionResource{Transaction: &t})
+}
+
+// handleCalculateRefund suggests refunding line item prices and shipping
+// against the order's first successful sale.
+func (s *Server) handleCalculateRefund(w http.ResponseWriter, r *http.Request, ids []int64) {
+	resource := struct {
+		Refund refund `json:"refund"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": err.Error()})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.order(ids[0])
+	if o == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	rf := resource.Refund
+	rf.Currency = o.Currency
+	total := decimal.Zero
+	for i, rli := range rf.RefundLineItems {
+		li := lineItem(o, rli.LineItemID)
+		if li == nil || rli.Quantity > li.Quantity {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string][]string{"errors": {"invalid refund line item"}})
+			return
+		}
+		subtotal := decimal.Zero
+		if li.Price != nil {
+			subtotal = li.Price.Mul(decimal.NewFromInt(int64(rli.Quantity)))
+		}
+		rf.RefundLineItems[i].Subtotal = &subtotal
+		total = total.Add(subtotal)
+	}
+	if rf.Shipping != nil {
+		if rf.Shipping.FullRefund {
+			for _, sl := range o.ShippingLines {
+				if sl.Price != nil {
+					total = total.Add(*sl.Price)
+				}
+			}
+		} else if rf.Shipping.Amount != nil {
+			total = total.Add(*rf.Shipping.Amount)
+		}
+	}
+	rf.Transactions = nil
+	for _, t := range o.Transactions {
+		if (t.Kind == "sale" || t.Kind == "capture") && t.Status == "success" {
+			parentID := t.ID
+			rf.Transactions = []goshopify.Transaction{{OrderID: o.ID, ParentID: &parentID, Amount: &total, Kind: "suggested_refund", Gateway: t.Gateway}}
+			break
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"refund": rf})
+}
+
+func (s *Server) handleCreateRefund(w http.ResponseWriter, r *http.Request, ids []int64) {
+	resource := struct {
+		Refund refund `json:"refund"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": err.Error()})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.order(ids[0])
+	if o == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+		return
+	}
+	rf := resource.Refund
+	rf.ID = s.nextID
+	rf.OrderID = o.ID
+	s.nextID++
+	now := time.Now()
+	for i := range rf.Transactions {
+		rf.Transactions[i].ID = s.nextID
+		rf.Transactions[i].OrderID = o.ID
+		rf.Transactions[i].Status = "success"
+		rf.Transactions[i].CreatedAt = &now
+		s.nextID++
+		o.Transactions = append(o.Transactions, rf.Transactions[i])
+	}
+	o.Refunds = append(o.Refunds, goshopify.Refund{Id: rf.ID, OrderId: o.ID, Note: rf.Note, Transactions: rf.Transactions})
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"refund": rf})
+}
+
+func (s *Server) handleCancelOrder(w http.ResponseWriter, r *http.Request, ids []int64) {
+	s.updateOrder(w, ids[0], func(o *goshopify.Order) {
+		now := time.Now()
+		o.CancelledAt = &now
+		o.ClosedAt = &now
+		o.CancelReason = "other"
+	})
+}
+
+func (s *Server) handleCloseOrder(w http.ResponseWriter, r *http.Request, ids []int64) {
+	s.updateOrder(w, ids[0], func(o *goshopify.Order) {
+		now := time.Now()
+		o.ClosedAt = &now
+	})
+}
+
+func (s *Server) handleOpenOrder(w http.ResponseWriter, r *http.Request, ids []int64) {
+	s.updateOrder(w, ids[0], func(o *goshopify.Order) { o.ClosedAt = nil })
+}
@@ -0,0 +1,80 @@
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+func (s *Server) webhookRoutes() []route {
+	return []route{
+		{http.MethodGet, "webhooks", s.handleListWebhooks},
+		{http.MethodPost, "webhooks", s.handleCreateWebhook},
+		{http.MethodDelete, "webhooks/*", s.handleDeleteWebhook},
+	}
+}
+
+// Webhooks returns a copy of all webhook subscriptions.
+func (s *Server) Webhooks() []goshopify.Webhook {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]goshopify.Webhook(nil), s.webhooks...)
+}
+
+// handleListWebhooks lists webhook subscriptions, optionally filtered by
+// topic and address.
+func (s *Server) handleListWebhooks(w http.ResponseWriter, r *http.Request, _ []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	webhooks := []goshopify.Webhook{}
+	for _, wh := range s.webhooks {
+		if matchQuery(r.URL.Query(), map[string]string{"topic": wh.Topic, "address": wh.Address}) {
+			webhooks = append(webhooks, wh)
+		}
+	}
+	writeJSON(w, http.StatusOK, goshopify.WebhooksResource{Webhooks: webhooks})
+}
+
+// handleCreateWebhook rejects duplicate subscriptions for the same topic
+// and address like Shopify does.
+func (s *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request, _ []int64) {
+	resource := goshopify.WebhookResource{}
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil || resource.Webhook == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errors": "invalid webhook"})
+		return
+	}
+	wh := *resource.Webhook
+	if wh.Topic == "" || wh.Address == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string][]string{"errors": {"topic and address can't be blank"}})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.webhooks {
+		if existing.Topic == wh.Topic && existing.Address == wh.Address {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string][]string{"address": {"for this topic has already been taken"}})
+			return
+		}
+	}
+	wh.ID = s.nextID
+	s.nextID++
+	if wh.Format == "" {
+		wh.Format = "json"
+	}
+	s.webhooks = append(s.webhooks, wh)
+	writeJSON(w, http.StatusCreated, goshopify.WebhookResource{Webhook: &wh})
+}
+
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request, ids []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.webhooks {
+		if s.webhooks[i].ID == ids[0] {
+			s.webhooks = append(s.webhooks[:i], s.webhooks[i+1:]...)
+			writeJSON(w, http.StatusOK, map[string]string{})
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"errors": "Not Found"})
+}
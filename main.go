@@ -1,22 +1,33 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/OfficiallyEQL/orderer/api"
 	"github.com/OfficiallyEQL/orderer/order"
 	"github.com/alecthomas/kong"
 	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/shopspring/decimal"
 )
 
+// requestTimeout limits each request to the Shopify Admin API.
+const requestTimeout = 30 * time.Second
+
 var (
 	// version vars set by goreleaser
 	version = "tip"
@@ -36,29 +47,52 @@ identifier.
 type CLI struct {
 	Get          GetCmd          `cmd:"" help:"Get order by order ID"`
 	List         ListCmd         `cmd:"" help:"List first 50 orders with matching name"`
-	Meta         MetaCmd         `cmd:"" help:"List metafields for given order"`
+	Export       ExportCmd       `cmd:"" help:"Export all orders as JSON lines"`
+	Meta         MetaCmd         `cmd:"" help:"List, set, delete or sync metafields of order, customer or variant"`
 	Transactions TransactionsCmd `cmd:"" help:"List Transactions for given order"`
+	AddTx        AddTxCmd        `cmd:"" name:"add-transactions" help:"Add transactions to existing order"`
 	Create       CreateCmd       `cmd:"" help:"Create order"`
 	Update       UpdateCmd       `cmd:"" help:"Update order"`
 	Merge        MergeCmd        `cmd:"" help:"Create or update order"`
+	Watch        WatchCmd        `cmd:"" help:"Merge orders from JSON or CSV files dropped into directory and move them to processed or failed"`
 	Delete       DeleteCmd       `cmd:"" help:"Delete order"`
 	BatchDelete  BatchDeleteCmd  `cmd:"" help:"Delete orders"`
 	Replace      ReplaceCmd      `cmd:"" help:"Replace order first then create new one"`
-
-	Variant   VariantCmd   `cmd:"" help:"Get product variant by variant ID"`
+	Fulfill      FulfillCmd      `cmd:"" help:"Fulfill order with optional tracking info"`
+	Refund       RefundCmd       `cmd:"" help:"Refund line items, shipping or amount of order"`
+	Cancel       CancelCmd       `cmd:"" help:"Cancel order"`
+	Close        CloseCmd        `cmd:"" help:"Close order"`
+	Reopen       ReopenCmd       `cmd:"" help:"Reopen closed order"`
+	Anonymise    AnonymiseCmd    `cmd:"" help:"Replace personal data in JSON orders or customers with fake values"`
+	CheckStock   CheckStockCmd   `cmd:"" help:"Check that inventory covers all line items of a batch of orders before creating them"`
+
+	Product   ProductCmd   `cmd:"" help:"Get, list, create, update, delete or import products"`
+	Variant   VariantCmd   `cmd:"" help:"Get, list, create, update or delete product variants"`
 	Inventory InventoryCmd `cmd:"" help:"Get inventory level including location for inventory_item_id or variant_id"`
+	Location  LocationCmd  `cmd:"" help:"List locations or get location by ID or name"`
 	Customer  CustomerCmd  `cmd:""  help:"Get, List, Create, Merge and Delete customer"`
-	Scopes    ScopesCmd    `cmd:"" help:"Get scopes for given Admin token"`
+	Seed      SeedCmd      `cmd:"" help:"Seed store with products, customers and orders from fixture directory"`
+	Webhook   WebhookCmd   `cmd:"" help:"Register, list or delete webhook subscriptions or send a signed test webhook"`
+
+	Serve         ServeCmd         `cmd:"" help:"Serve order, inventory and customer operations as JSON HTTP API for configured store profiles"`
+	ServeWebhooks ServeWebhooksCmd `cmd:"" help:"Receive webhooks, verify their signature and append them to a JSONL file or pass them to a command"`
+	Scopes        ScopesCmd        `cmd:"" help:"Get scopes for given Admin token"`
 
 	Version kong.VersionFlag `help:"Show version." env:"-"`
 }
 
 type Config struct {
-	Store       string   `required:"" help:"Shopify store name as found in <name>.myshopify.com URL."`
-	Token       string   `required:"" help:"Shopify Admin token."`
-	ShopifyLogs LogLevel `short:"L" help:"Log level (debug, info, warn, error, none)" enum:"debug,info,warn,error,none" default:"none"`
+	Store       string        `required:"" help:"Shopify store name as found in <name>.myshopify.com URL."`
+	Token       string        `required:"" help:"Shopify Admin token."`
+	ShopifyLogs LogLevel      `short:"L" help:"Log level (debug, info, warn, error, none)" enum:"debug,info,warn,error,none" default:"none"`
+	Timeout     time.Duration `help:"deadline of each operation of batch commands, e.g. importing an order or a customer" default:"2m"`
 	out         io.Writer
 	client      *goshopify.Client
+	skuResolver *order.SKUResolver
+	ctx         context.Context
+	// transport sends the requests of the clients created by newClient,
+	// http.DefaultTransport if nil.
+	transport http.RoundTripper
 }
 
 type GetCmd struct {
@@ -70,11 +104,71 @@ type ListCmd struct {
 	Config
 	Order *goshopify.Order `optional:"" arg:"" type:"jsonfile" placeholder:"order.json" help:"File containing JSON encoded order name to be listed (only name matters)"`
 	Name  string           `help:"name of order(s) to be listed"`
+	Bulk  bool             `help:"list all orders using a GraphQL bulk operation"`
+}
+
+type ExportCmd struct {
+	Config
+	Name         string        `help:"name of order(s) to be exported"`
+	Bulk         bool          `help:"export using a GraphQL bulk operation, faster for large stores but with fewer order fields"`
+	PollInterval time.Duration `help:"bulk operation status poll interval" default:"2s"`
+	Anonymise    bool          `help:"replace personal data with fake values, requires --seed"`
+	Seed         string        `help:"secret key for anonymisation, the same seed yields the same fake values"`
+}
+
+type AnonymiseCmd struct {
+	File     string `arg:"" optional:"" type:"existingfile" placeholder:"orders.jsonl" help:"File containing JSON or JSON lines encoded orders or customers, default: stdin"`
+	Resource string `short:"r" help:"resource type (order, customer)" enum:"order,customer" default:"order"`
+	Seed     string `required:"" help:"secret key for anonymisation, the same seed yields the same fake values"`
+	in       io.Reader
+	out      io.Writer
+}
+
+type CheckStockCmd struct {
+	Config
+	File       string          `arg:"" type:"existingfile" placeholder:"orders.jsonl" help:"File containing JSON array, JSON lines or CSV (.csv) encoded orders"`
+	ResolveSKU bool            `short:"s" help:"resolve variant_id of line items by sku if missing"`
+	UnknownSKU order.SKUPolicy `help:"handling of line items with unresolvable sku: fail, skip or custom (line item without variant)" enum:"fail,skip,custom" default:"fail"`
 }
 
 type MetaCmd struct {
+	List   MetaListCmd   `cmd:"" default:"withargs" help:"List metafields for given order, customer or variant"`
+	Set    MetaSetCmd    `cmd:"" help:"Create or update metafield by namespace and key"`
+	Delete MetaDeleteCmd `cmd:"" help:"Delete metafield by namespace and key"`
+	Sync   MetaSyncCmd   `cmd:"" help:"Create or update metafields from JSON file"`
+}
+
+type MetaListCmd struct {
 	Config
-	ID int64 `arg:"" required:"" help:"order ID"`
+	ID       int64  `arg:"" required:"" help:"order, customer or variant ID"`
+	Resource string `short:"r" help:"resource type (order, customer, variant)" enum:"order,customer,variant" default:"order"`
+}
+
+type MetaSetCmd struct {
+	Config
+	ID        int64  `arg:"" required:"" help:"order, customer or variant ID"`
+	Resource  string `short:"r" help:"resource type (order, customer, variant)" enum:"order,customer,variant" default:"order"`
+	Namespace string `required:"" help:"metafield namespace"`
+	Key       string `required:"" help:"metafield key"`
+	Type      string `help:"metafield type, e.g. single_line_text_field, number_integer, json" default:"single_line_text_field"`
+	Value     string `required:"" help:"metafield value"`
+}
+
+type MetaDeleteCmd struct {
+	Config
+	ID        int64  `arg:"" required:"" help:"order, customer or variant ID"`
+	Resource  string `short:"r" help:"resource type (order, customer, variant)" enum:"order,customer,variant" default:"order"`
+	Namespace string `required:"" help:"metafield namespace"`
+	Key       string `required:"" help:"metafield key"`
+}
+
+type MetaSyncCmd struct {
+	Config
+	ID         int64                 `arg:"" required:"" help:"order, customer or variant ID"`
+	Metafields []goshopify.Metafield `arg:"" type:"jsonfile" placeholder:"metafields.json" help:"File containing JSON encoded array of metafields with namespace, key, type and value"`
+	Resource   string                `short:"r" help:"resource type (order, customer, variant)" enum:"order,customer,variant" default:"order"`
+	Delete     bool                  `help:"delete metafields in namespaces of given metafields that are not in file"`
+	DryRun     bool                  `short:"n" help:"show changes without applying them"`
 }
 
 type TransactionsCmd struct {
@@ -84,18 +178,57 @@ type TransactionsCmd struct {
 
 type CreateCmd struct {
 	Config
-	Order         *goshopify.Order `required:"" arg:"" type:"jsonfile" placeholder:"order.json" help:"File containing JSON encoded order to be created"`
-	Unique        bool             `short:"u" help:"assert order name is new"`
-	VerifyProduct bool             `short:"p" help:"verify that product variant for given variant id exists before creating order"`
-	Inventory     bool             `short:"i" help:"update inventory (-1) when order is created"`
+	Order *goshopify.Order `required:"" arg:"" type:"jsonfile" placeholder:"order.json" help:"File containing JSON encoded order to be created"`
+	CreateFlags
+}
+
+// CreateFlags are the import options of orders created by the create and
+// replace commands.
+type CreateFlags struct {
+	Unique           bool                   `short:"u" help:"assert order name is new"`
+	VerifyProduct    bool                   `short:"p" help:"verify that product variant for given variant id exists before creating order"`
+	Inventory        bool                   `short:"i" help:"update inventory (-1) when order is created"`
+	ResolveSKU       bool                   `short:"s" help:"resolve variant_id of line items by sku if missing"`
+	UnknownSKU       order.SKUPolicy        `help:"handling of line items with unresolvable sku: fail, skip or custom (line item without variant)" enum:"fail,skip,custom" default:"fail"`
+	Fulfill          bool                   `short:"f" help:"fulfill order after creation, using its fulfillments or all line items, without notifying customer"`
+	Transactions     order.TransactionSet   `type:"jsonfile" placeholder:"transactions.json" help:"File containing JSON encoded transactions keyed by order name, replacing the order's transactions"`
+	ValidateTx       bool                   `name:"validate-transactions" help:"validate that transaction amounts reconcile with total price"`
+	CustomerStrategy order.CustomerStrategy `help:"link embedded customer to existing customer by email or phone: link (fail if missing), create (if missing), merge (update or create) or none" enum:"none,link,create,merge" default:"none"`
+	NormalisePhones  bool                   `name:"e164" help:"normalise phone numbers of order, customer and addresses to E.164 before writes and customer lookups"`
+	PhoneRegion      string                 `help:"default region (ISO country code) of phone numbers without country code if the address has no country" placeholder:"AU"`
+	Addresses        order.AddressPolicy    `help:"validate billing, shipping and customer addresses: fix country, province and zip, strict (fail instead of fixing) or none" enum:"none,fix,strict" default:"none"`
 }
 
 type MergeCmd struct {
 	Config
-	Order         *goshopify.Order `required:"" arg:"" type:"jsonfile" placeholder:"order.json" help:"File containing JSON encoded order to be merged (created or updated)"`
-	Unique        bool             `short:"u" help:"assert order name is used at most once"`
-	VerifyProduct bool             `short:"p" help:"verify that product variant for given variant id exists before creating order"`
-	Inventory     bool             `short:"i" help:"update inventory (-1) if order is created"`
+	Order *goshopify.Order `required:"" arg:"" type:"jsonfile" placeholder:"order.json" help:"File containing JSON encoded order to be merged (created or updated)"`
+	MergeFlags
+}
+
+// MergeFlags are the import options of orders merged by the merge and
+// watch commands.
+type MergeFlags struct {
+	VerifyProduct    bool                   `short:"p" help:"verify that product variant for given variant id exists before creating order"`
+	Inventory        bool                   `short:"i" help:"update inventory (-1) if order is created"`
+	ResolveSKU       bool                   `short:"s" help:"resolve variant_id of line items by sku if missing"`
+	UnknownSKU       order.SKUPolicy        `help:"handling of line items with unresolvable sku: fail, skip or custom (line item without variant)" enum:"fail,skip,custom" default:"fail"`
+	Fulfill          bool                   `short:"f" help:"fulfill order after creation, using its fulfillments or all line items, without notifying customer"`
+	Transactions     order.TransactionSet   `type:"jsonfile" placeholder:"transactions.json" help:"File containing JSON encoded transactions keyed by order name, replacing the order's transactions"`
+	ValidateTx       bool                   `name:"validate-transactions" help:"validate that transaction amounts reconcile with total price"`
+	CustomerStrategy order.CustomerStrategy `help:"link embedded customer to existing customer by email or phone: link (fail if missing), create (if missing), merge (update or create) or none" enum:"none,link,create,merge" default:"none"`
+	NormalisePhones  bool                   `name:"e164" help:"normalise phone numbers of order, customer and addresses to E.164 before writes and customer lookups"`
+	PhoneRegion      string                 `help:"default region (ISO country code) of phone numbers without country code if the address has no country" placeholder:"AU"`
+	Addresses        order.AddressPolicy    `help:"validate billing, shipping and customer addresses: fix country, province and zip, strict (fail instead of fixing) or none" enum:"none,fix,strict" default:"none"`
+	CheckStock       bool                   `help:"check that inventory covers the line items before importing, for watch those of all orders of a file"`
+}
+
+type WatchCmd struct {
+	Config
+	Dir      string        `arg:"" type:"existingdir" placeholder:"incoming" help:"directory JSON, JSON lines or CSV order files are dropped into"`
+	Interval time.Duration `help:"time between directory scans" default:"10s"`
+	MinAge   time.Duration `help:"time since last modification before a file is imported, to skip files still being uploaded" default:"5s"`
+	Once     bool          `help:"import files found in a single scan and exit"`
+	MergeFlags
 }
 
 type UpdateCmd struct {
@@ -104,6 +237,13 @@ type UpdateCmd struct {
 	VerifyProduct bool             `short:"p" help:"verify that product variant for given variant id exists before creating order"`
 }
 
+type AddTxCmd struct {
+	Config
+	Transactions []goshopify.Transaction `arg:"" type:"jsonfile" placeholder:"transactions.json" help:"File containing JSON encoded array of transactions to be added"`
+	ID           int64                   `help:"ID of order" xor:"id"`
+	Name         string                  `help:"name of order" xor:"id"`
+}
+
 type DeleteCmd struct {
 	Config
 	Order  *goshopify.Order `optional:"" arg:"" type:"jsonfile" placeholder:"order.json" help:"File containing JSON encoded order to be deleted"`
@@ -119,15 +259,112 @@ type BatchDeleteCmd struct {
 
 type ReplaceCmd struct {
 	Config
-	Order         *goshopify.Order `required:"" arg:"" type:"jsonfile" placeholder:"order.json" help:"File containing JSON encoded order to be replaced"`
-	Unique        bool             `short:"u" help:"assert order name is new"`
-	VerifyProduct bool             `short:"p" help:"verify that product variant for given variant id exists before creating order"`
-	Inventory     bool             `short:"i" help:"update inventory (-1) when order is created"`
+	Order *goshopify.Order `required:"" arg:"" type:"jsonfile" placeholder:"order.json" help:"File containing JSON encoded order to be replaced"`
+	CreateFlags
+}
+
+type FulfillCmd struct {
+	Config
+	Fulfillment     *goshopify.Fulfillment `optional:"" arg:"" type:"jsonfile" placeholder:"fulfillment.json" help:"File containing JSON encoded fulfillment with tracking info and line items (by id, variant_id or sku) to be fulfilled"`
+	ID              int64                  `help:"ID of order to be fulfilled" xor:"id"`
+	Name            string                 `help:"name of order to be fulfilled" xor:"id"`
+	TrackingCompany string                 `help:"tracking company, e.g. \"Australia Post\""`
+	TrackingNumber  string                 `help:"tracking number"`
+	TrackingURL     string                 `name:"tracking-url" help:"tracking URL"`
+	Notify          bool                   `help:"notify customer about shipment"`
+}
+
+type RefundCmd struct {
+	Config
+	ID             int64            `help:"ID of order to be refunded" xor:"id"`
+	Name           string           `help:"name of order to be refunded" xor:"id"`
+	LineItems      map[int64]int    `name:"line-item" help:"quantity to be refunded by line item ID, e.g. --line-item 1234=1"`
+	Shipping       bool             `help:"refund full shipping" xor:"shipping"`
+	ShippingAmount *decimal.Decimal `help:"shipping amount to be refunded" xor:"shipping"`
+	Amount         *decimal.Decimal `help:"refund amount instead of calculated amount, spread over the suggested transactions up to their refundable amount"`
+	Restock        string           `help:"restock type of refunded line items (no_restock, cancel, return)" enum:"no_restock,cancel,return" default:"no_restock"`
+	LocationID     string           `help:"location ID or name of restocked line items"`
+	Note           string           `help:"reason for refund"`
+	Notify         bool             `help:"notify customer about refund"`
+	DryRun         bool             `short:"n" help:"show refund calculation without creating refund"`
+}
+
+type CancelCmd struct {
+	Config
+	ID      int64  `help:"ID of order to be cancelled" xor:"id"`
+	Name    string `help:"name of order to be cancelled" xor:"id"`
+	Reason  string `help:"cancel reason (customer, fraud, inventory, declined, other)" enum:"customer,fraud,inventory,declined,other" default:"other"`
+	Restock bool   `help:"restock line items"`
+	Notify  bool   `help:"notify customer about cancellation"`
+}
+
+type CloseCmd struct {
+	Config
+	ID   int64  `help:"ID of order to be closed" xor:"id"`
+	Name string `help:"name of order to be closed" xor:"id"`
+}
+
+type ReopenCmd struct {
+	Config
+	ID   int64  `help:"ID of order to be reopened" xor:"id"`
+	Name string `help:"name of order to be reopened" xor:"id"`
+}
+
+type ProductCmd struct {
+	Get    ProductGetCmd    `cmd:"" help:"Get product by ID"`
+	List   ProductListCmd   `cmd:"" help:"List products, optionally filtered by handle, vendor or product type"`
+	Create ProductCreateCmd `cmd:"" help:"Create product with variants from JSON"`
+	Update ProductUpdateCmd `cmd:"" help:"Update product from JSON"`
+	Delete ProductDeleteCmd `cmd:"" help:"Delete product by ID"`
+	Import ProductImportCmd `cmd:"" help:"Create products with variants and initial inventory from JSON or CSV file, skipping existing handles"`
+}
+
+type ProductGetCmd struct {
+	Config
+	ID int64 `arg:"" help:"product ID"`
+}
+
+type ProductListCmd struct {
+	Config
+	Handle      string `help:"product handle"`
+	Vendor      string `help:"product vendor"`
+	ProductType string `help:"product type"`
+}
+
+type ProductCreateCmd struct {
+	Config
+	Product *goshopify.Product `arg:"" type:"jsonfile" placeholder:"product.json" help:"File containing JSON encoded product to be created"`
+}
+
+type ProductUpdateCmd struct {
+	Config
+	Product *goshopify.Product `arg:"" type:"jsonfile" placeholder:"product.json" help:"File containing JSON encoded product to be updated, identified by ID"`
+}
+
+type ProductDeleteCmd struct {
+	Config
+	ID int64 `arg:"" help:"ID of product to be deleted"`
+}
+
+type ProductImportCmd struct {
+	Config
+	File       string `arg:"" type:"existingfile" placeholder:"products.csv" help:"JSON, JSONL or CSV file containing products to be imported"`
+	Format     string `help:"file format (auto, json, jsonl, csv), auto uses the file extension" enum:"auto,json,jsonl,csv" default:"auto"`
+	LocationID string `help:"location ID or name of initial inventory, default: the only active location"`
+	DryRun     bool   `short:"n" help:"only report what would be imported"`
 }
 
 type VariantCmd struct {
 	Get    VariantGetCmd    `cmd:"" help:"Get Variant by ID"`
-	Create VariantCreateCmd `cmd:"" help:"Get Variant"`
+	List   VariantListCmd   `cmd:"" help:"List variants of product"`
+	Create VariantCreateCmd `cmd:"" help:"Create Variant"`
+	Update VariantUpdateCmd `cmd:"" help:"Update Variant"`
+	Delete VariantDeleteCmd `cmd:"" help:"Delete Variant"`
+}
+
+type VariantListCmd struct {
+	Config
+	Product int64 `required:"" help:"product ID"`
 }
 
 type VariantGetCmd struct {
@@ -142,9 +379,26 @@ type VariantCreateCmd struct {
 	Variant *goshopify.Variant `arg:"" type:"jsonfile" placeholder:"variant.json" help:"File containing JSON encoded variant to be created"`
 }
 
+type VariantUpdateCmd struct {
+	Config
+	Variant *goshopify.Variant `arg:"" type:"jsonfile" placeholder:"variant.json" help:"File containing JSON encoded variant to be updated, identified by ID"`
+}
+
+type VariantDeleteCmd struct {
+	Config
+	ID      int64 `arg:"" help:"ID of variant to be deleted"`
+	Product int64 `help:"product ID of variant, looked up if not given"`
+}
+
 type InventoryCmd struct {
-	Get    InventoryGetCmd    `cmd:"" help:"Get inventory levels by variant ID or inventory item ID"`
-	Adjust InventoryAdjustCmd `cmd:"" help:"Update inventory levels for given variant ID or inventory item ID."`
+	Get        InventoryGetCmd        `cmd:"" help:"Get inventory levels by variant ID or inventory item ID"`
+	List       InventoryListCmd       `cmd:"" help:"List inventory levels with SKU and variant at a location"`
+	Adjust     InventoryAdjustCmd     `cmd:"" help:"Update inventory levels for given variant ID or inventory item ID."`
+	Set        InventorySetCmd        `cmd:"" help:"Set available quantity for given variant ID or inventory item ID at a location"`
+	Transfer   InventoryTransferCmd   `cmd:"" help:"Move available quantity of given variant ID or inventory item ID between locations"`
+	Connect    InventoryConnectCmd    `cmd:"" help:"Stock given variant ID or inventory item ID at a location"`
+	Disconnect InventoryDisconnectCmd `cmd:"" help:"Remove inventory level of given variant ID or inventory item ID at a location"`
+	Sync       InventorySyncCmd       `cmd:"" help:"Set inventory levels to the quantities of a stock CSV file with sku, location and quantity columns"`
 }
 
 type InventoryGetCmd struct {
@@ -155,10 +409,70 @@ type InventoryGetCmd struct {
 
 type InventoryAdjustCmd struct {
 	Config
-	InventoryItemID int64 `help:"inventory item ID" xor:"id"`
-	VariantID       int64 `help:"variant ID" xor:"id"`
-	LocationID      int64 `help:"location ID of inventory to be adjusted"`
-	Amount          int   `help:"adjust inventory levels for given product. Use negative number to reduce inventory."`
+	InventoryItemID int64  `help:"inventory item ID" xor:"id"`
+	VariantID       int64  `help:"variant ID" xor:"id"`
+	LocationID      string `help:"location ID or name of inventory to be adjusted"`
+	Amount          int    `help:"adjust inventory levels for given product. Use negative number to reduce inventory."`
+}
+
+type InventoryListCmd struct {
+	Config
+	Location string `help:"location ID or name" required:""`
+}
+
+type InventorySetCmd struct {
+	Config
+	InventoryItemID int64  `help:"inventory item ID" xor:"id" required:""`
+	VariantID       int64  `help:"variant ID" xor:"id" required:""`
+	LocationID      string `help:"location ID or name of inventory to be set" required:""`
+	Available       int    `help:"available quantity" required:""`
+}
+
+type InventoryTransferCmd struct {
+	Config
+	InventoryItemID int64  `help:"inventory item ID" xor:"id" required:""`
+	VariantID       int64  `help:"variant ID" xor:"id" required:""`
+	FromLocation    string `help:"location ID or name to take items from" required:""`
+	ToLocation      string `help:"location ID or name to move items to, connected if needed" required:""`
+	Quantity        int    `help:"number of items to move" required:""`
+}
+
+type InventoryConnectCmd struct {
+	Config
+	InventoryItemID int64  `help:"inventory item ID" xor:"id" required:""`
+	VariantID       int64  `help:"variant ID" xor:"id" required:""`
+	LocationID      string `help:"location ID or name" required:""`
+}
+
+type InventoryDisconnectCmd struct {
+	Config
+	InventoryItemID int64  `help:"inventory item ID" xor:"id" required:""`
+	VariantID       int64  `help:"variant ID" xor:"id" required:""`
+	LocationID      string `help:"location ID or name" required:""`
+}
+
+type InventorySyncCmd struct {
+	Config
+	File              string  `arg:"" type:"existingfile" placeholder:"stock.csv" help:"CSV file with sku, location (ID or name) and quantity columns"`
+	DryRun            bool    `help:"show changes without applying them"`
+	BatchSize         int     `help:"number of inventory levels adjusted per request" default:"100"`
+	MaxChangedPercent float64 `help:"refuse to apply if more than this percentage of items change by more than --max-change" default:"10"`
+	MaxChange         int     `help:"change in quantity per item tolerated by --max-changed-percent" default:"10"`
+	Force             bool    `help:"apply changes exceeding the threshold"`
+}
+
+type LocationCmd struct {
+	List LocationListCmd `cmd:"" help:"List all locations with address, active status and fulfillment capability"`
+	Get  LocationGetCmd  `cmd:"" help:"Get location by ID or name"`
+}
+
+type LocationListCmd struct {
+	Config
+}
+
+type LocationGetCmd struct {
+	Config
+	Location string `arg:"" help:"location ID or name"`
 }
 
 type CustomerCmd struct {
@@ -169,6 +483,8 @@ type CustomerCmd struct {
 	Delete      CustomerDeleteCmd      `cmd:"" help:"Delete customer matched by email."`
 	BatchDelete CustomerBatchDeleteCmd `cmd:"" help:"Update customers."`
 	Create      CustomerCreateCmd      `cmd:"" help:"Create customer from JSON."`
+	Import      CustomerImportCmd      `cmd:"" help:"Create or update customers from JSONL or CSV file with deduplication."`
+	Export      CustomerExportCmd      `cmd:"" help:"Export customer data with orders, addresses, metafields and transactions as zip archive."`
 }
 
 type CustomerGetCmd struct {
@@ -178,8 +494,9 @@ type CustomerGetCmd struct {
 
 type CustomerListCmd struct {
 	Config
-	Email string `arg:"" optional:"" help:"Customer item ID" xor:"id"`
-	Phone string `help:"variant ID" xor:"id"`
+	Email       string `arg:"" optional:"" help:"Customer item ID" xor:"id"`
+	Phone       string `help:"phone number, normalised to E.164" xor:"id"`
+	PhoneRegion string `help:"region (ISO country code) of phone number without country code" placeholder:"AU"`
 }
 
 type CustomerCreateCmd struct {
@@ -203,11 +520,110 @@ type CustomerDeleteCmd struct {
 	Email    string              `help:"email of customer to be deleted." xor:"id"`
 	ID       int64               `help:"ID of customer to be deleted." xor:"id"`
 	Max      int                 `help:"maximum number of customers to be deleted. <= 50 (page size). default: no limit" default:"-1"`
+	CustomerDeleteFlags
+}
+
+type CustomerDeleteFlags struct {
+	Cascade    bool  `help:"delete the customer's orders first, or reassign them with --reassign-to" xor:"mode"`
+	ReassignTo int64 `help:"with --cascade, ID of customer the orders are reassigned to instead of being deleted" placeholder:"ID"`
+	Redact     bool  `help:"request erasure of the customer's personal data instead of deleting, keeping orders" xor:"mode"`
+	DryRun     bool  `short:"n" help:"only report what would be done"`
+}
+
+type CustomerImportCmd struct {
+	Config
+	File         string              `arg:"" type:"existingfile" placeholder:"customers.csv" help:"JSONL or CSV file containing customers to be imported"`
+	Format       string              `help:"file format (auto, jsonl, csv), auto uses the file extension" enum:"auto,jsonl,csv" default:"auto"`
+	Key          []string            `short:"k" help:"keys customers are matched by (email, phone, external_id)" default:"email"`
+	ExternalID   string              `placeholder:"NAMESPACE.KEY" help:"customer metafield storing external_id"`
+	PhoneRegion  string              `placeholder:"AU" help:"default region (ISO country code) of phone numbers without country code if the customer has no address country"`
+	Addresses    order.AddressPolicy `help:"validate addresses: fix country, province and zip, strict (fail instead of fixing) or none" enum:"none,fix,strict" default:"none"`
+	DryRun       bool                `short:"n" help:"only report what would be imported"`
+	PollInterval time.Duration       `help:"bulk operation status poll interval for external ID matching" default:"2s"`
+}
+
+type CustomerExportCmd struct {
+	Config
+	Email  string `help:"email of customer to be exported" xor:"id" required:""`
+	ID     int64  `help:"ID of customer to be exported" xor:"id" required:""`
+	Output string `short:"o" placeholder:"customer.zip" help:"archive file, default: customer-<ID>.zip"`
 }
 
 type CustomerBatchDeleteCmd struct {
 	Config
 	Max int `arg:"" help:"maximum number of customers to be deleted. <= 50 (page size). default: no limit" default:"-1"`
+	CustomerDeleteFlags
+}
+
+type SeedCmd struct {
+	Apply   SeedApplyCmd   `cmd:"" default:"withargs" help:"Create fixtures missing in store and record their IDs in lockfile"`
+	Resolve SeedResolveCmd `cmd:"" help:"Replace fixture refs such as variant_ref in JSON order file with IDs from lockfile"`
+}
+
+type SeedApplyCmd struct {
+	Config
+	Dir      string `arg:"" type:"existingdir" placeholder:"fixtures/" help:"Directory containing products.json, customers.json, orders.json and orders/*.json"`
+	Lockfile string `help:"lockfile recording seeded IDs per store, default: <dir>/seed.lock.json"`
+	DryRun   bool   `short:"n" help:"only report what would be created"`
+}
+
+type SeedResolveCmd struct {
+	File     string `arg:"" type:"existingfile" placeholder:"order.json" help:"JSON file containing fixture refs"`
+	Store    string `required:"" help:"Shopify store name whose IDs are used"`
+	Lockfile string `required:"" type:"existingfile" placeholder:"fixtures/seed.lock.json" help:"lockfile written by seed"`
+	out      io.Writer
+}
+
+type WebhookCmd struct {
+	Register WebhookRegisterCmd `cmd:"" help:"Subscribe address to webhook topics, skipping existing subscriptions"`
+	List     WebhookListCmd     `cmd:"" help:"List webhook subscriptions"`
+	Delete   WebhookDeleteCmd   `cmd:"" help:"Delete webhook subscription"`
+	Send     WebhookSendCmd     `cmd:"" help:"Send JSON payload signed with webhook secret, e.g. to test serve-webhooks locally"`
+}
+
+type WebhookRegisterCmd struct {
+	Config
+	Address string   `required:"" help:"HTTPS URL webhooks are sent to"`
+	Topic   []string `required:"" help:"webhook topics, e.g. orders/paid,orders/fulfilled,orders/cancelled"`
+	Fields  []string `help:"payload fields, default: all"`
+}
+
+type WebhookListCmd struct {
+	Config
+	Topic string `help:"list only subscriptions for topic"`
+}
+
+type WebhookDeleteCmd struct {
+	Config
+	ID int64 `arg:"" help:"ID of webhook subscription to be deleted"`
+}
+
+type WebhookSendCmd struct {
+	URL           string `arg:"" help:"URL of webhook receiver, e.g. http://localhost:8080/"`
+	File          string `arg:"" type:"existingfile" placeholder:"order.json" help:"File containing JSON payload"`
+	Topic         string `required:"" help:"webhook topic, e.g. orders/paid"`
+	WebhookSecret string `required:"" help:"secret used to sign the payload"`
+	Shop          string `help:"shop domain sent with the webhook" default:"orderer-test.myshopify.com"`
+	out           io.Writer
+	client        *http.Client
+}
+
+type ServeWebhooksCmd struct {
+	Addr          string `help:"address to listen on" default:":8080"`
+	WebhookSecret string `required:"" help:"app API secret key webhooks are signed with"`
+	Output        string `short:"o" placeholder:"webhooks.jsonl" help:"JSONL file webhook events are appended to, default: webhooks.jsonl unless --exec is given"`
+	Exec          string `help:"shell command run for each webhook event with the event as JSON on stdin and WEBHOOK_TOPIC and WEBHOOK_ID set"`
+	out           io.Writer
+	ctx           context.Context
+}
+
+type ServeCmd struct {
+	Addr        string        `help:"address to listen on" default:":8080"`
+	APIConfig   string        `name:"config" required:"" type:"existingfile" placeholder:"api.json" help:"JSON file with API bearer tokens and store profiles"`
+	ShopifyLogs LogLevel      `short:"L" help:"Log level (debug, info, warn, error, none)" enum:"debug,info,warn,error,none" default:"none"`
+	Timeout     time.Duration `help:"deadline of each request's operation" default:"2m"`
+	out         io.Writer
+	ctx         context.Context
 }
 
 type ScopesCmd struct {
@@ -219,22 +635,94 @@ var kongOpts = []kong.Option{
 	kong.DefaultEnvars("shopify"),
 	kong.NamedMapper("jsonfile", JSONFileMapper),
 	kong.Vars{"version": fmt.Sprintf("%s (%s on %s)", version, commit, date)},
+	kong.BindTo(context.Background(), (*context.Context)(nil)),
 }
 
 func main() {
-	kctx := kong.Parse(&CLI{}, kongOpts...)
-	kctx.FatalIfErrorf(kctx.Run())
+	ctx, cancel := interruptContext()
+	kctx := kong.Parse(&CLI{}, append(kongOpts, kong.BindTo(ctx, (*context.Context)(nil)))...)
+	err := kctx.Run()
+	cancel()
+	if errors.Is(err, context.Canceled) {
+		err = fmt.Errorf("interrupted: %w", err)
+	}
+	kctx.FatalIfErrorf(err)
+}
+
+// interruptContext returns a context cancelled by SIGINT or SIGTERM.
+// Batch commands complete the operation in flight and stop before the
+// next one, a second signal terminates the process.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			fmt.Fprintf(os.Stderr, "%v: completing operation in flight, repeat to terminate\n", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx, cancel
 }
 
-func (c *Config) AfterApply() error {
+func (c *Config) AfterApply(ctx context.Context) error {
 	c.out = os.Stdout
-	c.client = newClient(c, true)
+	c.ctx = order.WithOperationTimeout(ctx, c.Timeout)
+	c.client = c.newClient(c.ctx)
 	return nil
 }
 
-func newClient(c *Config, withVersion bool) *goshopify.Client {
+// newClient returns a client whose requests use ctx. Commands use the
+// command context, which aborts the request in flight when interrupted.
+// Batch commands run each order, customer or product as separate
+// operation with a client for its order.OperationContext instead, which
+// is completed when interrupted and has the deadline given by --timeout.
+func (c *Config) newClient(ctx context.Context) *goshopify.Client {
+	return newClient(ctx, c, true)
+}
+
+// withContext returns a copy of c whose client uses ctx. The copy shares
+// the SKUs resolved so far with c.
+func (c *Config) withContext(ctx context.Context) *Config {
+	op := *c
+	op.client = c.newClient(ctx)
+	if c.skuResolver == nil {
+		c.skuResolver = order.NewSKUResolver(c.orderClient(), order.SKUPolicyFail)
+	}
+	op.skuResolver = c.skuResolver.WithClient(op.orderClient())
+	return &op
+}
+
+// commandContext returns the context of the command, cancelled when it
+// is interrupted.
+func (c *Config) commandContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// orderClient returns c.client as order.Client.
+func (c *Config) orderClient() order.Client {
+	return order.NewShopifyClient(c.client)
+}
+
+// newOrderClient returns a new client whose requests use ctx as
+// order.Client.
+func (c *Config) newOrderClient(ctx context.Context) order.Client {
+	return order.NewShopifyClient(c.newClient(ctx))
+}
+
+// newClient returns a client for the store of c whose requests use ctx,
+// each limited to requestTimeout.
+func newClient(ctx context.Context, c *Config, withVersion bool) *goshopify.Client {
 	opts := []goshopify.Option{
 		goshopify.WithRetry(5),
+		goshopify.WithHTTPClient(&http.Client{Transport: c.transport}),
+		order.WithContext(ctx, requestTimeout),
 	}
 	if withVersion {
 		opts = append(opts, goshopify.WithVersion("2022-10"))
@@ -243,45 +731,115 @@ func newClient(c *Config, withVersion bool) *goshopify.Client {
 		logger := NewLogger(os.Stdout, c.ShopifyLogs)
 		opts = append(opts, goshopify.WithLogger(logger))
 	}
-	client := goshopify.NewClient(goshopify.App{}, c.Store, c.Token, opts...)
-	client.Client.Timeout = 30 * time.Second
-	return client
+	return goshopify.NewClient(goshopify.App{}, c.Store, c.Token, opts...)
 }
 
 func (c *GetCmd) Run() error {
-	order, err := c.client.Order.Get(c.ID, nil)
+	order, err := c.orderClient().GetOrder(c.ID)
 	if err != nil {
 		return err
 	}
 	return json.NewEncoder(c.out).Encode(order)
 }
 
-func (c *MetaCmd) Run() error {
-	meta, err := order.Meta(c.client, c.ID)
+func (c *MetaListCmd) Run() error {
+	meta, err := order.Metafields(c.orderClient(), metaResource(c.Resource), c.ID)
 	if err != nil {
 		return err
 	}
 	return json.NewEncoder(c.out).Encode(meta)
 }
 
+func (c *MetaSetCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	mf := goshopify.Metafield{Namespace: c.Namespace, Key: c.Key, Type: c.Type, Value: c.Value}
+	result, err := order.SetMetafield(c.orderClient(), metaResource(c.Resource), c.ID, mf)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "metafield set, ID: %d, %s.%s\n", result.ID, result.Namespace, result.Key)
+	return nil
+}
+
+func (c *MetaDeleteCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	deleted, err := order.DeleteMetafield(c.orderClient(), metaResource(c.Resource), c.ID, c.Namespace, c.Key)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		fmt.Fprintf(c.out, "metafield not found: %s.%s\n", c.Namespace, c.Key)
+		return nil
+	}
+	fmt.Fprintf(c.out, "metafield deleted: %s.%s\n", c.Namespace, c.Key)
+	return nil
+}
+
+func (c *MetaSyncCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	opts := order.SyncOptions{Delete: c.Delete, DryRun: c.DryRun}
+	changes, err := order.SyncMetafields(c.orderClient(), metaResource(c.Resource), c.ID, c.Metafields, opts)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		fmt.Fprintf(c.out, "%s: %s.%s\n", change.Action, change.Metafield.Namespace, change.Metafield.Key)
+	}
+	return nil
+}
+
+// metaResource returns the REST API resource name for the resource flag
+// value of metafield commands.
+func metaResource(resource string) string {
+	switch resource {
+	case "customer":
+		return order.ResourceCustomers
+	case "variant":
+		return order.ResourceVariants
+	default:
+		return order.ResourceOrders
+	}
+}
+
 func (c *TransactionsCmd) Run() error {
-	transactions, err := order.Transactions(c.client, c.ID)
+	transactions, err := order.Transactions(c.orderClient(), c.ID)
 	if err != nil {
 		return err
 	}
 	return json.NewEncoder(c.out).Encode(transactions)
 }
 
+func (c *AddTxCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	orderID, err := c.orderID(c.ID, c.Name)
+	if err != nil {
+		return err
+	}
+	transactions, err := order.AddTransactions(c.orderClient(), orderID, c.Transactions)
+	for _, t := range transactions {
+		fmt.Fprintf(c.out, "transaction added, ID: %d, kind: %s, amount: %s\n", t.ID, t.Kind, t.Amount)
+	}
+	return err
+}
+
 func (c *VariantGetCmd) Run() error {
 	id := c.ID
 	if id == 0 {
 		var err error
-		id, err = order.GetVariantIDBySKU(c.client, c.SKU, c.IncludeInventory)
+		id, err = order.GetVariantIDBySKU(c.orderClient(), c.SKU, c.IncludeInventory)
 		if err != nil {
 			return err
 		}
 	}
-	variant, err := c.client.Variant.Get(id, nil)
+	variant, err := c.orderClient().GetVariant(id)
 	if err != nil {
 		return err
 	}
@@ -292,303 +850,1361 @@ func (c *VariantCreateCmd) Run() error {
 	if !whitelist[c.Store] {
 		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
 	}
-	variant, err := c.client.Variant.Create(c.Variant.ProductID, *c.Variant)
+	variant, err := c.orderClient().CreateVariant(c.Variant.ProductID, *c.Variant)
 	if err != nil {
 		return err
 	}
 	return json.NewEncoder(c.out).Encode(variant)
 }
 
-func (c *InventoryGetCmd) Run() error {
-	levels, err := order.GetIventoryLevels(c.client, c.InventoryItemID, c.VariantID)
+func (c *VariantListCmd) Run() error {
+	variants, err := c.orderClient().ListVariants(c.Product)
 	if err != nil {
 		return err
 	}
-	return json.NewEncoder(c.out).Encode(levels)
+	enc := json.NewEncoder(c.out)
+	for _, v := range variants {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (c *InventoryAdjustCmd) Run() error {
+func (c *VariantUpdateCmd) Run() error {
 	if !whitelist[c.Store] {
 		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
 	}
-	resp, err := order.AdjustIventoryLevel(c.client, c.LocationID, c.InventoryItemID, c.VariantID, c.Amount)
+	variant, err := c.orderClient().UpdateVariant(*c.Variant)
 	if err != nil {
 		return err
 	}
-	return json.NewEncoder(c.out).Encode(resp)
+	return json.NewEncoder(c.out).Encode(variant)
 }
 
-func (c *CustomerGetCmd) Run() error {
-	customer, err := c.client.Customer.Get(c.ID, nil)
+func (c *VariantDeleteCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	productID := c.Product
+	if productID == 0 {
+		variant, err := c.orderClient().GetVariant(c.ID)
+		if err != nil {
+			return err
+		}
+		productID = variant.ProductID
+	}
+	if err := c.orderClient().DeleteVariant(productID, c.ID); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "variant deleted, ID:", c.ID)
+	return nil
+}
+
+func (c *ProductGetCmd) Run() error {
+	product, err := c.orderClient().GetProduct(c.ID)
 	if err != nil {
 		return err
 	}
-	return json.NewEncoder(c.out).Encode(customer)
+	return json.NewEncoder(c.out).Encode(product)
 }
 
-func (c *CustomerUpdateCmd) Run() error {
+func (c *ProductListCmd) Run() error {
+	opts := goshopify.ProductListOptions{Handle: c.Handle, Vendor: c.Vendor, ProductType: c.ProductType}
+	products, err := order.ListProducts(c.orderClient(), opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "number of products:", len(products))
+	for _, p := range products {
+		fmt.Fprintf(c.out, "id: %d title: %s, handle: %s, variants: %d\n", p.ID, p.Title, p.Handle, len(p.Variants))
+	}
+	return nil
+}
+
+func (c *ProductCreateCmd) Run() error {
 	if !whitelist[c.Store] {
 		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
 	}
-	customer, err := c.client.Customer.Update(*c.Customer)
+	product, err := c.orderClient().CreateProduct(*c.Product)
 	if err != nil {
 		return err
 	}
-	fmt.Fprintln(c.out, "customer updated, ID:", customer.ID)
+	fmt.Fprintln(c.out, "product created, ID:", product.ID)
 	return nil
 }
 
-func (c *CustomerMergeCmd) Run() error {
+func (c *ProductUpdateCmd) Run() error {
 	if !whitelist[c.Store] {
 		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
 	}
-	customer, err := order.CustomerMerge(c.client, c.Customer)
+	product, err := c.orderClient().UpdateProduct(*c.Product)
 	if err != nil {
 		return err
 	}
-	fmt.Fprintln(c.out, "customer merged, ID:", customer.ID)
+	fmt.Fprintln(c.out, "product updated, ID:", product.ID)
 	return nil
 }
 
-func (c *CustomerDeleteCmd) Run() error {
+func (c *ProductDeleteCmd) Run() error {
 	if !whitelist[c.Store] {
 		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
 	}
-	id := c.ID
-	if id == 0 {
-		email := c.Email
-		if email == "" {
-			email = c.Customer.Email
-		}
-		customers, err := order.CustomerListByEmail(c.client, email)
-		if err != nil {
-			return err
-		}
-		if len(customers) == 0 {
-			return nil
-		}
-		if len(customers) > 1 {
-			return fmt.Errorf("more than one customer found with email %q", email)
-		}
-		id = customers[0].ID
-	}
-	err := c.client.Customer.Delete(id)
-	if err != nil {
+	if err := c.orderClient().DeleteProduct(c.ID); err != nil {
 		return err
 	}
-	fmt.Fprintln(c.out, "customer deleted, ID:", id)
+	fmt.Fprintln(c.out, "product deleted, ID:", c.ID)
 	return nil
 }
 
-func (c *CustomerBatchDeleteCmd) Run() error {
+func (c *ProductImportCmd) Run() error {
 	if !whitelist[c.Store] {
 		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
 	}
-	customers, err := c.client.Customer.List(nil)
+	format := c.Format
+	if format == "auto" {
+		format = strings.TrimPrefix(filepath.Ext(c.File), ".")
+	}
+	f, err := os.Open(c.File)
 	if err != nil {
 		return err
 	}
-	cnt := c.Max
-	if len(customers) < cnt {
-		cnt = len(customers)
-	}
-	fmt.Fprintf(c.out, "deleting %d customers\n", cnt)
+	defer f.Close()
+	products, err := order.ReadProducts(f, format)
+	if err != nil {
+		return err
+	}
+	locationID, err := c.locationID(c.LocationID)
+	if err != nil {
+		return err
+	}
+	opts := order.ProductImportOptions{LocationID: locationID, DryRun: c.DryRun}
+	report, err := order.ImportProductsContext(c.commandContext(), c.newOrderClient, products, opts)
+	if report != nil {
+		for _, r := range report.Results {
+			fmt.Fprintf(c.out, "product %q: %s product %d with %d variants %s\n", r.Handle, r.Action, r.ProductID, r.Variants, r.Reason)
+		}
+		fmt.Fprintf(c.out, "created: %d, skipped: %d\n", report.Created, report.Skipped)
+	}
+	return err
+}
+
+func (c *InventoryGetCmd) Run() error {
+	levels, err := order.GetIventoryLevels(c.orderClient(), c.InventoryItemID, c.VariantID)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(c.out).Encode(levels)
+}
+
+func (c *InventoryAdjustCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	locationID, err := c.locationID(c.LocationID)
+	if err != nil {
+		return err
+	}
+	resp, err := order.AdjustIventoryLevel(c.orderClient(), locationID, c.InventoryItemID, c.VariantID, c.Amount)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(c.out).Encode(resp)
+}
+
+func (c *InventoryListCmd) Run() error {
+	locationID, err := c.locationID(c.Location)
+	if err != nil {
+		return err
+	}
+	levels, err := order.ListLocationInventory(c.orderClient(), locationID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "number of inventory levels:", len(levels))
+	for _, l := range levels {
+		fmt.Fprintf(c.out, "inventory_item_id: %d variant_id: %d sku: %s, available: %d, title: %s\n", l.InventoryItemID, l.VariantID, l.SKU, l.Available, l.Title)
+	}
+	return nil
+}
+
+func (c *InventorySetCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	itemID, err := order.InventoryItemID(c.orderClient(), c.InventoryItemID, c.VariantID)
+	if err != nil {
+		return err
+	}
+	locationID, err := c.locationID(c.LocationID)
+	if err != nil {
+		return err
+	}
+	resp, err := order.SetInventoryLevel(c.orderClient(), locationID, itemID, c.Available)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(c.out).Encode(resp)
+}
+
+func (c *InventoryTransferCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	itemID, err := order.InventoryItemID(c.orderClient(), c.InventoryItemID, c.VariantID)
+	if err != nil {
+		return err
+	}
+	from, err := c.locationID(c.FromLocation)
+	if err != nil {
+		return err
+	}
+	to, err := c.locationID(c.ToLocation)
+	if err != nil {
+		return err
+	}
+	resp, err := order.TransferInventory(c.orderClient(), itemID, from, to, c.Quantity)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(c.out).Encode(resp)
+}
+
+func (c *InventoryConnectCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	itemID, err := order.InventoryItemID(c.orderClient(), c.InventoryItemID, c.VariantID)
+	if err != nil {
+		return err
+	}
+	locationID, err := c.locationID(c.LocationID)
+	if err != nil {
+		return err
+	}
+	resp, err := order.ConnectInventoryLevel(c.orderClient(), locationID, itemID)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(c.out).Encode(resp)
+}
+
+func (c *InventoryDisconnectCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	itemID, err := order.InventoryItemID(c.orderClient(), c.InventoryItemID, c.VariantID)
+	if err != nil {
+		return err
+	}
+	locationID, err := c.locationID(c.LocationID)
+	if err != nil {
+		return err
+	}
+	if err := order.DisconnectInventoryLevel(c.orderClient(), locationID, itemID); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "inventory disconnected, inventory item ID: %d, location ID: %d\n", itemID, locationID)
+	return nil
+}
+
+func (c *InventorySyncCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	f, err := os.Open(c.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	records, err := order.ReadStock(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.File, err)
+	}
+	changes, err := order.PlanInventorySync(c.orderClient(), records)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.File, err)
+	}
+	changed := 0
+	for _, change := range changes {
+		if change.Delta() != 0 {
+			changed++
+			fmt.Fprintf(c.out, "sku %s at location %d: %d -> %d (%+d)\n", change.SKU, change.LocationID, change.Current, change.Quantity, change.Delta())
+		}
+	}
+	fmt.Fprintf(c.out, "changes: %d of %d items\n", changed, len(changes))
+	threshold := order.InventoryThreshold{MaxChangedPercent: c.MaxChangedPercent, MaxChange: c.MaxChange}
+	if err := threshold.Check(changes); err != nil && !c.Force {
+		return fmt.Errorf("%w, use --force to apply anyway", err)
+	}
+	if c.DryRun {
+		return nil
+	}
+	applied, err := order.ApplyInventorySyncContext(c.commandContext(), c.newOrderClient, changes, c.BatchSize)
+	fmt.Fprintf(c.out, "applied: %d\n", applied)
+	return err
+}
+
+func (c *LocationListCmd) Run() error {
+	locations, err := order.ListLocations(c.orderClient())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "number of locations:", len(locations))
+	for _, l := range locations {
+		a := l.Address
+		address := []string{}
+		for _, f := range []string{a.Address1, a.Address2, a.City, a.Province, a.Zip, a.Country} {
+			if f != "" {
+				address = append(address, f)
+			}
+		}
+		fmt.Fprintf(c.out, "id: %d name: %s, address: %s, active: %t, fulfills online orders: %t", l.ID, l.Name, strings.Join(address, ", "), l.Active, l.FulfillsOnlineOrders)
+		if l.FulfillmentService != "" {
+			fmt.Fprintf(c.out, ", fulfillment service: %s", l.FulfillmentService)
+		}
+		fmt.Fprintln(c.out)
+	}
+	return nil
+}
+
+func (c *LocationGetCmd) Run() error {
+	location, err := order.GetLocation(c.orderClient(), c.Location)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(c.out).Encode(location)
+}
+
+func (c *CustomerGetCmd) Run() error {
+	customer, err := c.orderClient().GetCustomer(c.ID)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(c.out).Encode(customer)
+}
+
+func (c *CustomerUpdateCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	customer, err := c.orderClient().UpdateCustomer(*c.Customer)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "customer updated, ID:", customer.ID)
+	return nil
+}
+
+func (c *CustomerMergeCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	customer, err := order.CustomerMerge(c.orderClient(), c.Customer)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "customer merged, ID:", customer.ID)
+	return nil
+}
+
+func (c *CustomerDeleteCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	id := c.ID
+	if id == 0 {
+		email := c.Email
+		if email == "" {
+			email = c.Customer.Email
+		}
+		if email == "" {
+			return fmt.Errorf("email is empty")
+		}
+		customers, err := order.FindCustomers(c.orderClient(), email, "")
+		if err != nil {
+			return err
+		}
+		if len(customers) == 0 {
+			return nil
+		}
+		if len(customers) > 1 {
+			return fmt.Errorf("more than one customer found with email %q", email)
+		}
+		id = customers[0].ID
+	}
+	result, err := order.DeleteCustomer(c.orderClient(), id, c.options())
+	if err != nil {
+		return err
+	}
+	c.print(c.out, result)
+	if result.Action == order.CustomerKept {
+		return fmt.Errorf("customer %d not deleted: %s", id, result.Reason)
+	}
+	return nil
+}
+
+func (c *CustomerBatchDeleteCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	customers, err := c.orderClient().ListCustomers()
+	if err != nil {
+		return err
+	}
+	cnt := len(customers)
+	if c.Max >= 0 && c.Max < cnt {
+		cnt = c.Max
+	}
+	fmt.Fprintf(c.out, "deleting %d customers\n", cnt)
+	summary := map[string]int{}
+	failed := 0
+	printSummary := func() {
+		fmt.Fprintf(c.out, "customers deleted: %d, redacted: %d, kept: %d, failed: %d\n", summary[order.CustomerDeleted], summary[order.CustomerRedacted], summary[order.CustomerKept], failed)
+	}
+	ctx := c.commandContext()
+	for i := 0; i < cnt; i++ {
+		if err := ctx.Err(); err != nil {
+			fmt.Fprintf(c.out, "customers deleted: %d of %d\n", i, cnt)
+			printSummary()
+			return err
+		}
+		var result *order.CustomerDeleteResult
+		err := order.RunOperation(ctx, c.withContext, func(op *Config) error {
+			var err error
+			result, err = order.DeleteCustomer(op.orderClient(), customers[i].ID, c.options())
+			return err
+		})
+		if err != nil {
+			// keep going, a single customer should not stop the batch
+			fmt.Fprintf(c.out, "customer %d failed: %v\n", customers[i].ID, err)
+			failed++
+			continue
+		}
+		c.print(c.out, result)
+		summary[result.Action]++
+	}
+	printSummary()
+	if failed != 0 {
+		return fmt.Errorf("customers failed: %d", failed)
+	}
+	return nil
+}
+
+func (f CustomerDeleteFlags) options() order.CustomerDeleteOptions {
+	return order.CustomerDeleteOptions{Cascade: f.Cascade, ReassignTo: f.ReassignTo, Redact: f.Redact, DryRun: f.DryRun}
+}
+
+func (f CustomerDeleteFlags) print(w io.Writer, r *order.CustomerDeleteResult) {
+	prefix := ""
+	if f.DryRun {
+		prefix = "dry run: "
+	}
+	switch {
+	case r.Action == order.CustomerKept:
+		fmt.Fprintf(w, "%scustomer kept, ID: %d, orders: %v (%s)\n", prefix, r.CustomerID, r.Orders, r.Reason)
+	case r.OrderAction != "":
+		fmt.Fprintf(w, "%scustomer %s, ID: %d, orders %s: %v\n", prefix, r.Action, r.CustomerID, r.OrderAction, r.Orders)
+	default:
+		fmt.Fprintf(w, "%scustomer %s, ID: %d\n", prefix, r.Action, r.CustomerID)
+	}
+}
+
+func (c *CustomerListCmd) Run() error {
+	var customers []goshopify.Customer
+	var err error
+	if c.Email != "" {
+		customers, err = order.FindCustomers(c.orderClient(), c.Email, "")
+	} else {
+		var phone string
+		if phone, err = order.NormalisePhone(c.Phone, c.PhoneRegion); err != nil {
+			return err
+		}
+		customers, err = order.FindCustomers(c.orderClient(), "", phone)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "number of customers:", len(customers))
+	for _, customer := range customers {
+		fmt.Fprintf(c.out, "id: %d name: %s %s, email: %s, phone: %s\n", customer.ID, customer.FirstName, customer.LastName, customer.Email, customer.Phone)
+	}
+	return nil
+}
+
+func (c *CustomerCreateCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	customer, err := c.orderClient().CreateCustomer(*c.Customer)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "customer created, ID:", customer.ID)
+	return nil
+}
+
+func (c *CustomerImportCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	format := c.Format
+	if format == "auto" {
+		format = strings.TrimPrefix(filepath.Ext(c.File), ".")
+	}
+	f, err := os.Open(c.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	records, err := order.ReadCustomers(f, format)
+	if err != nil {
+		return err
+	}
+	for i := range records {
+		changes, err := order.NormaliseCustomerAddresses(&records[i].Customer, c.Addresses)
+		if err != nil {
+			return fmt.Errorf("customer record %d: %w", i+1, err)
+		}
+		for _, change := range changes {
+			fmt.Fprintf(c.out, "record %d: address corrected, %s: %q -> %q\n", i+1, change.Field, change.Old, change.New)
+		}
+	}
+	opts := order.CustomerImportOptions{
+		Keys:        c.Key,
+		ExternalID:  c.ExternalID,
+		DryRun:      c.DryRun,
+		PhoneRegion: c.PhoneRegion,
+		Bulk:        order.BulkOptions{PollInterval: c.PollInterval},
+	}
+	report, err := order.ImportCustomersContext(c.commandContext(), c.newOrderClient, records, opts)
+	if report != nil {
+		for _, r := range report.Results {
+			fmt.Fprintf(c.out, "records %v: %s customer %d %s\n", r.Records, r.Action, r.CustomerID, r.Reason)
+		}
+		fmt.Fprintf(c.out, "created: %d, updated: %d, skipped: %d, conflicted: %d\n", report.Created, report.Updated, report.Skipped, report.Conflicted)
+	}
+	return err
+}
+
+func (c *CustomerExportCmd) Run() error {
+	id := c.ID
+	if id == 0 {
+		customers, err := order.FindCustomers(c.orderClient(), c.Email, "")
+		if err != nil {
+			return err
+		}
+		if len(customers) != 1 {
+			return fmt.Errorf("expected one customer with email %q, found %d", c.Email, len(customers))
+		}
+		id = customers[0].ID
+	}
+	export, err := order.ExportCustomer(c.orderClient(), id)
+	if err != nil {
+		return err
+	}
+	output := c.Output
+	if output == "" {
+		output = fmt.Sprintf("customer-%d.zip", id)
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := export.WriteArchive(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "customer %d exported with %d orders to %s\n", id, len(export.Orders), output)
+	return nil
+}
+
+func (c *SeedApplyCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	fixtures, err := order.LoadFixtures(c.Dir)
+	if err != nil {
+		return err
+	}
+	lockfile := c.Lockfile
+	if lockfile == "" {
+		lockfile = filepath.Join(c.Dir, "seed.lock.json")
+	}
+	l, err := order.ReadLockfile(lockfile)
+	if err != nil {
+		return err
+	}
+	report, err := order.SeedContext(c.commandContext(), c.newOrderClient, fixtures, l.Refs(c.Store), order.SeedOptions{DryRun: c.DryRun})
+	if report != nil {
+		summary := map[string]int{}
+		for _, r := range report.Results {
+			fmt.Fprintf(c.out, "%s %q: %s, ID: %d\n", r.Kind, r.Ref, r.Action, r.ID)
+			summary[r.Action]++
+		}
+		fmt.Fprintf(c.out, "created: %d, found: %d, locked: %d\n", summary[order.SeedCreated], summary[order.SeedFound], summary[order.SeedLocked])
+	}
+	if c.DryRun {
+		return err
+	}
+	// record what has been seeded even if seeding failed part way
+	if werr := l.Write(lockfile); werr != nil && err == nil {
+		err = werr
+	}
+	return err
+}
+
+func (c *SeedResolveCmd) AfterApply() error {
+	c.out = os.Stdout
+	return nil
+}
+
+func (c *SeedResolveCmd) Run() error {
+	l, err := order.ReadLockfile(c.Lockfile)
+	if err != nil {
+		return err
+	}
+	refs, ok := l.Stores[c.Store]
+	if !ok {
+		return fmt.Errorf("lockfile %s has no refs for store %q", c.Lockfile, c.Store)
+	}
+	b, err := os.ReadFile(c.File)
+	if err != nil {
+		return err
+	}
+	resolved, err := order.ResolveRefs(b, refs)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.out, "%s\n", resolved)
+	return err
+}
+
+func (c *ListCmd) AfterApply(ctx context.Context) error {
+	if err := c.Config.AfterApply(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *ListCmd) OrderName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	if c.Order != nil {
+		return c.Order.Name
+	}
+	return ""
+}
+
+func (c *ListCmd) Run() error {
+	name := c.OrderName()
+	var lines []string
+	add := func(o goshopify.Order) error {
+		lines = append(lines, fmt.Sprintf("id: %d name: %s email: %s", o.ID, o.Name, o.Email))
+		return nil
+	}
+	if c.Bulk {
+		// keep only the listed fields rather than all orders
+		if err := order.BulkOrders(c.orderClient(), name, order.BulkOptions{PollInterval: 2 * time.Second}, add); err != nil {
+			return err
+		}
+	} else {
+		orders, err := order.List(c.orderClient(), name)
+		if err != nil {
+			return err
+		}
+		for _, o := range orders {
+			_ = add(o)
+		}
+	}
+	fmt.Fprintln(c.out, "number of orders:", len(lines))
+	for _, line := range lines {
+		fmt.Fprintln(c.out, line)
+	}
+	return nil
+}
+
+func (c *ExportCmd) Run() error {
+	var anonymiser *order.Anonymiser
+	if c.Anonymise {
+		var err error
+		if anonymiser, err = order.NewAnonymiser(c.Seed); err != nil {
+			return err
+		}
+	}
+	enc := json.NewEncoder(c.out)
+	write := func(o goshopify.Order) error {
+		if anonymiser != nil {
+			anonymiser.Order(&o)
+		}
+		return enc.Encode(o)
+	}
+	// stores can have many orders, write them as they are received
+	if c.Bulk {
+		return order.BulkOrders(c.orderClient(), c.Name, order.BulkOptions{PollInterval: c.PollInterval}, write)
+	}
+	return order.EachOrder(c.orderClient(), c.Name, write)
+}
+
+func (c *AnonymiseCmd) AfterApply() error {
+	c.in = os.Stdin
+	c.out = os.Stdout
+	return nil
+}
+
+func (c *AnonymiseCmd) Run() error {
+	anonymiser, err := order.NewAnonymiser(c.Seed)
+	if err != nil {
+		return err
+	}
+	in := c.in
+	if c.File != "" {
+		f, err := os.Open(c.File)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	dec := json.NewDecoder(in)
+	enc := json.NewEncoder(c.out)
+	for dec.More() {
+		if c.Resource == "customer" {
+			customer := &goshopify.Customer{}
+			if err := dec.Decode(customer); err != nil {
+				return err
+			}
+			anonymiser.Customer(customer)
+			err = enc.Encode(customer)
+		} else {
+			o := &goshopify.Order{}
+			if err := dec.Decode(o); err != nil {
+				return err
+			}
+			anonymiser.Order(o)
+			err = enc.Encode(o)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CheckStockCmd) Run() error {
+	f, err := os.Open(c.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	orders, err := order.ReadOrders(f, orderFileFormat(c.File))
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.File, err)
+	}
+	for i := range orders {
+		if err := c.resolveSKUs(&orders[i], c.ResolveSKU, c.UnknownSKU); err != nil {
+			return fmt.Errorf("order %q: %w", orders[i].Name, err)
+		}
+	}
+	shortfalls, err := order.CheckStock(c.orderClient(), orders)
+	if err != nil {
+		return err
+	}
+	for _, s := range shortfalls {
+		fmt.Fprintf(c.out, "shortfall: %v\n", s)
+	}
+	if len(shortfalls) != 0 {
+		return fmt.Errorf("insufficient inventory, shortfalls: %d", len(shortfalls))
+	}
+	fmt.Fprintf(c.out, "inventory available for %d orders\n", len(orders))
+	return nil
+}
+
+// orderFileFormat returns the format of an order file for order.ReadOrders
+// by its extension, defaulting to JSON.
+func orderFileFormat(filename string) string {
+	if strings.EqualFold(filepath.Ext(filename), ".csv") {
+		return "csv"
+	}
+	return "json"
+}
+
+func (c *DeleteCmd) OrderName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	if c.Order != nil {
+		return c.Order.Name
+	}
+	return ""
+}
+
+func (c *DeleteCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	if c.ID != 0 {
+		if err := order.DeleteByID(c.orderClient(), c.ID); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, "order deleted, ID:", c.ID)
+		return nil
+	}
+	opts := order.DeleteOptions{Unique: c.Unique, DryRun: true, Max: -1}
+	orderIDs, err := order.Delete(c.orderClient(), c.OrderName(), opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "number of orders to delete:", len(orderIDs))
+	return c.deleteOrders(orderIDs)
+}
 
-	for i := 0; i < cnt; i++ {
-		err := c.client.Customer.Delete(customers[i].ID)
+// deleteOrders deletes the orders with the given IDs, each as separate
+// operation, and stops before the next order if interrupted.
+func (c *Config) deleteOrders(orderIDs []int64) error {
+	for i, orderID := range orderIDs {
+		err := order.RunOperation(c.commandContext(), c.withContext, func(op *Config) error {
+			return order.DeleteByID(op.orderClient(), orderID)
+		})
+		if errors.Is(err, context.Canceled) {
+			fmt.Fprintf(c.out, "orders deleted: %d of %d\n", i, len(orderIDs))
+		}
 		if err != nil {
-			gerr := goshopify.ResponseError{}
-			if errors.As(err, &gerr) {
-				if gerr.GetStatus() == http.StatusUnprocessableEntity {
-					fmt.Printf("Cannot delete customer %d - maybe still used in order? Continuing\n", customers[i].ID)
-					continue
-				}
-			}
 			return err
 		}
-		fmt.Fprintln(c.out, "customer deleted, ID:", customers[i].ID)
+		fmt.Fprintln(c.out, "order deleted, ID:", orderID)
 	}
 	return nil
 }
 
-func (c *CustomerListCmd) Run() error {
-	var customers []goshopify.Customer
-	var err error
-	if c.Email != "" {
-		customers, err = order.CustomerListByEmail(c.client, c.Email)
-	} else {
-		customers, err = order.CustomerListByPhone(c.client, c.Email)
+func (c *BatchDeleteCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
 	}
+	opts := order.DeleteOptions{DryRun: true, Max: c.Max}
+	orderIDs, err := order.Delete(c.orderClient(), "", opts)
 	if err != nil {
 		return err
 	}
-	fmt.Fprintln(c.out, "number of customers:", len(customers))
-	for _, customer := range customers {
-		fmt.Fprintf(c.out, "id: %d name: %s %s, email: %s, phone: %s\n", customer.ID, customer.FirstName, customer.LastName, customer.Email, customer.Phone)
+	fmt.Fprintln(c.out, "number of orders to delete:", len(orderIDs))
+	return c.deleteOrders(orderIDs)
+}
+
+// options returns the order.CreateOptions set by f.
+func (f CreateFlags) options() order.CreateOptions {
+	return order.CreateOptions{
+		Unique:               f.Unique,
+		VerifyProduct:        f.VerifyProduct,
+		Inventory:            f.Inventory,
+		Fulfill:              f.Fulfill,
+		ValidateTransactions: f.ValidateTx,
+		Customer:             f.CustomerStrategy,
+		NormalisePhones:      f.NormalisePhones,
+		PhoneRegion:          f.PhoneRegion,
 	}
-	return nil
 }
 
-func (c *CustomerCreateCmd) Run() error {
+func (c *ReplaceCmd) Run() error {
 	if !whitelist[c.Store] {
 		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
 	}
-	customer, err := c.client.Customer.Create(*c.Customer)
-	if err != nil {
+	if err := c.resolveSKUs(c.Order, c.ResolveSKU, c.UnknownSKU); err != nil {
 		return err
 	}
-	fmt.Fprintln(c.out, "customer created, ID:", customer.ID)
-	return nil
+	if err := c.normaliseAddresses(c.Order, c.Addresses); err != nil {
+		return err
+	}
+	c.Transactions.Apply(c.Order)
+	o, err := order.Replace(c.orderClient(), c.Order, c.options())
+	if o != nil {
+		fmt.Fprintln(c.out, "order replaced, new ID:", o.ID)
+	}
+	return err
+}
+
+func (c *CreateCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	if err := c.resolveSKUs(c.Order, c.ResolveSKU, c.UnknownSKU); err != nil {
+		return err
+	}
+	if err := c.normaliseAddresses(c.Order, c.Addresses); err != nil {
+		return err
+	}
+	c.Transactions.Apply(c.Order)
+	o, err := order.Create(c.orderClient(), c.Order, c.options())
+	if o != nil {
+		fmt.Fprintln(c.out, "order created, ID:", o.ID)
+	}
+	return err
 }
 
-func (c *ListCmd) AfterApply() error {
-	if err := c.Config.AfterApply(); err != nil {
+func (c *UpdateCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
+	}
+	o, err := order.Update(c.orderClient(), c.Order)
+	if err != nil {
 		return err
 	}
+	fmt.Fprintln(c.out, "order updated, ID:", o.ID)
 	return nil
 }
 
-func (c *ListCmd) OrderName() string {
-	if c.Name != "" {
-		return c.Name
+func (c *MergeCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
 	}
-	if c.Order != nil {
-		return c.Order.Name
+	result, err := c.merge(c.Order, c.MergeFlags)
+	if result != nil {
+		fmt.Fprintf(c.out, "order merged (%s), ID: %d\n", result.Label, result.OrderID)
 	}
-	return ""
+	return err
 }
 
-func (c *ListCmd) Run() error {
-	name := c.OrderName()
-	var orders []goshopify.Order
-	var err error
-	orders, err = order.List(c.client, name)
+// merge creates or updates o with the import options of flags.
+func (c *Config) merge(o *goshopify.Order, flags MergeFlags) (*order.MergeResult, error) {
+	if err := c.resolveSKUs(o, flags.ResolveSKU, flags.UnknownSKU); err != nil {
+		return nil, err
+	}
+	if err := c.normaliseAddresses(o, flags.Addresses); err != nil {
+		return nil, err
+	}
+	if flags.CheckStock {
+		if err := c.checkStock([]goshopify.Order{*o}, flags); err != nil {
+			return nil, err
+		}
+	}
+	opts := order.MergeOptions{
+		VerifyProduct:        flags.VerifyProduct,
+		Inventory:            flags.Inventory,
+		Fulfill:              flags.Fulfill,
+		ValidateTransactions: flags.ValidateTx,
+		Customer:             flags.CustomerStrategy,
+		NormalisePhones:      flags.NormalisePhones,
+		PhoneRegion:          flags.PhoneRegion,
+	}
+	flags.Transactions.Apply(o)
+	return order.Merge(c.orderClient(), o, opts)
+}
+
+// checkStock resolves the SKUs of orders and fails with the shortfalls
+// if inventory does not cover their line items, see order.CheckStock.
+func (c *Config) checkStock(orders []goshopify.Order, flags MergeFlags) error {
+	for i := range orders {
+		if err := c.resolveSKUs(&orders[i], flags.ResolveSKU, flags.UnknownSKU); err != nil {
+			return fmt.Errorf("order %q: %w", orders[i].Name, err)
+		}
+	}
+	shortfalls, err := order.CheckStock(c.orderClient(), orders)
 	if err != nil {
 		return err
 	}
-	fmt.Fprintln(c.out, "number of orders:", len(orders))
-	for _, o := range orders {
-		fmt.Fprintf(c.out, "id: %d name: %s email: %s\n", o.ID, o.Name, o.Email)
+	if len(shortfalls) != 0 {
+		return fmt.Errorf("insufficient inventory: %w", shortfalls)
 	}
 	return nil
 }
 
-func (c *DeleteCmd) OrderName() string {
-	if c.Name != "" {
-		return c.Name
+func (c *WatchCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
 	}
-	if c.Order != nil {
-		return c.Order.Name
+	ctx := c.commandContext()
+	flags := c.MergeFlags
+	// the stock of all orders of a file is checked before importing them
+	flags.CheckStock = false
+	watcher, err := order.NewFolderWatcher(c.Dir, func(o *goshopify.Order) (*order.MergeResult, error) {
+		opCtx, cancel := order.OperationContext(ctx)
+		defer cancel()
+		return c.withContext(opCtx).merge(o, flags)
+	})
+	if err != nil {
+		return err
+	}
+	if c.CheckStock {
+		watcher.Check = func(orders []goshopify.Order) error {
+			opCtx, cancel := order.OperationContext(ctx)
+			defer cancel()
+			return c.withContext(opCtx).checkStock(orders, flags)
+		}
+	}
+	watcher.MinAge = c.MinAge
+	for {
+		reports, err := watcher.PollContext(ctx)
+		for _, r := range reports {
+			fmt.Fprintf(c.out, "%s %s, orders: %d, failed: %d\n", r.File, r.Status, len(r.Orders), r.Failed())
+			if r.Error != "" {
+				fmt.Fprintf(c.out, "error: %s\n", r.Error)
+			}
+			for _, o := range r.Orders {
+				if o.Error != "" && o.OrderID != 0 {
+					fmt.Fprintf(c.out, "order %q merged (%s) but failed, ID: %d: %s\n", o.Name, o.Label, o.OrderID, o.Error)
+				} else if o.Error != "" {
+					fmt.Fprintf(c.out, "order %q failed: %s\n", o.Name, o.Error)
+				} else {
+					fmt.Fprintf(c.out, "order %q merged (%s), ID: %d\n", o.Name, o.Label, o.OrderID)
+				}
+			}
+		}
+		if errors.Is(err, context.Canceled) {
+			// files in progress are continued after restart
+			fmt.Fprintln(c.out, "watch stopped")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if c.Once {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(c.out, "watch stopped")
+			return nil
+		case <-time.After(c.Interval):
+		}
 	}
-	return ""
 }
 
-func (c *DeleteCmd) Run() error {
+func (c *FulfillCmd) Run() error {
 	if !whitelist[c.Store] {
 		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
 	}
-	if c.ID != 0 {
-		if err := order.DeleteByID(c.client, c.ID); err != nil {
-			return err
-		}
-		fmt.Fprintln(c.out, "order deleted, ID:", c.ID)
-		return nil
+	spec := goshopify.Fulfillment{}
+	if c.Fulfillment != nil {
+		spec = *c.Fulfillment
 	}
-	opts := order.DeleteOptions{Unique: c.Unique, DryRun: true, Max: -1}
-	orderIDs, err := order.Delete(c.client, c.OrderName(), opts)
+	id := c.ID
+	if id == 0 && c.Name == "" {
+		id = spec.OrderID
+	}
+	orderID, err := c.orderID(id, c.Name)
 	if err != nil {
 		return err
 	}
-	fmt.Fprintln(c.out, "number of orders to delete:", len(orderIDs))
-	for _, orderID := range orderIDs {
-		if err := order.DeleteByID(c.client, orderID); err != nil {
-			return err
-		}
-		fmt.Fprintln(c.out, "order deleted, ID:", orderID)
+	if c.TrackingCompany != "" {
+		spec.TrackingCompany = c.TrackingCompany
+	}
+	if c.TrackingNumber != "" {
+		spec.TrackingNumber = c.TrackingNumber
+	}
+	if c.TrackingURL != "" {
+		spec.TrackingUrl = c.TrackingURL
+	}
+	opts := order.FulfillOptions{NotifyCustomer: c.Notify}
+	fulfillments, err := order.Fulfill(c.orderClient(), orderID, []goshopify.Fulfillment{spec}, opts)
+	if err != nil {
+		return err
+	}
+	for _, f := range fulfillments {
+		fmt.Fprintf(c.out, "order fulfilled, ID: %d, fulfillment ID: %d, line items: %d\n", orderID, f.ID, len(f.LineItems))
 	}
 	return nil
 }
 
-func (c *BatchDeleteCmd) Run() error {
+func (c *RefundCmd) Run() error {
 	if !whitelist[c.Store] {
 		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
 	}
-	opts := order.DeleteOptions{DryRun: true, Max: c.Max}
-	orderIDs, err := order.Delete(c.client, "", opts)
+	orderID, err := c.orderID(c.ID, c.Name)
 	if err != nil {
 		return err
 	}
-	fmt.Fprintln(c.out, "number of orders to delete:", len(orderIDs))
-	for _, orderID := range orderIDs {
-		if err := order.DeleteByID(c.client, orderID); err != nil {
-			return err
+	locationID, err := c.locationID(c.LocationID)
+	if err != nil {
+		return err
+	}
+	opts := order.RefundOptions{
+		LineItems:      c.LineItems,
+		FullShipping:   c.Shipping,
+		ShippingAmount: c.ShippingAmount,
+		Amount:         c.Amount,
+		RestockType:    c.Restock,
+		LocationID:     locationID,
+		Note:           c.Note,
+		Notify:         c.Notify,
+		DryRun:         c.DryRun,
+	}
+	refund, err := order.CreateRefund(c.orderClient(), orderID, opts)
+	if err != nil {
+		return err
+	}
+	if c.DryRun {
+		return json.NewEncoder(c.out).Encode(refund)
+	}
+	total := decimal.Zero
+	for _, t := range refund.Transactions {
+		if t.Amount != nil {
+			total = total.Add(*t.Amount)
 		}
-		fmt.Fprintln(c.out, "order deleted, ID:", orderID)
 	}
+	fmt.Fprintf(c.out, "order refunded, ID: %d, refund ID: %d, amount: %s\n", orderID, refund.ID, total)
 	return nil
 }
 
-func (c *ReplaceCmd) Run() error {
+func (c *CancelCmd) Run() error {
 	if !whitelist[c.Store] {
 		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
 	}
-	opts := order.CreateOptions{
-		Unique:        c.Unique,
-		VerifyProduct: c.VerifyProduct,
-		Inventory:     c.Inventory,
-	}
-	o, err := order.Replace(c.client, c.Order, opts)
+	orderID, err := c.orderID(c.ID, c.Name)
 	if err != nil {
 		return err
 	}
-	fmt.Fprintln(c.out, "order replaced, new ID:", o.ID)
+	opts := order.CancelOptions{Reason: c.Reason, Restock: c.Restock, Notify: c.Notify}
+	if _, err := order.Cancel(c.orderClient(), orderID, opts); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "order cancelled, ID:", orderID)
 	return nil
 }
 
-func (c *CreateCmd) Run() error {
+func (c *CloseCmd) Run() error {
 	if !whitelist[c.Store] {
 		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
 	}
-	opts := order.CreateOptions{
-		Unique:        c.Unique,
-		VerifyProduct: c.VerifyProduct,
-		Inventory:     c.Inventory,
+	orderID, err := c.orderID(c.ID, c.Name)
+	if err != nil {
+		return err
+	}
+	if _, err := c.orderClient().CloseOrder(orderID); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "order closed, ID:", orderID)
+	return nil
+}
+
+func (c *ReopenCmd) Run() error {
+	if !whitelist[c.Store] {
+		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
 	}
-	o, err := order.Create(c.client, c.Order, opts)
+	orderID, err := c.orderID(c.ID, c.Name)
 	if err != nil {
 		return err
 	}
-	fmt.Fprintln(c.out, "order created, ID:", o.ID)
+	if _, err := c.orderClient().OpenOrder(orderID); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "order reopened, ID:", orderID)
 	return nil
 }
 
-func (c *UpdateCmd) Run() error {
+// orderID returns id if set or else looks up the ID of the order with the
+// given name.
+func (c *Config) orderID(id int64, name string) (int64, error) {
+	if id != 0 {
+		return id, nil
+	}
+	if name == "" {
+		return 0, fmt.Errorf("order ID or name required")
+	}
+	return order.IDByName(c.orderClient(), name)
+}
+
+// locationID returns the ID of the location given by ID or name, or 0 if
+// location is empty.
+func (c *Config) locationID(location string) (int64, error) {
+	if location == "" {
+		return 0, nil
+	}
+	return order.LocationID(c.orderClient(), location)
+}
+
+func (c *WebhookRegisterCmd) Run() error {
 	if !whitelist[c.Store] {
 		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
 	}
-	o, err := order.Update(c.client, c.Order)
+	for _, topic := range c.Topic {
+		existing, err := c.client.Webhook.List(goshopify.WebhookOptions{Address: c.Address, Topic: topic})
+		if err != nil {
+			return err
+		}
+		if len(existing) != 0 {
+			fmt.Fprintf(c.out, "webhook exists, ID: %d, topic: %s\n", existing[0].ID, topic)
+			continue
+		}
+		webhook, err := c.client.Webhook.Create(goshopify.Webhook{Address: c.Address, Topic: topic, Format: "json", Fields: c.Fields})
+		if err != nil {
+			return fmt.Errorf("topic %s: %w", topic, err)
+		}
+		fmt.Fprintf(c.out, "webhook registered, ID: %d, topic: %s\n", webhook.ID, topic)
+	}
+	return nil
+}
+
+func (c *WebhookListCmd) Run() error {
+	webhooks, err := c.client.Webhook.List(goshopify.WebhookOptions{Topic: c.Topic})
 	if err != nil {
 		return err
 	}
-	fmt.Fprintln(c.out, "order updated, ID:", o.ID)
+	fmt.Fprintln(c.out, "number of webhooks:", len(webhooks))
+	for _, w := range webhooks {
+		fmt.Fprintf(c.out, "id: %d topic: %s, address: %s\n", w.ID, w.Topic, w.Address)
+	}
 	return nil
 }
 
-func (c *MergeCmd) Run() error {
+func (c *WebhookDeleteCmd) Run() error {
 	if !whitelist[c.Store] {
 		return fmt.Errorf("write command for non whitelisted shop %q", c.Store)
 	}
-	opts := order.MergeOptions{VerifyProduct: c.VerifyProduct}
-	result, err := order.Merge(c.client, c.Order, opts)
+	if err := c.client.Webhook.Delete(c.ID); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "webhook deleted, ID:", c.ID)
+	return nil
+}
+
+func (c *WebhookSendCmd) AfterApply() error {
+	c.out = os.Stdout
+	c.client = &http.Client{Timeout: 30 * time.Second}
+	return nil
+}
+
+func (c *WebhookSendCmd) Run() error {
+	payload, err := os.ReadFile(c.File)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(order.WebhookTopicHeader, c.Topic)
+	req.Header.Set(order.WebhookShopHeader, c.Shop)
+	req.Header.Set(order.WebhookIDHeader, fmt.Sprintf("orderer-%d", time.Now().UnixNano()))
+	req.Header.Set(order.WebhookHMACHeader, order.SignWebhook(c.WebhookSecret, payload))
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("webhook rejected: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	fmt.Fprintf(c.out, "webhook sent, topic: %s\n", c.Topic)
+	return nil
+}
+
+func (c *ServeCmd) AfterApply(ctx context.Context) error {
+	c.out = os.Stdout
+	c.ctx = ctx
+	return nil
+}
+
+func (c *ServeCmd) Run() error {
+	server, err := c.server()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "serving API on %s\n", c.Addr)
+	return serveHTTP(c.ctx, c.Addr, server)
+}
+
+func (c *ServeCmd) server() (*api.Server, error) {
+	cfg, err := api.ReadConfig(c.APIConfig)
+	if err != nil {
+		return nil, err
+	}
+	server, err := api.NewServer(cfg, whitelist, func(ctx context.Context, p api.Profile) order.Client {
+		return order.NewShopifyClient(newClient(ctx, &Config{Store: p.Store, Token: p.Token, ShopifyLogs: c.ShopifyLogs}, true))
+	})
+	if err != nil {
+		return nil, err
+	}
+	server.Log = c.out
+	server.Timeout = c.Timeout
+	return server, nil
+}
+
+func (c *ServeWebhooksCmd) AfterApply(ctx context.Context) error {
+	c.out = os.Stdout
+	c.ctx = ctx
+	return nil
+}
+
+func (c *ServeWebhooksCmd) Run() error {
+	handler, closeOutput, err := c.handler()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+	fmt.Fprintf(c.out, "receiving webhooks on %s\n", c.Addr)
+	return serveHTTP(c.ctx, c.Addr, handler)
+}
+
+// serveHTTP serves handler on addr until ctx is done and then waits for
+// the requests in flight to complete.
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) error {
+	server := &http.Server{Addr: addr, Handler: handler}
+	errc := make(chan error, 1)
+	go func() { errc <- server.ListenAndServe() }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return server.Shutdown(context.Background())
+	}
+}
+
+// handler returns the webhook handler appending events to the output
+// file and running the exec command, and a function closing the output
+// file.
+func (c *ServeWebhooksCmd) handler() (http.Handler, func() error, error) {
+	output := c.Output
+	if output == "" && c.Exec == "" {
+		output = "webhooks.jsonl"
+	}
+	closeOutput := func() error { return nil }
+	var log *order.WebhookLog
+	if output != "" {
+		f, err := os.OpenFile(output, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, nil, err
+		}
+		closeOutput = f.Close
+		log = order.NewWebhookLog(f)
+	}
+	handle := func(event *order.WebhookEvent) error {
+		if log != nil {
+			if err := log.Append(event); err != nil {
+				return err
+			}
+		}
+		if c.Exec != "" {
+			if err := execWebhookCommand(c.Exec, event); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(c.out, "webhook received, topic: %s, ID: %s\n", event.Topic, event.ID)
+		return nil
+	}
+	return order.NewWebhookHandler(c.WebhookSecret, handle), closeOutput, nil
+}
+
+func execWebhookCommand(command string, event *order.WebhookEvent) error {
+	b, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
-	fmt.Fprintf(c.out, "order merged (%s), ID: %d\n", result.Label, result.OrderID)
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Env = append(os.Environ(), "WEBHOOK_TOPIC="+event.Topic, "WEBHOOK_ID="+event.ID)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", command, err, strings.TrimSpace(string(out)))
+	}
 	return nil
 }
 
 func (c *ScopesCmd) Run() error {
-	c.client = newClient(&c.Config, false)
+	c.client = newClient(c.commandContext(), &c.Config, false)
 
 	resource := goshopify.AccessScopesResource{}
 	err := c.client.CreateAndDo("GET", "oauth/access_scopes.json", nil, nil, &resource)
@@ -605,6 +2221,37 @@ func (c *ScopesCmd) Run() error {
 	return nil
 }
 
+// resolveSKUs sets missing variant IDs of order line items from their SKUs
+// and reports line items that could not be resolved. Lookups are cached
+// for the whole run.
+func (c *Config) resolveSKUs(o *goshopify.Order, resolve bool, policy order.SKUPolicy) error {
+	if !resolve {
+		return nil
+	}
+	if c.skuResolver == nil {
+		c.skuResolver = order.NewSKUResolver(c.orderClient(), policy)
+	}
+	c.skuResolver.Policy = policy
+	skuErrs, err := c.skuResolver.Resolve(o)
+	if err != nil {
+		return err
+	}
+	for _, skuErr := range skuErrs {
+		fmt.Fprintf(c.out, "%v (%s)\n", skuErr, policy)
+	}
+	return nil
+}
+
+func (c *Config) normaliseAddresses(o *goshopify.Order, policy order.AddressPolicy) error {
+	changes, err := order.NormaliseOrderAddresses(o, policy)
+	if policy == order.AddressPolicyFix {
+		for _, change := range changes {
+			fmt.Fprintf(c.out, "address corrected, %s: %q -> %q\n", change.Field, change.Old, change.New)
+		}
+	}
+	return err
+}
+
 var JSONFileMapper = kong.MapperFunc(decodeJSONFile)
 
 func decodeJSONFile(ctx *kong.DecodeContext, target reflect.Value) error {
@@ -2,15 +2,24 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/OfficiallyEQL/orderer/fake"
+	"github.com/OfficiallyEQL/orderer/order"
+	"github.com/alecthomas/kong"
 	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
 )
 
@@ -26,7 +35,7 @@ func TestRun(t *testing.T) {
 	require.NoError(t, deleteCmd.Run())
 
 	got.Reset()
-	createCmd := CreateCmd{Config: *cfg, Order: order, VerifyProduct: true}
+	createCmd := CreateCmd{Config: *cfg, Order: order, CreateFlags: CreateFlags{VerifyProduct: true}}
 	require.NoError(t, createCmd.Run())
 	want := "order created, ID: "
 	gotStr := got.String()
@@ -70,7 +79,7 @@ func TestRun(t *testing.T) {
 	require.Error(t, createCmd.Run())
 	deleteCmd.Unique = true
 	require.Error(t, deleteCmd.Run())
-	mergeCmd.Unique = true
+	// merge always asserts that the order name is used at most once
 	require.Error(t, mergeCmd.Run())
 
 	got.Reset()
@@ -116,3 +125,508 @@ func testOrder(t *testing.T, fname string) *goshopify.Order {
 	require.NoError(t, json.NewDecoder(f).Decode(o))
 	return o
 }
+
+func TestExport(t *testing.T) {
+	o := testOrder(t, "testdata/order.json")
+	o.ID = 1
+	srv := fake.NewServer(*o)
+	defer srv.Close()
+	got := &bytes.Buffer{}
+	cfg := Config{Store: "fake", out: got, client: srv.Client()}
+
+	exportCmd := ExportCmd{Config: cfg, PollInterval: time.Millisecond}
+	require.NoError(t, exportCmd.Run())
+	rest := got.String()
+	require.Contains(t, rest, `"name":"order1"`)
+
+	got.Reset()
+	exportCmd.Bulk = true
+	require.NoError(t, exportCmd.Run())
+	require.Contains(t, got.String(), `"name":"order1"`)
+	require.Contains(t, got.String(), `"variant_id":43434424271066`)
+
+	got.Reset()
+	exportCmd = ExportCmd{Config: cfg, Anonymise: true, Seed: "secret"}
+	require.NoError(t, exportCmd.Run())
+	require.Contains(t, got.String(), `"name":"order1"`)
+	require.NotContains(t, got.String(), o.Email)
+	exported := &goshopify.Order{}
+	require.NoError(t, json.Unmarshal(got.Bytes(), exported))
+
+	got.Reset()
+	orders, err := json.Marshal(o)
+	require.NoError(t, err)
+	anonymiseCmd := AnonymiseCmd{Seed: "secret", in: bytes.NewReader(orders), out: got}
+	require.NoError(t, anonymiseCmd.Run())
+	anonymised := &goshopify.Order{}
+	require.NoError(t, json.Unmarshal(got.Bytes(), anonymised))
+	require.NotEmpty(t, anonymised.Email)
+	require.Equal(t, exported.Email, anonymised.Email)
+}
+
+func TestReplaceCmd(t *testing.T) {
+	srv := fake.NewServer(goshopify.Order{ID: 1, Name: "order1", Email: "jay@example.com"})
+	defer srv.Close()
+	got := &bytes.Buffer{}
+	cfg := Config{Store: "eql-dev", out: got, client: srv.Client()}
+
+	cmd := ReplaceCmd{Config: cfg, Order: &goshopify.Order{Name: "order1", Email: "mary@example.com"}}
+	require.NoError(t, cmd.Run())
+	orders := srv.Orders()
+	require.Len(t, orders, 1, "existing order must be deleted")
+	require.NotEqual(t, int64(1), orders[0].ID)
+	require.Equal(t, "mary@example.com", orders[0].Email)
+	require.Equal(t, fmt.Sprintf("order replaced, new ID: %d\n", orders[0].ID), got.String())
+}
+
+func TestRefundCmd(t *testing.T) {
+	total := decimal.NewFromInt(10)
+	srv := fake.NewServer(goshopify.Order{
+		ID:           1,
+		Name:         "order1",
+		LineItems:    []goshopify.LineItem{{ID: 11, Quantity: 1, Price: &total}},
+		Transactions: []goshopify.Transaction{{ID: 21, Kind: "sale", Status: "success", Amount: &total}},
+	})
+	defer srv.Close()
+	got := &bytes.Buffer{}
+	cfg := Config{Store: "eql-dev", out: got, client: srv.Client()}
+
+	cli := &CLI{}
+	parser, err := kong.New(cli, kongOpts...)
+	require.NoError(t, err)
+	_, err = parser.Parse([]string{"refund", "--store", "eql-dev", "--token", "t", "--name", "order1", "--line-item", "11=1", "--amount", "7.5"})
+	require.NoError(t, err)
+	cmd := &cli.Refund
+	cmd.Config = cfg
+	require.NoError(t, cmd.Run())
+	require.Regexp(t, `^order refunded, ID: 1, refund ID: \d+, amount: 7.5\n$`, got.String())
+}
+
+func TestAddTxCmd(t *testing.T) {
+	srv := fake.NewServer(goshopify.Order{ID: 1, Name: "order1"})
+	defer srv.Close()
+	got := &bytes.Buffer{}
+
+	cli := &CLI{}
+	parser, err := kong.New(cli, kongOpts...)
+	require.NoError(t, err)
+	_, err = parser.Parse([]string{"add-transactions", "--store", "eql-dev", "--token", "t", "--id", "1", "testdata/payments.json"})
+	require.NoError(t, err)
+	cmd := &cli.AddTx
+	cmd.out = got
+	cmd.client = srv.Client()
+	require.NoError(t, cmd.Run())
+	require.Regexp(t, `^transaction added, ID: \d+, kind: sale, amount: 100\ntransaction added, ID: \d+, kind: sale, amount: 49.95\n$`, got.String())
+	require.Len(t, srv.Orders()[0].Transactions, 2)
+}
+
+func TestCustomerImportCmd(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	srv.AddCustomers(goshopify.Customer{ID: 1, Email: "jo@example.com", FirstName: "Jo", LastName: "Bloggs"})
+	got := &bytes.Buffer{}
+	cfg := Config{Store: "eql-dev", out: got, client: srv.Client(), transport: srv.Client().Client.Transport}
+
+	cmd := CustomerImportCmd{Config: cfg, File: "testdata/customers.csv", Format: "auto", Key: []string{"email"}}
+	require.NoError(t, cmd.Run())
+	require.Contains(t, got.String(), "created: 1, updated: 1, skipped: 0, conflicted: 0\n")
+	customers := srv.Customers()
+	require.Len(t, customers, 2)
+	require.Equal(t, "newsletter, vip", customers[1].Tags)
+	require.Len(t, customers[1].Addresses, 2)
+}
+
+func TestCustomerExportCmd(t *testing.T) {
+	srv := fake.NewServer(goshopify.Order{ID: 11, Name: "#11", Customer: &goshopify.Customer{ID: 1}})
+	defer srv.Close()
+	srv.AddCustomers(goshopify.Customer{ID: 1, Email: "jo@example.com"})
+	got := &bytes.Buffer{}
+	output := filepath.Join(t.TempDir(), "jo.zip")
+	cmd := CustomerExportCmd{Config: Config{Store: "eql-dev", out: got, client: srv.Client()}, Email: "JO@example.com", Output: output}
+	require.NoError(t, cmd.Run())
+	require.Equal(t, "customer 1 exported with 1 orders to "+output+"\n", got.String())
+	require.FileExists(t, output)
+
+	cmd.Email = "nobody@example.com"
+	require.Error(t, cmd.Run())
+}
+
+// failTransport answers requests matching fail with 500 Internal Server
+// Error.
+type failTransport struct {
+	base http.RoundTripper
+	fail func(r *http.Request) bool
+}
+
+func (t failTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.fail(r) {
+		return &http.Response{StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error", Body: io.NopCloser(strings.NewReader("{}")), Header: http.Header{}, Request: r}, nil
+	}
+	return t.base.RoundTrip(r)
+}
+
+// hookTransport calls after for each request sent.
+type hookTransport struct {
+	base  http.RoundTripper
+	after func(r *http.Request)
+}
+
+func (t hookTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(r)
+	t.after(r)
+	return resp, err
+}
+
+func TestCustomerDeleteCmd(t *testing.T) {
+	srv := fake.NewServer(goshopify.Order{ID: 11, Name: "#11", Customer: &goshopify.Customer{ID: 2}})
+	defer srv.Close()
+	srv.AddCustomers(goshopify.Customer{ID: 1, Email: "jo@example.com"}, goshopify.Customer{ID: 2, Email: "jo@example.com.au"})
+	got := &bytes.Buffer{}
+	cfg := Config{Store: "eql-dev", out: got, client: srv.Client()}
+
+	// the search also matches jo@example.com.au, which must be kept
+	listCmd := CustomerListCmd{Config: cfg, Email: "jo@example.com"}
+	require.NoError(t, listCmd.Run())
+	require.Equal(t, "number of customers: 1\nid: 1 name:  , email: jo@example.com, phone: \n", got.String())
+
+	got.Reset()
+	cmd := CustomerDeleteCmd{Config: cfg, Email: "jo@example.com", CustomerDeleteFlags: CustomerDeleteFlags{Cascade: true}}
+	require.NoError(t, cmd.Run())
+	require.Equal(t, "customer deleted, ID: 1\n", got.String())
+	customers := srv.Customers()
+	require.Len(t, customers, 1)
+	require.Equal(t, int64(2), customers[0].ID)
+	require.Len(t, srv.Orders(), 1)
+}
+
+func TestCustomerBatchDeleteCmd(t *testing.T) {
+	srv := fake.NewServer(goshopify.Order{ID: 11, Name: "#11", Customer: &goshopify.Customer{ID: 3}})
+	defer srv.Close()
+	srv.AddCustomers(goshopify.Customer{ID: 1}, goshopify.Customer{ID: 2}, goshopify.Customer{ID: 3})
+	transport := failTransport{base: srv.Client().Client.Transport, fail: func(r *http.Request) bool {
+		return r.Method == http.MethodDelete && strings.HasSuffix(r.URL.Path, "/customers/2.json")
+	}}
+	got := &bytes.Buffer{}
+	cmd := CustomerBatchDeleteCmd{Config: Config{Store: "eql-dev", out: got, client: srv.Client(), transport: transport}, Max: -1}
+	require.EqualError(t, cmd.Run(), "customers failed: 1")
+	require.Contains(t, got.String(), "customer 2 failed: ")
+	require.Contains(t, got.String(), "customers deleted: 1, redacted: 0, kept: 1, failed: 1\n")
+	require.Len(t, srv.Customers(), 2)
+}
+
+func TestCustomerBatchDeleteCmdInterrupted(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	srv.AddCustomers(goshopify.Customer{ID: 1}, goshopify.Customer{ID: 2})
+	got := &bytes.Buffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	transport := hookTransport{base: srv.Client().Client.Transport, after: func(r *http.Request) {
+		if r.Method == http.MethodDelete {
+			cancel()
+		}
+	}}
+	cfg := Config{Store: "eql-dev", out: got, client: srv.Client(), transport: transport, ctx: ctx}
+
+	cmd := CustomerBatchDeleteCmd{Config: cfg, Max: -1}
+	require.ErrorIs(t, cmd.Run(), context.Canceled)
+	want := `deleting 2 customers
+customer deleted, ID: 1
+customers deleted: 1 of 2
+customers deleted: 1, redacted: 0, kept: 0, failed: 0
+`
+	require.Equal(t, want, got.String())
+	require.Len(t, srv.Customers(), 1)
+}
+
+func TestProductImportCmd(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	got := &bytes.Buffer{}
+	cfg := Config{Store: "eql-dev", out: got, client: srv.Client(), transport: srv.Client().Client.Transport}
+
+	cmd := ProductImportCmd{Config: cfg, File: "testdata/products.json", Format: "auto"}
+	require.NoError(t, cmd.Run())
+	require.Contains(t, got.String(), "created: 1, skipped: 0\n")
+	products := srv.Products()
+	require.Len(t, products, 1)
+	require.Equal(t, 3, srv.Inventory(products[0].Variants[0].InventoryItemId, srv.LocationID))
+
+	got.Reset()
+	deleteCmd := VariantDeleteCmd{Config: cfg, ID: products[0].Variants[1].ID}
+	require.NoError(t, deleteCmd.Run())
+	require.Len(t, srv.Products()[0].Variants, 1)
+
+	got.Reset()
+	listCmd := ProductListCmd{Config: cfg, Handle: "trail-runner"}
+	require.NoError(t, listCmd.Run())
+	require.Contains(t, got.String(), "number of products: 1\n")
+}
+
+func TestInventoryCmd(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	srv.AddProducts(goshopify.Product{ID: 1, Title: "Tee", Variants: []goshopify.Variant{{ID: 2, ProductID: 1, InventoryItemId: 3, Title: "M", Sku: "TEE-M"}}})
+	got := &bytes.Buffer{}
+	cfg := Config{Store: "eql-dev", out: got, client: srv.Client()}
+
+	setCmd := InventorySetCmd{Config: cfg, VariantID: 2, LocationID: "1", Available: 8}
+	require.NoError(t, setCmd.Run())
+	require.Equal(t, 8, srv.Inventory(3, 1))
+
+	transferCmd := InventoryTransferCmd{Config: cfg, VariantID: 2, FromLocation: "Shop location", ToLocation: "2", Quantity: 3}
+	require.NoError(t, transferCmd.Run())
+	require.Equal(t, 5, srv.Inventory(3, 1))
+	require.Equal(t, 3, srv.Inventory(3, 2))
+
+	got.Reset()
+	listCmd := InventoryListCmd{Config: cfg, Location: "2"}
+	require.NoError(t, listCmd.Run())
+	require.Equal(t, "number of inventory levels: 1\ninventory_item_id: 3 variant_id: 2 sku: TEE-M, available: 3, title: Tee - M\n", got.String())
+
+	disconnectCmd := InventoryDisconnectCmd{Config: cfg, InventoryItemID: 3, LocationID: "2"}
+	require.NoError(t, disconnectCmd.Run())
+	connectCmd := InventoryConnectCmd{Config: cfg, InventoryItemID: 3, LocationID: "2"}
+	require.NoError(t, connectCmd.Run())
+	require.Equal(t, 0, srv.Inventory(3, 2))
+
+	setCmd.Store = "eql"
+	require.Error(t, setCmd.Run())
+}
+
+func TestInventorySyncCmd(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	srv.AddProducts(goshopify.Product{ID: 1, Title: "Tee", Variants: []goshopify.Variant{
+		{ID: 2, ProductID: 1, InventoryItemId: 3, Title: "M", Sku: "TEE-M"},
+		{ID: 4, ProductID: 1, InventoryItemId: 5, Title: "L", Sku: "TEE-L"},
+	}})
+	got := &bytes.Buffer{}
+	cfg := Config{Store: "eql-dev", out: got, client: srv.Client(), transport: srv.Client().Client.Transport}
+	setCmd := InventorySetCmd{Config: cfg, InventoryItemID: 3, LocationID: "shop location", Available: 5}
+	require.NoError(t, setCmd.Run())
+	setCmd.InventoryItemID = 5
+	require.NoError(t, setCmd.Run())
+
+	got.Reset()
+	cmd := InventorySyncCmd{Config: cfg, File: "testdata/stock.csv", BatchSize: 100, MaxChangedPercent: 10, MaxChange: 10}
+	require.ErrorContains(t, cmd.Run(), "1 of 2 items (50.0%) change by more than 10")
+	require.Equal(t, "sku TEE-M at location 1: 5 -> 7 (+2)\nsku TEE-L at location 1: 5 -> 30 (+25)\nchanges: 2 of 2 items\n", got.String())
+	require.Equal(t, 5, srv.Inventory(5, srv.LocationID))
+
+	got.Reset()
+	cmd.Force, cmd.DryRun = true, true
+	require.NoError(t, cmd.Run())
+	require.Equal(t, 5, srv.Inventory(5, srv.LocationID))
+
+	got.Reset()
+	cmd.DryRun = false
+	require.NoError(t, cmd.Run())
+	require.Contains(t, got.String(), "applied: 2\n")
+	require.Equal(t, 7, srv.Inventory(3, srv.LocationID))
+	require.Equal(t, 30, srv.Inventory(5, srv.LocationID))
+}
+
+func TestCheckStockCmd(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	srv.AddProducts(goshopify.Product{ID: 1, Title: "Tee", Variants: []goshopify.Variant{{ID: 2, ProductID: 1, InventoryItemId: 3, Sku: "TEE-M"}}})
+	got := &bytes.Buffer{}
+	cfg := Config{Store: "eql-dev", out: got, client: srv.Client()}
+	setCmd := InventorySetCmd{Config: cfg, InventoryItemID: 3, LocationID: "shop location", Available: 1}
+	require.NoError(t, setCmd.Run())
+	file := filepath.Join(t.TempDir(), "orders.jsonl")
+	orders := `{"name": "#1001", "line_items": [{"sku": "TEE-M", "quantity": 1}]}` + "\n" + `{"name": "#1002", "line_items": [{"variant_id": 2, "quantity": 1}]}`
+	require.NoError(t, os.WriteFile(file, []byte(orders), 0o600))
+
+	got.Reset()
+	cmd := CheckStockCmd{Config: cfg, File: file}
+	require.NoError(t, cmd.Run())
+	require.Equal(t, "inventory available for 2 orders\n", got.String())
+
+	got.Reset()
+	cmd.ResolveSKU = true
+	require.EqualError(t, cmd.Run(), "insufficient inventory, shortfalls: 1")
+	require.Equal(t, "shortfall: variant 2 (inventory item 3) at location 1: 2 required by #1001, #1002, 1 available\n", got.String())
+}
+
+func TestWatchCmd(t *testing.T) {
+	srv := fake.NewServer(goshopify.Order{ID: 1, Name: "#1002", Email: "old@example.com"})
+	defer srv.Close()
+	got := &bytes.Buffer{}
+	cfg := Config{Store: "eql-dev", out: got, client: srv.Client(), transport: srv.Client().Client.Transport}
+	dir := t.TempDir()
+	b, err := os.ReadFile("testdata/orders.csv")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "orders.csv"), b, 0o600))
+
+	cmd := WatchCmd{Config: cfg, Dir: dir, Once: true}
+	require.NoError(t, cmd.Run())
+	want := `orders.csv processed, orders: 2, failed: 0
+order "#1001" merged (created), ID: 1000
+order "#1002" merged (updated), ID: 1
+`
+	require.Equal(t, want, got.String())
+	require.Len(t, srv.Orders(), 2)
+	require.FileExists(t, filepath.Join(dir, "processed", "orders.csv"))
+	require.FileExists(t, filepath.Join(dir, "processed", "orders.csv.result.json"))
+
+	got.Reset()
+	require.NoError(t, cmd.Run())
+	require.Empty(t, got.String())
+
+	// with --check-stock no order of a file is imported if the inventory
+	// does not cover all of them
+	srv.AddProducts(goshopify.Product{ID: 1, Variants: []goshopify.Variant{{ID: 2, ProductID: 1, InventoryItemId: 3, Sku: "TEE-M"}}})
+	_, err = order.NewShopifyClient(srv.Client()).SetInventoryLevel(order.InventoryLevel{InventoryItemID: 3, LocationID: 1, Available: 1})
+	require.NoError(t, err)
+	lines := `{"name": "#1003", "line_items": [{"variant_id": 2, "quantity": 1}]}` + "\n" + `{"name": "#1004", "line_items": [{"variant_id": 2, "quantity": 1}]}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "stock.jsonl"), []byte(lines), 0o600))
+	got.Reset()
+	cmd.CheckStock = true
+	require.NoError(t, cmd.Run())
+	want = `stock.jsonl failed, orders: 0, failed: 0
+error: insufficient inventory: variant 2 (inventory item 3) at location 1: 2 required by #1003, #1004, 1 available
+`
+	require.Equal(t, want, got.String())
+	require.Len(t, srv.Orders(), 2)
+
+	cmd.Store = "eql"
+	require.EqualError(t, cmd.Run(), `write command for non whitelisted shop "eql"`)
+}
+
+func TestLocationCmd(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	srv.AddLocations(goshopify.Location{ID: 1, Name: "Shop location", Active: true, Address1: "1 Main St", City: "Sydney"}, goshopify.Location{ID: 2, Name: "Warehouse", Active: true, Legacy: true})
+	got := &bytes.Buffer{}
+	cfg := Config{Store: "eql-dev", out: got, client: srv.Client()}
+
+	listCmd := LocationListCmd{Config: cfg}
+	require.NoError(t, listCmd.Run())
+	want := `number of locations: 2
+id: 1 name: Shop location, address: 1 Main St, Sydney, active: true, fulfills online orders: true
+id: 2 name: Warehouse, address: , active: true, fulfills online orders: false, fulfillment service: Fulfillment service
+`
+	require.Equal(t, want, got.String())
+
+	got.Reset()
+	getCmd := LocationGetCmd{Config: cfg, Location: "warehouse"}
+	require.NoError(t, getCmd.Run())
+	require.Contains(t, got.String(), `"id":2,"name":"Warehouse"`)
+}
+
+func TestWebhookCmd(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	got := &bytes.Buffer{}
+	cfg := Config{Store: "eql-dev", out: got, client: srv.Client()}
+
+	registerCmd := WebhookRegisterCmd{Config: cfg, Address: "https://example.com/webhooks", Topic: []string{"orders/paid", "orders/cancelled"}}
+	require.NoError(t, registerCmd.Run())
+	registerCmd.Topic = []string{"orders/paid", "orders/fulfilled"}
+	require.NoError(t, registerCmd.Run())
+	webhooks := srv.Webhooks()
+	require.Len(t, webhooks, 3)
+	require.Contains(t, got.String(), fmt.Sprintf("webhook exists, ID: %d, topic: orders/paid\n", webhooks[0].ID))
+
+	got.Reset()
+	listCmd := WebhookListCmd{Config: cfg, Topic: "orders/cancelled"}
+	require.NoError(t, listCmd.Run())
+	require.Equal(t, fmt.Sprintf("number of webhooks: 1\nid: %d topic: orders/cancelled, address: https://example.com/webhooks\n", webhooks[1].ID), got.String())
+
+	deleteCmd := WebhookDeleteCmd{Config: cfg, ID: webhooks[1].ID}
+	require.NoError(t, deleteCmd.Run())
+	require.Len(t, srv.Webhooks(), 2)
+}
+
+func TestServeWebhooksCmd(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "webhooks.jsonl")
+	execOutput := filepath.Join(dir, "exec.txt")
+	serveCmd := ServeWebhooksCmd{WebhookSecret: "secret", Output: output, Exec: "echo $WEBHOOK_TOPIC >> " + execOutput, out: &bytes.Buffer{}}
+	handler, closeOutput, err := serveCmd.handler()
+	require.NoError(t, err)
+	defer closeOutput()
+	receiver := httptest.NewServer(handler)
+	defer receiver.Close()
+
+	got := &bytes.Buffer{}
+	sendCmd := WebhookSendCmd{URL: receiver.URL, File: "testdata/order.json", Topic: "orders/paid", WebhookSecret: "secret", Shop: "eql-dev.myshopify.com", out: got, client: receiver.Client()}
+	require.NoError(t, sendCmd.Run())
+	require.Equal(t, "webhook sent, topic: orders/paid\n", got.String())
+	sendCmd.WebhookSecret = "wrong"
+	require.ErrorContains(t, sendCmd.Run(), "401 Unauthorized")
+
+	b, err := os.ReadFile(output)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	require.Len(t, lines, 1)
+	event := order.WebhookEvent{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &event))
+	require.Equal(t, "orders/paid", event.Topic)
+	require.Equal(t, "eql-dev.myshopify.com", event.Shop)
+	require.NotNil(t, event.Order)
+	b, err = os.ReadFile(execOutput)
+	require.NoError(t, err)
+	require.Equal(t, "orders/paid\n", string(b))
+}
+
+func TestSeedCmd(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	got := &bytes.Buffer{}
+	cfg := Config{Store: "eql-dev", out: got, client: srv.Client(), transport: srv.Client().Client.Transport}
+	lockfile := filepath.Join(t.TempDir(), "seed.lock.json")
+
+	cmd := SeedApplyCmd{Config: cfg, Dir: "testdata/fixtures", Lockfile: lockfile}
+	require.NoError(t, cmd.Run())
+	require.Contains(t, got.String(), "created: 5, found: 0, locked: 0\n")
+	require.FileExists(t, lockfile)
+
+	got.Reset()
+	require.NoError(t, cmd.Run())
+	require.Contains(t, got.String(), "created: 0, found: 2, locked: 3\n")
+
+	got.Reset()
+	resolveCmd := SeedResolveCmd{File: "testdata/fixtures/orders/order-fixture-1.json", Store: "eql-dev", Lockfile: lockfile, out: got}
+	require.NoError(t, resolveCmd.Run())
+	o := &goshopify.Order{}
+	require.NoError(t, json.Unmarshal(got.Bytes(), o))
+	require.Equal(t, srv.Products()[0].Variants[0].ID, o.LineItems[0].VariantID)
+
+	resolveCmd.Store = "other"
+	require.Error(t, resolveCmd.Run())
+}
+
+func TestServeCmd(t *testing.T) {
+	t.Setenv("ORDERER_API_TOKEN", "secret")
+	t.Setenv("SHOPIFY_TOKEN", "token")
+	got := &bytes.Buffer{}
+	cmd := ServeCmd{APIConfig: "testdata/api.json", out: got}
+	server, err := cmd.server()
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/profiles/dev/orders", strings.NewReader("{}"))
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, "POST /profiles/dev/orders ci 400\n", got.String())
+}
+
+func TestBatchDeleteCmdInterrupted(t *testing.T) {
+	srv := fake.NewServer(goshopify.Order{ID: 1, Name: "order1"}, goshopify.Order{ID: 2, Name: "order2"})
+	defer srv.Close()
+	got := &bytes.Buffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cfg := Config{Store: "eql-dev", out: got, client: srv.Client(), transport: srv.Client().Client.Transport, ctx: ctx}
+
+	cmd := BatchDeleteCmd{Config: cfg, Max: -1}
+	require.ErrorIs(t, cmd.Run(), context.Canceled)
+	want := `number of orders to delete: 2
+orders deleted: 0 of 2
+`
+	require.Equal(t, want, got.String())
+	require.Len(t, srv.Orders(), 2)
+}
@@ -0,0 +1,334 @@
+package order
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strings"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// AddressPolicy defines how invalid addresses are handled before import.
+type AddressPolicy string
+
+const (
+	AddressPolicyNone   AddressPolicy = "none"   // send addresses as they are
+	AddressPolicyFix    AddressPolicy = "fix"    // correct codes, names and zips
+	AddressPolicyStrict AddressPolicy = "strict" // fail on anything that would be corrected
+)
+
+// ISO 3166-1 countries and the ISO 3166-2 subdivisions of Australia,
+// Canada, New Zealand and the United States. Provinces of other
+// countries, including others where Shopify requires one, are not
+// checked.
+var (
+	//go:embed data/countries.csv
+	countriesCSV string
+	//go:embed data/provinces.csv
+	provincesCSV string
+)
+
+type country struct {
+	code      string
+	name      string
+	provinces []province
+}
+
+type province struct {
+	code string // without country prefix, e.g. VIC
+	name string
+}
+
+// zipFormat validates a country's postal codes after normalisation.
+type zipFormat struct {
+	pattern *regexp.Regexp
+	// split inserts a space before the last split characters, e.g. for
+	// SW1A1AA.
+	split int
+}
+
+var (
+	countries      = map[string]*country{}
+	countriesByKey = map[string]*country{}
+)
+
+// countryAliases are common country names other than the ISO short name.
+var countryAliases = map[string]string{
+	"usa":                      "US",
+	"united states of america": "US",
+	"america":                  "US",
+	"uk":                       "GB",
+	"great britain":            "GB",
+	"england":                  "GB",
+	"scotland":                 "GB",
+	"wales":                    "GB",
+	"northern ireland":         "GB",
+	"holland":                  "NL",
+	"the netherlands":          "NL",
+	"republic of korea":        "KR",
+	"russian federation":       "RU",
+	"czech republic":           "CZ",
+	"viet nam":                 "VN",
+	"aotearoa":                 "NZ",
+}
+
+var zipFormats = map[string]zipFormat{
+	"AT": {pattern: regexp.MustCompile(`^\d{4}$`)},
+	"AU": {pattern: regexp.MustCompile(`^\d{4}$`)},
+	"BE": {pattern: regexp.MustCompile(`^\d{4}$`)},
+	"CA": {pattern: regexp.MustCompile(`^[A-Z]\d[A-Z] \d[A-Z]\d$`), split: 3},
+	"CH": {pattern: regexp.MustCompile(`^\d{4}$`)},
+	"DE": {pattern: regexp.MustCompile(`^\d{5}$`)},
+	"DK": {pattern: regexp.MustCompile(`^\d{4}$`)},
+	"ES": {pattern: regexp.MustCompile(`^\d{5}$`)},
+	"FR": {pattern: regexp.MustCompile(`^\d{5}$`)},
+	"GB": {pattern: regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? \d[A-Z]{2}$`), split: 3},
+	"IT": {pattern: regexp.MustCompile(`^\d{5}$`)},
+	"JP": {pattern: regexp.MustCompile(`^\d{3}-\d{4}$`)},
+	"NL": {pattern: regexp.MustCompile(`^\d{4} [A-Z]{2}$`), split: 2},
+	"NZ": {pattern: regexp.MustCompile(`^\d{4}$`)},
+	"SE": {pattern: regexp.MustCompile(`^\d{3} \d{2}$`), split: 2},
+	"SG": {pattern: regexp.MustCompile(`^\d{6}$`)},
+	"US": {pattern: regexp.MustCompile(`^\d{5}(-\d{4})?$`)},
+}
+
+// optionalProvince lists countries with known provinces for which
+// Shopify does not require one, e.g. the regions of New Zealand.
+var optionalProvince = map[string]bool{
+	"NZ": true,
+}
+
+func init() {
+	rows, err := csv.NewReader(strings.NewReader(countriesCSV)).ReadAll()
+	if err != nil {
+		panic(err)
+	}
+	for _, row := range rows[1:] {
+		c := &country{code: row[0], name: row[1]}
+		countries[c.code] = c
+		countriesByKey[strings.ToLower(c.code)] = c
+		countriesByKey[strings.ToLower(c.name)] = c
+	}
+	for alias, code := range countryAliases {
+		countriesByKey[alias] = countries[code]
+	}
+	rows, err = csv.NewReader(strings.NewReader(provincesCSV)).ReadAll()
+	if err != nil {
+		panic(err)
+	}
+	for _, row := range rows[1:] {
+		c := countries[row[0]]
+		c.provinces = append(c.provinces, province{code: row[1], name: row[2]})
+	}
+}
+
+// AddressChange is a correction made, or required in strict mode, to an
+// address field, e.g. billing_address.province_code.
+type AddressChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type AddressErrors []string
+
+func (e AddressErrors) Error() string {
+	return "invalid addresses: " + strings.Join(e, "; ")
+}
+
+// addressFields points to the normalised fields of an order or customer
+// address.
+type addressFields struct {
+	prefix       string
+	country      *string
+	countryCode  *string
+	countryName  *string // customer addresses only
+	province     *string
+	provinceCode *string
+	zip          *string
+}
+
+type addressNormaliser struct {
+	policy  AddressPolicy
+	changes []AddressChange
+	errs    AddressErrors
+}
+
+// NormaliseOrderAddresses corrects country and province codes and names
+// and zip formats of the order's billing, shipping and customer
+// addresses. Country codes take precedence over country names. Provinces
+// are only checked for countries with known provinces, see provincesCSV.
+// With AddressPolicyStrict nothing is changed and every required
+// correction is reported as error. Addresses that cannot be corrected, e.g. with
+// unknown country, are errors regardless of policy.
+func NormaliseOrderAddresses(order *goshopify.Order, policy AddressPolicy) ([]AddressChange, error) {
+	n := &addressNormaliser{policy: policy}
+	if policy == "" || policy == AddressPolicyNone {
+		return nil, nil
+	}
+	if a := order.BillingAddress; a != nil {
+		n.normalise(orderAddressFields("billing_address", a))
+	}
+	if a := order.ShippingAddress; a != nil {
+		n.normalise(orderAddressFields("shipping_address", a))
+	}
+	if order.Customer != nil {
+		n.customer("customer.", order.Customer)
+	}
+	return n.result()
+}
+
+// NormaliseCustomerAddresses normalises the default and other addresses
+// of customer, see NormaliseOrderAddresses.
+func NormaliseCustomerAddresses(customer *goshopify.Customer, policy AddressPolicy) ([]AddressChange, error) {
+	n := &addressNormaliser{policy: policy}
+	if policy == "" || policy == AddressPolicyNone {
+		return nil, nil
+	}
+	n.customer("", customer)
+	return n.result()
+}
+
+func orderAddressFields(prefix string, a *goshopify.Address) addressFields {
+	return addressFields{
+		prefix:       prefix,
+		country:      &a.Country,
+		countryCode:  &a.CountryCode,
+		province:     &a.Province,
+		provinceCode: &a.ProvinceCode,
+		zip:          &a.Zip,
+	}
+}
+
+func customerAddressFields(prefix string, a *goshopify.CustomerAddress) addressFields {
+	return addressFields{
+		prefix:       prefix,
+		country:      &a.Country,
+		countryCode:  &a.CountryCode,
+		countryName:  &a.CountryName,
+		province:     &a.Province,
+		provinceCode: &a.ProvinceCode,
+		zip:          &a.Zip,
+	}
+}
+
+func (n *addressNormaliser) customer(prefix string, c *goshopify.Customer) {
+	if a := c.DefaultAddress; a != nil {
+		n.normalise(customerAddressFields(prefix+"default_address", a))
+	}
+	for i, a := range c.Addresses {
+		if a != nil && a != c.DefaultAddress {
+			n.normalise(customerAddressFields(fmt.Sprintf("%saddresses.%d", prefix, i), a))
+		}
+	}
+}
+
+func (n *addressNormaliser) normalise(a addressFields) {
+	if *a.country == "" && *a.countryCode == "" {
+		return
+	}
+	c := countriesByKey[strings.ToLower(strings.TrimSpace(*a.countryCode))]
+	if c == nil {
+		c = countriesByKey[strings.ToLower(strings.TrimSpace(*a.country))]
+	}
+	if c == nil {
+		n.errs = append(n.errs, fmt.Sprintf("%s: unknown country %q, country code %q", a.prefix, *a.country, *a.countryCode))
+		return
+	}
+	n.set(a.prefix+".country_code", a.countryCode, c.code)
+	n.set(a.prefix+".country", a.country, c.name)
+	if a.countryName != nil && *a.countryName != "" {
+		n.set(a.prefix+".country_name", a.countryName, c.name)
+	}
+	if len(c.provinces) != 0 {
+		n.normaliseProvince(a, c)
+	}
+	if *a.zip != "" {
+		n.normaliseZip(a, c)
+	}
+}
+
+func (n *addressNormaliser) normaliseProvince(a addressFields, c *country) {
+	if *a.province == "" && *a.provinceCode == "" {
+		if optionalProvince[c.code] {
+			return
+		}
+		n.errs = append(n.errs, fmt.Sprintf("%s: province required for %s", a.prefix, c.name))
+		return
+	}
+	p := c.province(*a.provinceCode)
+	if p == nil {
+		p = c.province(*a.province)
+	}
+	if p == nil {
+		n.errs = append(n.errs, fmt.Sprintf("%s: unknown province %q, province code %q for %s", a.prefix, *a.province, *a.provinceCode, c.name))
+		return
+	}
+	n.set(a.prefix+".province_code", a.provinceCode, p.code)
+	n.set(a.prefix+".province", a.province, p.name)
+}
+
+func (n *addressNormaliser) normaliseZip(a addressFields, c *country) {
+	f, ok := zipFormats[c.code]
+	if !ok {
+		n.set(a.prefix+".zip", a.zip, strings.TrimSpace(*a.zip))
+		return
+	}
+	zip := strings.ToUpper(strings.Join(strings.Fields(*a.zip), ""))
+	if f.split != 0 && len(zip) > f.split {
+		zip = zip[:len(zip)-f.split] + " " + zip[len(zip)-f.split:]
+	}
+	if c.code == "JP" && len(zip) == 7 {
+		zip = zip[:3] + "-" + zip[3:]
+	}
+	if !f.pattern.MatchString(zip) {
+		n.errs = append(n.errs, fmt.Sprintf("%s: invalid zip %q for %s", a.prefix, *a.zip, c.name))
+		return
+	}
+	n.set(a.prefix+".zip", a.zip, zip)
+}
+
+// set records a change of field to v, applying it unless in strict mode.
+func (n *addressNormaliser) set(field string, p *string, v string) {
+	if *p == v {
+		return
+	}
+	n.changes = append(n.changes, AddressChange{Field: field, Old: *p, New: v})
+	if n.policy == AddressPolicyStrict {
+		n.errs = append(n.errs, fmt.Sprintf("%s: %q should be %q", field, *p, v))
+		return
+	}
+	*p = v
+}
+
+func (n *addressNormaliser) result() ([]AddressChange, error) {
+	if len(n.errs) != 0 {
+		return n.changes, n.errs
+	}
+	return n.changes, nil
+}
+
+// province returns the province with the given code, with or without
+// country prefix, or name, compared case-insensitively.
+func (c *country) province(s string) *province {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.ToUpper(s), c.code+"-")
+	for i, p := range c.provinces {
+		if strings.EqualFold(p.code, s) || strings.EqualFold(p.name, s) {
+			return &c.provinces[i]
+		}
+	}
+	return nil
+}
+
+// lookupCountry returns the ISO 3166-1 alpha-2 code of a country given
+// by code, name or common alias.
+func lookupCountry(s string) (string, bool) {
+	c := countriesByKey[strings.ToLower(strings.TrimSpace(s))]
+	if c == nil {
+		return "", false
+	}
+	return c.code, true
+}
@@ -0,0 +1,71 @@
+package order
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormaliseOrderAddresses(t *testing.T) {
+	o := &goshopify.Order{
+		BillingAddress:  &goshopify.Address{Country: "Australia", Province: "Vic", Zip: " 3333 "},
+		ShippingAddress: &goshopify.Address{Country: "New Zealand", CountryCode: "au", ProvinceCode: "AU-NSW", Zip: "2000"},
+		Customer: &goshopify.Customer{DefaultAddress: &goshopify.CustomerAddress{
+			Country: "UK", Zip: "sw1a1aa", CountryName: "UK",
+		}},
+	}
+	changes, err := NormaliseOrderAddresses(o, AddressPolicyFix)
+	require.NoError(t, err)
+	require.Equal(t, &goshopify.Address{Country: "Australia", CountryCode: "AU", Province: "Victoria", ProvinceCode: "VIC", Zip: "3333"}, o.BillingAddress)
+	require.Equal(t, &goshopify.Address{Country: "Australia", CountryCode: "AU", Province: "New South Wales", ProvinceCode: "NSW", Zip: "2000"}, o.ShippingAddress)
+	require.Equal(t, &goshopify.CustomerAddress{Country: "United Kingdom", CountryCode: "GB", CountryName: "United Kingdom", Zip: "SW1A 1AA"}, o.Customer.DefaultAddress)
+	require.Contains(t, changes, AddressChange{Field: "billing_address.province_code", Old: "", New: "VIC"})
+	require.Contains(t, changes, AddressChange{Field: "shipping_address.country", Old: "New Zealand", New: "Australia"})
+	require.Contains(t, changes, AddressChange{Field: "customer.default_address.zip", Old: "sw1a1aa", New: "SW1A 1AA"})
+
+	changes, err = NormaliseOrderAddresses(o, AddressPolicyStrict)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	nz := &goshopify.Order{ShippingAddress: &goshopify.Address{Country: "New Zealand", CountryCode: "NZ", Zip: "6011"}}
+	_, err = NormaliseOrderAddresses(nz, AddressPolicyStrict)
+	require.NoError(t, err, "province is optional for New Zealand")
+	nz.ShippingAddress.Province = "Wellington"
+	_, err = NormaliseOrderAddresses(nz, AddressPolicyFix)
+	require.NoError(t, err)
+	require.Equal(t, "WGN", nz.ShippingAddress.ProvinceCode)
+
+	for _, a := range []goshopify.Address{
+		{Country: "Atlantis"},
+		{CountryCode: "US", Province: "Springfield", Zip: "12345"},
+		{CountryCode: "US", Zip: "12345"},
+		{CountryCode: "US", ProvinceCode: "NY", Zip: "1234"},
+	} {
+		a := a
+		_, err := NormaliseOrderAddresses(&goshopify.Order{BillingAddress: &a}, AddressPolicyFix)
+		require.Error(t, err, a)
+	}
+}
+
+func TestNormaliseCustomerAddressesStrict(t *testing.T) {
+	b, err := os.ReadFile("../testdata/customer.json")
+	require.NoError(t, err)
+	c := &goshopify.Customer{}
+	require.NoError(t, json.Unmarshal(b, c))
+	changes, err := NormaliseCustomerAddresses(c, AddressPolicyStrict)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	c.DefaultAddress.ProvinceCode = "vic"
+	changes, err = NormaliseCustomerAddresses(c, AddressPolicyStrict)
+	require.Error(t, err)
+	require.Equal(t, []AddressChange{{Field: "default_address.province_code", Old: "vic", New: "VIC"}}, changes)
+	require.Equal(t, "vic", c.DefaultAddress.ProvinceCode)
+
+	changes, err = NormaliseCustomerAddresses(c, AddressPolicyNone)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
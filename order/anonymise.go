@@ -0,0 +1,217 @@
+package order
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+var (
+	fakeFirstNames = []string{"Alex", "Billie", "Casey", "Charlie", "Dana", "Drew", "Eden", "Frankie", "Harper", "Jamie", "Jesse", "Jordan", "Kai", "Lee", "Morgan", "Quinn", "Riley", "Rowan", "Sam", "Taylor"}
+	fakeLastNames  = []string{"Archer", "Baker", "Carter", "Dawson", "Ellis", "Fletcher", "Grant", "Hayes", "Irving", "Jensen", "Keller", "Lane", "Mercer", "Nash", "Osborne", "Parker", "Reed", "Sutton", "Turner", "Walsh"}
+	fakeStreets    = []string{"Acacia Ave", "Beach Rd", "Church St", "Elm St", "George St", "High St", "King St", "Lake Rd", "Main St", "Market St", "Mill Lane", "Oak Ave", "Park Rd", "Queen St", "River Rd", "Station St", "Victoria St", "Water St"}
+)
+
+// Anonymiser replaces personal data in orders and customers with fake but
+// format-valid values. Values are derived from a keyed hash of the
+// original value, so the same email, name, phone or street is replaced by
+// the same fake value across orders, while the original cannot be
+// recovered without the seed. City, province, zip and country are kept
+// so that tax and shipping behave as in the original.
+type Anonymiser struct {
+	key []byte
+}
+
+// personFields points to the personal fields of an order or customer
+// address.
+type personFields struct {
+	firstName, lastName, name *string
+	company                   *string
+	address1, address2        *string
+	phone                     *string
+}
+
+// NewAnonymiser returns an Anonymiser keyed with seed. The same seed
+// yields the same fake values.
+func NewAnonymiser(seed string) (*Anonymiser, error) {
+	if seed == "" {
+		return nil, fmt.Errorf("anonymiser seed is empty")
+	}
+	return &Anonymiser{key: []byte(seed)}, nil
+}
+
+// Order anonymises order in place, including its customer, addresses,
+// notes, client details and transaction payment details.
+func (a *Anonymiser) Order(order *goshopify.Order) {
+	order.Email = a.Email(order.Email)
+	order.ContactEmail = a.Email(order.ContactEmail)
+	order.Phone = a.Phone(order.Phone)
+	order.Note = a.note(order.Note)
+	for i := range order.NoteAttributes {
+		if s, ok := order.NoteAttributes[i].Value.(string); ok && s != "" {
+			order.NoteAttributes[i].Value = a.note(s)
+		}
+	}
+	order.BrowserIp = ""
+	if d := order.ClientDetails; d != nil {
+		order.ClientDetails = &goshopify.ClientDetails{AcceptLanguage: d.AcceptLanguage, BrowserHeight: d.BrowserHeight, BrowserWidth: d.BrowserWidth}
+	}
+	if addr := order.BillingAddress; addr != nil {
+		addr.Latitude, addr.Longitude = 0, 0
+		a.person(orderPersonFields(addr))
+	}
+	if addr := order.ShippingAddress; addr != nil {
+		addr.Latitude, addr.Longitude = 0, 0
+		a.person(orderPersonFields(addr))
+	}
+	if order.Customer != nil {
+		a.Customer(order.Customer)
+	}
+	clearPaymentDetails(order.Transactions)
+	for i := range order.Refunds {
+		clearPaymentDetails(order.Refunds[i].Transactions)
+	}
+}
+
+// Customer anonymises customer and its addresses in place.
+func (a *Anonymiser) Customer(customer *goshopify.Customer) {
+	first, last := customer.FirstName, customer.LastName
+	if first != "" {
+		customer.FirstName = a.firstName(first)
+	}
+	if last != "" {
+		customer.LastName = a.lastName(last)
+	}
+	customer.Email = a.Email(customer.Email)
+	customer.Phone = a.Phone(customer.Phone)
+	customer.Note = a.note(customer.Note)
+	if addr := customer.DefaultAddress; addr != nil {
+		a.person(customerPersonFields(addr))
+	}
+	for _, addr := range customer.Addresses {
+		if addr != nil && addr != customer.DefaultAddress {
+			a.person(customerPersonFields(addr))
+		}
+	}
+}
+
+// Email returns a fake example.com address for email, compared
+// case-insensitively. An empty email stays empty.
+func (a *Anonymiser) Email(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return ""
+	}
+	return fmt.Sprintf("customer-%x@example.com", a.hash("email", email)[:6])
+}
+
+// Phone returns a fake phone number with the format, length and, for
+// numbers starting with "+", the country calling code of phone. National
+// numbers keep their first digit, typically the trunk prefix.
+func (a *Anonymiser) Phone(phone string) string {
+	phone = strings.TrimSpace(phone)
+	digits := phoneDigits(phone)
+	if digits == "" {
+		return phone
+	}
+	keep := 1
+	if strings.HasPrefix(phone, "+") {
+		keep = len(callingCode(digits))
+	}
+	h := a.hash("phone", digits)
+	var b strings.Builder
+	i := 0
+	for _, c := range phone {
+		if c < '0' || c > '9' {
+			b.WriteRune(c)
+			continue
+		}
+		if i < keep {
+			b.WriteRune(c)
+		} else {
+			b.WriteByte('0' + h[i%len(h)]%10)
+		}
+		i++
+	}
+	return b.String()
+}
+
+func orderPersonFields(addr *goshopify.Address) personFields {
+	return personFields{&addr.FirstName, &addr.LastName, &addr.Name, &addr.Company, &addr.Address1, &addr.Address2, &addr.Phone}
+}
+
+func customerPersonFields(addr *goshopify.CustomerAddress) personFields {
+	return personFields{&addr.FirstName, &addr.LastName, &addr.Name, &addr.Company, &addr.Address1, &addr.Address2, &addr.Phone}
+}
+
+func (a *Anonymiser) person(p personFields) {
+	if *p.firstName != "" {
+		*p.firstName = a.firstName(*p.firstName)
+	}
+	if *p.lastName != "" {
+		*p.lastName = a.lastName(*p.lastName)
+	}
+	if *p.name != "" {
+		*p.name = strings.TrimSpace(*p.firstName + " " + *p.lastName)
+	}
+	if *p.company != "" {
+		*p.company = fmt.Sprintf("Company %X", a.hash("company", strings.ToLower(*p.company))[:2])
+	}
+	if *p.address1 != "" {
+		h := a.hash("street", strings.ToLower(*p.address1))
+		*p.address1 = fmt.Sprintf("%d %s", 1+binary.BigEndian.Uint32(h)%200, pick(fakeStreets, h[4:]))
+	}
+	if *p.address2 != "" {
+		*p.address2 = fmt.Sprintf("Unit %d", 1+binary.BigEndian.Uint32(a.hash("street2", strings.ToLower(*p.address2)))%50)
+	}
+	*p.phone = a.Phone(*p.phone)
+}
+
+func clearPaymentDetails(transactions []goshopify.Transaction) {
+	for i := range transactions {
+		transactions[i].PaymentDetails = nil
+	}
+}
+
+func (a *Anonymiser) firstName(name string) string {
+	return pick(fakeFirstNames, a.hash("first_name", strings.ToLower(strings.TrimSpace(name))))
+}
+
+func (a *Anonymiser) lastName(name string) string {
+	return pick(fakeLastNames, a.hash("last_name", strings.ToLower(strings.TrimSpace(name))))
+}
+
+func (a *Anonymiser) note(note string) string {
+	if note == "" {
+		return ""
+	}
+	return fmt.Sprintf("note %x", a.hash("note", note)[:4])
+}
+
+// hash returns the keyed hash of value, separated by kind so that e.g.
+// a first name and a last name with the same value are unrelated.
+func (a *Anonymiser) hash(kind, value string) []byte {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(kind + "\x00" + value))
+	return mac.Sum(nil)
+}
+
+func pick(values []string, h []byte) string {
+	return values[binary.BigEndian.Uint32(h)%uint32(len(values))]
+}
+
+// callingCode returns the longest known country calling code digits
+// start with, or the first digit.
+func callingCode(digits string) string {
+	code := digits[:1]
+	for _, r := range phoneRegions {
+		if len(r.callingCode) > len(code) && strings.HasPrefix(digits, r.callingCode) {
+			code = r.callingCode
+		}
+	}
+	return code
+}
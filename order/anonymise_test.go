@@ -0,0 +1,73 @@
+package order
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func testOrderWithPII() *goshopify.Order {
+	return &goshopify.Order{
+		Name:  "#1001",
+		Email: "Jo.Citizen@example.org",
+		Phone: "+61 3 9123 4567",
+		Note:  "leave at back door, Jo",
+		BillingAddress: &goshopify.Address{
+			FirstName: "Jo", LastName: "Citizen", Name: "Jo Citizen", Address1: "12 Secret St", Address2: "Flat 3",
+			City: "Melbourne", Province: "Victoria", Zip: "3000", Country: "Australia", Phone: "(03) 9123 4567", Latitude: -37.8,
+		},
+		Customer: &goshopify.Customer{
+			FirstName: "Jo", LastName: "Citizen", Email: "jo.citizen@example.org",
+			DefaultAddress: &goshopify.CustomerAddress{FirstName: "Jo", LastName: "Citizen", Address1: "12 Secret St", Phone: "+1 (415) 555-0100"},
+		},
+		ClientDetails: &goshopify.ClientDetails{BrowserIp: "10.1.2.3", UserAgent: "Mozilla", AcceptLanguage: "en"},
+		Transactions:  []goshopify.Transaction{{Kind: "sale", PaymentDetails: &goshopify.PaymentDetails{CreditCardNumber: "•••• 4242"}}},
+	}
+}
+
+func TestAnonymiserOrder(t *testing.T) {
+	a, err := NewAnonymiser("secret")
+	require.NoError(t, err)
+	o := testOrderWithPII()
+	a.Order(o)
+
+	b, err := json.Marshal(o)
+	require.NoError(t, err)
+	for _, pii := range []string{"Jo", "Citizen", "Secret", "Flat", "back door", "9123", "555-0100", "10.1.2.3", "Mozilla", "4242"} {
+		require.NotContains(t, string(b), pii)
+	}
+	require.Equal(t, "#1001", o.Name)
+	require.Equal(t, o.Email, o.Customer.Email, "same email regardless of case")
+	require.True(t, strings.HasSuffix(o.Email, "@example.com"))
+	require.Equal(t, o.BillingAddress.FirstName, o.Customer.FirstName)
+	require.Equal(t, o.BillingAddress.FirstName+" "+o.BillingAddress.LastName, o.BillingAddress.Name)
+	require.Equal(t, o.BillingAddress.Address1, o.Customer.DefaultAddress.Address1)
+	require.Equal(t, "Melbourne", o.BillingAddress.City)
+	require.Equal(t, "3000", o.BillingAddress.Zip)
+	require.Zero(t, o.BillingAddress.Latitude)
+	require.Equal(t, "en", o.ClientDetails.AcceptLanguage)
+	require.Nil(t, o.Transactions[0].PaymentDetails)
+
+	require.True(t, strings.HasPrefix(o.Phone, "+61 "))
+	require.Len(t, o.Phone, len("+61 3 9123 4567"))
+	_, err = NormalisePhone(o.Phone, "")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(o.Customer.DefaultAddress.Phone, "+1 ("))
+	require.True(t, strings.HasPrefix(o.BillingAddress.Phone, "(0"))
+
+	again := testOrderWithPII()
+	a.Order(again)
+	require.Equal(t, o, again)
+
+	other, err := NewAnonymiser("other")
+	require.NoError(t, err)
+	again = testOrderWithPII()
+	other.Order(again)
+	require.NotEqual(t, o.Email, again.Email)
+
+	_, err = NewAnonymiser("")
+	require.Error(t, err)
+}
@@ -0,0 +1,323 @@
+package order
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/shopspring/decimal"
+)
+
+type BulkOptions struct {
+	// PollInterval is the time between bulk operation status queries.
+	PollInterval time.Duration
+	// Timeout is the maximum time to wait for the bulk operation to
+	// complete, zero means no limit.
+	Timeout time.Duration
+}
+
+type BulkOperation struct {
+	ID          string  `json:"id"`
+	Status      string  `json:"status"`
+	ErrorCode   string  `json:"errorCode"`
+	ObjectCount string  `json:"objectCount"`
+	URL         *string `json:"url"`
+}
+
+type bulkMoney struct {
+	ShopMoney struct {
+		Amount *decimal.Decimal `json:"amount"`
+	} `json:"shopMoney"`
+}
+
+// bulkRow is a single line of a bulk operation result. Orders and line
+// items are returned as separate rows, with line items referencing their
+// order by ParentID.
+type bulkRow struct {
+	ID                       string     `json:"id"`
+	ParentID                 string     `json:"__parentId"`
+	Name                     string     `json:"name"`
+	Email                    string     `json:"email"`
+	Phone                    string     `json:"phone"`
+	Note                     string     `json:"note"`
+	Tags                     []string   `json:"tags"`
+	CreatedAt                *time.Time `json:"createdAt"`
+	ProcessedAt              *time.Time `json:"processedAt"`
+	CurrencyCode             string     `json:"currencyCode"`
+	DisplayFinancialStatus   string     `json:"displayFinancialStatus"`
+	DisplayFulfillmentStatus string     `json:"displayFulfillmentStatus"`
+	TotalPriceSet            *bulkMoney `json:"totalPriceSet"`
+
+	Title                string     `json:"title"`
+	SKU                  string     `json:"sku"`
+	Quantity             int        `json:"quantity"`
+	OriginalUnitPriceSet *bulkMoney `json:"originalUnitPriceSet"`
+	Variant              *struct {
+		ID string `json:"id"`
+	} `json:"variant"`
+}
+
+const bulkOrdersQuery = `{
+  orders%s {
+    edges {
+      node {
+        id name email phone note tags createdAt processedAt currencyCode
+        displayFinancialStatus displayFulfillmentStatus
+        totalPriceSet { shopMoney { amount } }
+        lineItems {
+          edges {
+            node {
+              id title sku quantity
+              originalUnitPriceSet { shopMoney { amount } }
+              variant { id }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+const bulkRunMutation = `mutation($query: String!) {
+  bulkOperationRunQuery(query: $query) {
+    bulkOperation { id status }
+    userErrors { field message }
+  }
+}`
+
+const bulkOperationQuery = `query($id: ID!) {
+  node(id: $id) { ... on BulkOperation { id status errorCode objectCount url } }
+}`
+
+// BulkList returns all orders, or all orders with the given name, using a
+// GraphQL bulk operation. This is considerably faster than paging through
+// the REST API for stores with many orders, but only a subset of order
+// fields is populated.
+func BulkList(client Client, orderName string, opts BulkOptions) ([]goshopify.Order, error) {
+	var orders []goshopify.Order
+	err := BulkOrders(client, orderName, opts, func(o goshopify.Order) error {
+		orders = append(orders, o)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// BulkOrders is like BulkList but calls fn with each order as it is
+// decoded from the bulk operation result instead of keeping all orders
+// in memory.
+func BulkOrders(client Client, orderName string, opts BulkOptions, fn func(goshopify.Order) error) error {
+	filter := ""
+	if orderName != "" {
+		b, err := json.Marshal(fmt.Sprintf("name:%s", quoteSearchValue(orderName)))
+		if err != nil {
+			return err
+		}
+		filter = fmt.Sprintf("(query: %s)", b)
+	}
+	d := &bulkOrderDecoder{fn: fn}
+	if err := BulkQuery(client, fmt.Sprintf(bulkOrdersQuery, filter), opts, d.add); err != nil {
+		return err
+	}
+	return d.flush()
+}
+
+// BulkQuery runs query as bulk operation and calls fn with each line of
+// the JSONL result.
+func BulkQuery(client Client, query string, opts BulkOptions, fn func(line []byte) error) error {
+	op, err := RunBulkQuery(client, query, opts)
+	if err != nil {
+		return err
+	}
+	if op.URL == nil {
+		return nil // no results
+	}
+	body, err := client.Download(*op.URL)
+	if err != nil {
+		return fmt.Errorf("cannot download bulk operation result: %w", err)
+	}
+	defer body.Close()
+	return scanLines(body, fn)
+}
+
+// RunBulkQuery starts a bulk operation for query and polls until it has
+// finished.
+func RunBulkQuery(client Client, query string, opts BulkOptions) (*BulkOperation, error) {
+	if opts.PollInterval <= 0 {
+		return nil, fmt.Errorf("invalid bulk operation poll interval %v", opts.PollInterval)
+	}
+	gql := NewGraphQL(client)
+	result := struct {
+		BulkOperationRunQuery struct {
+			BulkOperation *BulkOperation
+			UserErrors    UserErrors
+		}
+	}{}
+	if err := gql.Do(bulkRunMutation, Vars{"query": query}, &result); err != nil {
+		return nil, err
+	}
+	if err := result.BulkOperationRunQuery.UserErrors.Err(); err != nil {
+		return nil, err
+	}
+	started := result.BulkOperationRunQuery.BulkOperation
+	if started == nil {
+		return nil, fmt.Errorf("bulk operation not started")
+	}
+	var deadline time.Time
+	if opts.Timeout != 0 {
+		deadline = time.Now().Add(opts.Timeout)
+	}
+	for {
+		// poll the operation started above rather than the current one,
+		// which may have been replaced by another bulk query of the app
+		current := struct{ Node *BulkOperation }{}
+		if err := gql.Do(bulkOperationQuery, Vars{"id": started.ID}, &current); err != nil {
+			return nil, err
+		}
+		op := current.Node
+		if op == nil {
+			return nil, fmt.Errorf("bulk operation %s not found", started.ID)
+		}
+		switch op.Status {
+		case "COMPLETED":
+			return op, nil
+		case "CREATED", "RUNNING":
+		default:
+			return nil, fmt.Errorf("bulk operation %s: %s %s", op.ID, strings.ToLower(op.Status), op.ErrorCode)
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return nil, fmt.Errorf("bulk operation %s: timed out after %v", op.ID, opts.Timeout)
+		}
+		if err := sleep(client.Context(), opts.PollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// bulkOrderDecoder stitches line item rows of bulk operation results into
+// their parent order and passes each complete order to fn. Shopify lists
+// child rows right after their parent, so an order is complete once the
+// next order row is read.
+type bulkOrderDecoder struct {
+	fn    func(goshopify.Order) error
+	gid   string // GraphQL ID of order
+	order *goshopify.Order
+}
+
+func decodeBulkOrders(r io.Reader) ([]goshopify.Order, error) {
+	var orders []goshopify.Order
+	d := &bulkOrderDecoder{fn: func(o goshopify.Order) error {
+		orders = append(orders, o)
+		return nil
+	}}
+	if err := scanLines(r, d.add); err != nil {
+		return nil, err
+	}
+	if err := d.flush(); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (d *bulkOrderDecoder) add(line []byte) error {
+	row := bulkRow{}
+	if err := json.Unmarshal(line, &row); err != nil {
+		return err
+	}
+	if row.ParentID != "" {
+		if d.order == nil || row.ParentID != d.gid {
+			return fmt.Errorf("line item %s: parent order %s not found", row.ID, row.ParentID)
+		}
+		li, err := row.lineItem()
+		if err != nil {
+			return err
+		}
+		d.order.LineItems = append(d.order.LineItems, li)
+		return nil
+	}
+	if err := d.flush(); err != nil {
+		return err
+	}
+	o, err := row.order()
+	if err != nil {
+		return err
+	}
+	d.gid, d.order = row.ID, &o
+	return nil
+}
+
+// flush passes the order decoded last to fn.
+func (d *bulkOrderDecoder) flush() error {
+	if d.order == nil {
+		return nil
+	}
+	o := *d.order
+	d.order = nil
+	return d.fn(o)
+}
+
+func scanLines(r io.Reader, fn func(line []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (row bulkRow) order() (goshopify.Order, error) {
+	id, err := IDFromGID(row.ID)
+	if err != nil {
+		return goshopify.Order{}, err
+	}
+	o := goshopify.Order{
+		ID:              id,
+		Name:            row.Name,
+		Email:           row.Email,
+		Phone:           row.Phone,
+		Note:            row.Note,
+		Tags:            strings.Join(row.Tags, ", "),
+		CreatedAt:       row.CreatedAt,
+		ProcessedAt:     row.ProcessedAt,
+		Currency:        row.CurrencyCode,
+		FinancialStatus: strings.ToLower(row.DisplayFinancialStatus),
+	}
+	// REST represents unfulfilled orders with a null fulfillment status
+	if status := strings.ToLower(row.DisplayFulfillmentStatus); status != "unfulfilled" {
+		o.FulfillmentStatus = status
+	}
+	if row.TotalPriceSet != nil {
+		o.TotalPrice = row.TotalPriceSet.ShopMoney.Amount
+	}
+	return o, nil
+}
+
+func (row bulkRow) lineItem() (goshopify.LineItem, error) {
+	id, err := IDFromGID(row.ID)
+	if err != nil {
+		return goshopify.LineItem{}, err
+	}
+	li := goshopify.LineItem{
+		ID:       id,
+		Title:    row.Title,
+		SKU:      row.SKU,
+		Quantity: row.Quantity,
+	}
+	if row.Variant != nil {
+		if li.VariantID, err = IDFromGID(row.Variant.ID); err != nil {
+			return goshopify.LineItem{}, err
+		}
+	}
+	if row.OriginalUnitPriceSet != nil {
+		li.Price = row.OriginalUnitPriceSet.ShopMoney.Amount
+	}
+	return li, nil
+}
@@ -0,0 +1,96 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkList(t *testing.T) {
+	price := decimal.NewFromInt(12)
+	orders := []goshopify.Order{
+		{ID: 1, Name: "order1", Email: "jay@example.com", FinancialStatus: "paid", Tags: "a, b", TotalPrice: &price, LineItems: []goshopify.LineItem{
+			{ID: 11, SKU: "TS-RED-M", VariantID: 43434424271066, Quantity: 1, Price: &price},
+			{ID: 12, Title: "Gift wrap", Quantity: 2},
+		}},
+		{ID: 2, Name: `order "2"`, LineItems: []goshopify.LineItem{{ID: 21, SKU: "TS-RED-L", Quantity: 3}}},
+		{ID: 3, Name: "order3"},
+	}
+	srv := fake.NewServer(orders...)
+	defer srv.Close()
+	srv.Polls = 2
+	opts := BulkOptions{PollInterval: time.Millisecond}
+
+	got, err := BulkList(NewShopifyClient(srv.Client()), "", opts)
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	require.Equal(t, orders[0].LineItems, got[0].LineItems)
+	require.Equal(t, "paid", got[0].FinancialStatus)
+	require.Equal(t, "a, b", got[0].Tags)
+	require.True(t, price.Equal(*got[0].TotalPrice))
+	require.Equal(t, orders[1].LineItems, got[1].LineItems)
+	require.Empty(t, got[2].LineItems)
+
+	got, err = BulkList(NewShopifyClient(srv.Client()), `order "2"`, opts)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, int64(2), got[0].ID)
+
+	got, err = BulkList(NewShopifyClient(srv.Client()), "missing", opts)
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	_, err = BulkList(NewShopifyClient(srv.Client()), "", BulkOptions{})
+	require.EqualError(t, err, "invalid bulk operation poll interval 0s")
+}
+
+func TestDecodeBulkOrders(t *testing.T) {
+	jsonl := `{"id":"gid://shopify/Order/1","name":"order1","displayFulfillmentStatus":"UNFULFILLED"}
+{"id":"gid://shopify/LineItem/11","sku":"A","quantity":1,"__parentId":"gid://shopify/Order/1"}
+{"id":"gid://shopify/Order/2","name":"order2"}
+`
+	got, err := decodeBulkOrders(strings.NewReader(jsonl))
+	require.NoError(t, err)
+	want := []goshopify.Order{{ID: 1, Name: "order1", LineItems: []goshopify.LineItem{{ID: 11, SKU: "A", Quantity: 1}}}, {ID: 2, Name: "order2"}}
+	require.Equal(t, want, got)
+
+	_, err = decodeBulkOrders(strings.NewReader(`{"id":"gid://shopify/LineItem/11","__parentId":"gid://shopify/Order/2"}`))
+	require.Error(t, err)
+}
+
+func TestDownload(t *testing.T) {
+	// bulk operation results may take longer to read than the timeout of
+	// a request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"id": "gid://shopify/Order/1"}`)
+		w.(http.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprintln(w, `{"id": "gid://shopify/Order/2"}`)
+	}))
+	defer srv.Close()
+	client := fake.Client(srv.URL)
+	WithContext(context.Background(), 20*time.Millisecond)(client)
+	body, err := NewShopifyClient(client).Download(srv.URL + "/result.jsonl")
+	require.NoError(t, err)
+	defer body.Close()
+	b, err := io.ReadAll(body)
+	require.NoError(t, err)
+	require.Equal(t, 2, strings.Count(string(b), "\n"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client = fake.Client(srv.URL)
+	WithContext(ctx, time.Minute)(client)
+	_, err = NewShopifyClient(client).Download(srv.URL + "/result.jsonl")
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,502 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// Client is the part of the Shopify Admin API used by orderer: orders
+// with their transactions, refunds and fulfillments, customers, products,
+// locations, inventory levels, metafields and GraphQL requests, which are
+// also used for bulk operations. All functions of this package send their
+// requests through a Client, so that middleware, e.g. for auditing or dry
+// runs, can wrap it. ShopifyClient implements it with go-shopify, tests use
+// it with the server of package fake.
+type Client interface {
+	// Context returns the context of the client's requests. It also ends
+	// waiting for throttled GraphQL requests and bulk operations.
+	Context() context.Context
+	// PostGraphQL sends a GraphQL request without handling throttling,
+	// see GraphQL.
+	PostGraphQL(request GraphQLRequest) (*GraphQLResponse, error)
+	// Download gets the file at url, e.g. the result of a bulk operation,
+	// without limiting the time taken to read its body.
+	Download(url string) (io.ReadCloser, error)
+
+	// ListOrders returns the first page of open orders, optionally
+	// filtered by name.
+	ListOrders(name string) ([]goshopify.Order, error)
+	// ListAllOrders returns all orders, open or closed, optionally
+	// filtered by name and customer ID.
+	ListAllOrders(name string, customerID int64) ([]goshopify.Order, error)
+	// ListOrderPages calls fn with each page of the orders returned by
+	// ListAllOrders as it is received.
+	ListOrderPages(name string, customerID int64, fn func(orders []goshopify.Order) error) error
+	GetOrder(id int64) (*goshopify.Order, error)
+	CreateOrder(order goshopify.Order) (*goshopify.Order, error)
+	// UpdateOrder updates the order with order.ID.
+	UpdateOrder(order goshopify.Order) (*goshopify.Order, error)
+	DeleteOrder(id int64) error
+	CancelOrder(id int64, opts goshopify.OrderCancelOptions) (*goshopify.Order, error)
+	CloseOrder(id int64) (*goshopify.Order, error)
+	OpenOrder(id int64) (*goshopify.Order, error)
+	ListTransactions(orderID int64) ([]goshopify.Transaction, error)
+	CreateTransaction(orderID int64, transaction goshopify.Transaction) (*goshopify.Transaction, error)
+	// CalculateRefund returns Shopify's calculation of refund, including
+	// suggested refund transactions.
+	CalculateRefund(orderID int64, refund Refund) (*Refund, error)
+	CreateRefund(orderID int64, refund Refund) (*Refund, error)
+	ListFulfillmentOrders(orderID int64) ([]FulfillmentOrder, error)
+	CreateFulfillment(request FulfillmentRequest) (*goshopify.Fulfillment, error)
+
+	// ListProducts returns all products matching opts.
+	ListProducts(opts goshopify.ProductListOptions) ([]goshopify.Product, error)
+	GetProduct(id int64) (*goshopify.Product, error)
+	CreateProduct(product goshopify.Product) (*goshopify.Product, error)
+	// UpdateProduct updates the product with product.ID.
+	UpdateProduct(product goshopify.Product) (*goshopify.Product, error)
+	DeleteProduct(id int64) error
+	ListVariants(productID int64) ([]goshopify.Variant, error)
+	GetVariant(id int64) (*goshopify.Variant, error)
+	CreateVariant(productID int64, variant goshopify.Variant) (*goshopify.Variant, error)
+	// UpdateVariant updates the variant with variant.ID.
+	UpdateVariant(variant goshopify.Variant) (*goshopify.Variant, error)
+	DeleteVariant(productID, id int64) error
+	ListLocations() ([]goshopify.Location, error)
+	ListInventoryLevels(inventoryItemID int64) ([]*InventoryLevel, error)
+	AdjustInventoryLevel(adjustment InventoryLevelAdjustment) (*InventoryLevel, error)
+	SetInventoryLevel(level InventoryLevel) (*InventoryLevel, error)
+	// ConnectInventoryLevel stocks level.InventoryItemID at
+	// level.LocationID, keeping an existing inventory level.
+	ConnectInventoryLevel(level InventoryLevel) (*InventoryLevel, error)
+	DisconnectInventoryLevel(locationID, inventoryItemID int64) error
+
+	// ListCustomers returns the first page of customers.
+	ListCustomers() ([]goshopify.Customer, error)
+	GetCustomer(id int64) (*goshopify.Customer, error)
+	// SearchCustomers returns the customers matching a customer search
+	// query such as email:"mary@example.com".
+	SearchCustomers(query string) ([]goshopify.Customer, error)
+	CreateCustomer(customer goshopify.Customer) (*goshopify.Customer, error)
+	// UpdateCustomer updates the customer with customer.ID.
+	UpdateCustomer(customer goshopify.Customer) (*goshopify.Customer, error)
+	DeleteCustomer(id int64) error
+	ListCustomerAddresses(customerID int64) ([]goshopify.CustomerAddress, error)
+	CreateCustomerAddress(customerID int64, address goshopify.CustomerAddress) (*goshopify.CustomerAddress, error)
+
+	// ListMetafields returns the metafields of the resource with the
+	// given ID, e.g. ListMetafields(ResourceOrders, 1).
+	ListMetafields(resource string, id int64) ([]goshopify.Metafield, error)
+	// SaveMetafield creates mf or, if mf.ID is set, updates its value.
+	// The type of a metafield cannot be changed.
+	SaveMetafield(resource string, id int64, mf goshopify.Metafield) (*goshopify.Metafield, error)
+	DeleteMetafield(resource string, id, metafieldID int64) error
+}
+
+// ShopifyClient implements Client with the Shopify Admin REST API.
+type ShopifyClient struct {
+	client *goshopify.Client
+}
+
+// NewShopifyClient returns a Client sending its requests with client.
+func NewShopifyClient(client *goshopify.Client) *ShopifyClient {
+	return &ShopifyClient{client: client}
+}
+
+// Shopify returns the underlying go-shopify client.
+func (c *ShopifyClient) Shopify() *goshopify.Client {
+	return c.client
+}
+
+func (c *ShopifyClient) Context() context.Context {
+	return clientContext(c.client)
+}
+
+func (c *ShopifyClient) PostGraphQL(request GraphQLRequest) (*GraphQLResponse, error) {
+	resp := GraphQLResponse{}
+	if err := c.client.Post("graphql.json", request, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *ShopifyClient) Download(url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(c.Context(), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	// not sent with c.client.Client, whose timeout would also limit
+	// reading the body, which may take long for large files. The request
+	// is still aborted with the client's context.
+	httpClient := &http.Client{Transport: c.client.Client.Transport}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("download failed: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func (c *ShopifyClient) ListOrders(name string) ([]goshopify.Order, error) {
+	if name == "" {
+		return c.client.Order.List(nil)
+	}
+	resource := goshopify.OrdersResource{}
+	query := struct {
+		Name string `url:"name"`
+	}{Name: name}
+	if err := c.client.Get("orders.json", &resource, query); err != nil {
+		return nil, err
+	}
+	return resource.Orders, nil
+}
+
+func (c *ShopifyClient) ListAllOrders(name string, customerID int64) ([]goshopify.Order, error) {
+	var result []goshopify.Order
+	err := c.ListOrderPages(name, customerID, func(orders []goshopify.Order) error {
+		result = append(result, orders...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *ShopifyClient) ListOrderPages(name string, customerID int64, fn func(orders []goshopify.Order) error) error {
+	query := struct {
+		goshopify.ListOptions
+		Name       string `url:"name,omitempty"`
+		CustomerID int64  `url:"customer_id,omitempty"`
+		Status     string `url:"status,omitempty"`
+	}{Name: name, CustomerID: customerID, Status: "any"}
+	query.Limit = 250
+	var opts interface{} = query
+	for {
+		orders, pagination, err := c.client.Order.ListWithPagination(opts)
+		if err != nil {
+			return err
+		}
+		if err := fn(orders); err != nil {
+			return err
+		}
+		if pagination == nil || pagination.NextPageOptions == nil {
+			return nil
+		}
+		opts = pagination.NextPageOptions
+	}
+}
+
+func (c *ShopifyClient) GetOrder(id int64) (*goshopify.Order, error) {
+	return c.client.Order.Get(id, nil)
+}
+
+func (c *ShopifyClient) CreateOrder(order goshopify.Order) (*goshopify.Order, error) {
+	return c.client.Order.Create(order)
+}
+
+func (c *ShopifyClient) UpdateOrder(order goshopify.Order) (*goshopify.Order, error) {
+	return c.client.Order.Update(order)
+}
+
+func (c *ShopifyClient) DeleteOrder(id int64) error {
+	return c.client.Delete(fmt.Sprintf("orders/%d.json", id))
+}
+
+func (c *ShopifyClient) CancelOrder(id int64, opts goshopify.OrderCancelOptions) (*goshopify.Order, error) {
+	return c.client.Order.Cancel(id, opts)
+}
+
+func (c *ShopifyClient) CloseOrder(id int64) (*goshopify.Order, error) {
+	return c.client.Order.Close(id)
+}
+
+func (c *ShopifyClient) OpenOrder(id int64) (*goshopify.Order, error) {
+	return c.client.Order.Open(id)
+}
+
+func (c *ShopifyClient) ListTransactions(orderID int64) ([]goshopify.Transaction, error) {
+	resource := goshopify.TransactionsResource{}
+	if err := c.client.Get(fmt.Sprintf("orders/%d/transactions.json", orderID), &resource, nil); err != nil {
+		return nil, err
+	}
+	return resource.Transactions, nil
+}
+
+func (c *ShopifyClient) CreateTransaction(orderID int64, transaction goshopify.Transaction) (*goshopify.Transaction, error) {
+	return c.client.Transaction.Create(orderID, transaction)
+}
+
+func (c *ShopifyClient) CalculateRefund(orderID int64, refund Refund) (*Refund, error) {
+	resource := RefundResource{}
+	path := fmt.Sprintf("orders/%d/refunds/calculate.json", orderID)
+	if err := c.client.Post(path, RefundResource{Refund: &refund}, &resource); err != nil {
+		return nil, err
+	}
+	return resource.Refund, nil
+}
+
+func (c *ShopifyClient) CreateRefund(orderID int64, refund Refund) (*Refund, error) {
+	resource := RefundResource{}
+	path := fmt.Sprintf("orders/%d/refunds.json", orderID)
+	if err := c.client.Post(path, RefundResource{Refund: &refund}, &resource); err != nil {
+		return nil, err
+	}
+	return resource.Refund, nil
+}
+
+func (c *ShopifyClient) ListFulfillmentOrders(orderID int64) ([]FulfillmentOrder, error) {
+	resource := FulfillmentOrdersResource{}
+	if err := c.client.Get(fmt.Sprintf("orders/%d/fulfillment_orders.json", orderID), &resource, nil); err != nil {
+		return nil, err
+	}
+	return resource.FulfillmentOrders, nil
+}
+
+func (c *ShopifyClient) CreateFulfillment(request FulfillmentRequest) (*goshopify.Fulfillment, error) {
+	resource := goshopify.FulfillmentResource{}
+	body := struct {
+		Fulfillment FulfillmentRequest `json:"fulfillment"`
+	}{Fulfillment: request}
+	if err := c.client.Post("fulfillments.json", body, &resource); err != nil {
+		return nil, err
+	}
+	return resource.Fulfillment, nil
+}
+
+func (c *ShopifyClient) ListProducts(opts goshopify.ProductListOptions) ([]goshopify.Product, error) {
+	opts.Limit = 250
+	var result []goshopify.Product
+	var query interface{} = opts
+	for {
+		products, pagination, err := c.client.Product.ListWithPagination(query)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, products...)
+		if pagination == nil || pagination.NextPageOptions == nil {
+			return result, nil
+		}
+		query = pagination.NextPageOptions
+	}
+}
+
+func (c *ShopifyClient) GetProduct(id int64) (*goshopify.Product, error) {
+	return c.client.Product.Get(id, nil)
+}
+
+func (c *ShopifyClient) CreateProduct(product goshopify.Product) (*goshopify.Product, error) {
+	return c.client.Product.Create(product)
+}
+
+func (c *ShopifyClient) UpdateProduct(product goshopify.Product) (*goshopify.Product, error) {
+	return c.client.Product.Update(product)
+}
+
+func (c *ShopifyClient) DeleteProduct(id int64) error {
+	return c.client.Product.Delete(id)
+}
+
+func (c *ShopifyClient) ListVariants(productID int64) ([]goshopify.Variant, error) {
+	return c.client.Variant.List(productID, nil)
+}
+
+func (c *ShopifyClient) GetVariant(id int64) (*goshopify.Variant, error) {
+	return c.client.Variant.Get(id, nil)
+}
+
+func (c *ShopifyClient) CreateVariant(productID int64, variant goshopify.Variant) (*goshopify.Variant, error) {
+	return c.client.Variant.Create(productID, variant)
+}
+
+func (c *ShopifyClient) UpdateVariant(variant goshopify.Variant) (*goshopify.Variant, error) {
+	return c.client.Variant.Update(variant)
+}
+
+func (c *ShopifyClient) DeleteVariant(productID, id int64) error {
+	return c.client.Variant.Delete(productID, id)
+}
+
+func (c *ShopifyClient) ListLocations() ([]goshopify.Location, error) {
+	return c.client.Location.List(nil)
+}
+
+func (c *ShopifyClient) ListInventoryLevels(inventoryItemID int64) ([]*InventoryLevel, error) {
+	query := struct {
+		InventoryItemID int64 `url:"inventory_item_ids"`
+	}{InventoryItemID: inventoryItemID}
+	resource := InventoryLevelsResource{}
+	if err := c.client.Get("inventory_levels.json", &resource, query); err != nil {
+		return nil, err
+	}
+	return resource.InventoryLevels, nil
+}
+
+func (c *ShopifyClient) AdjustInventoryLevel(adjustment InventoryLevelAdjustment) (*InventoryLevel, error) {
+	resource := InventoryLevelResource{}
+	if err := c.client.Post("inventory_levels/adjust.json", adjustment, &resource); err != nil {
+		return nil, err
+	}
+	return resource.InventoryLevel, nil
+}
+
+func (c *ShopifyClient) SetInventoryLevel(level InventoryLevel) (*InventoryLevel, error) {
+	resource := InventoryLevelResource{}
+	if err := c.client.Post("inventory_levels/set.json", level, &resource); err != nil {
+		return nil, err
+	}
+	return resource.InventoryLevel, nil
+}
+
+func (c *ShopifyClient) ConnectInventoryLevel(level InventoryLevel) (*InventoryLevel, error) {
+	resource := InventoryLevelResource{}
+	if err := c.client.Post("inventory_levels/connect.json", level, &resource); err != nil {
+		return nil, err
+	}
+	return resource.InventoryLevel, nil
+}
+
+func (c *ShopifyClient) DisconnectInventoryLevel(locationID, inventoryItemID int64) error {
+	return c.client.Delete(fmt.Sprintf("inventory_levels.json?inventory_item_id=%d&location_id=%d", inventoryItemID, locationID))
+}
+
+func (c *ShopifyClient) ListCustomers() ([]goshopify.Customer, error) {
+	return c.client.Customer.List(nil)
+}
+
+func (c *ShopifyClient) GetCustomer(id int64) (*goshopify.Customer, error) {
+	return c.client.Customer.Get(id, nil)
+}
+
+func (c *ShopifyClient) SearchCustomers(query string) ([]goshopify.Customer, error) {
+	return c.client.Customer.Search(goshopify.CustomerSearchOptions{Query: query})
+}
+
+func (c *ShopifyClient) CreateCustomer(customer goshopify.Customer) (*goshopify.Customer, error) {
+	return c.client.Customer.Create(customer)
+}
+
+func (c *ShopifyClient) UpdateCustomer(customer goshopify.Customer) (*goshopify.Customer, error) {
+	return c.client.Customer.Update(customer)
+}
+
+func (c *ShopifyClient) DeleteCustomer(id int64) error {
+	return c.client.Customer.Delete(id)
+}
+
+func (c *ShopifyClient) ListCustomerAddresses(customerID int64) ([]goshopify.CustomerAddress, error) {
+	return c.client.CustomerAddress.List(customerID, nil)
+}
+
+func (c *ShopifyClient) CreateCustomerAddress(customerID int64, address goshopify.CustomerAddress) (*goshopify.CustomerAddress, error) {
+	return c.client.CustomerAddress.Create(customerID, address)
+}
+
+func (c *ShopifyClient) ListMetafields(resource string, id int64) ([]goshopify.Metafield, error) {
+	path := goshopify.MetafieldPathPrefix(resource, id) + ".json"
+	var metafields []goshopify.Metafield
+	var opts interface{} = goshopify.ListOptions{Limit: 250}
+	for opts != nil {
+		page := goshopify.MetafieldsResource{}
+		var err error
+		if opts, err = c.getPage(path, &page, opts); err != nil {
+			return nil, err
+		}
+		metafields = append(metafields, page.Metafields...)
+	}
+	return metafields, nil
+}
+
+// getPage gets a page of a REST list endpoint without go-shopify support
+// for pagination and returns the options for the next page, nil for the
+// last page.
+func (c *ShopifyClient) getPage(path string, resource, opts interface{}) (interface{}, error) {
+	// go-shopify does not return response headers, record them with a
+	// copy of the client
+	header := http.Header{}
+	client := *c.client
+	httpClient := *client.Client
+	httpClient.Transport = headerTransport{base: httpClient.Transport, header: header}
+	client.Client = &httpClient
+	if err := client.Get(path, resource, opts); err != nil {
+		return nil, err
+	}
+	return nextPageOptions(header.Get("Link"))
+}
+
+// headerTransport copies the headers of the last response into header.
+type headerTransport struct {
+	base   http.RoundTripper
+	header http.Header
+}
+
+func (t headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(r)
+	if err == nil {
+		for k := range t.header {
+			delete(t.header, k)
+		}
+		for k, v := range resp.Header {
+			t.header[k] = v
+		}
+	}
+	return resp, err
+}
+
+// nextPageOptions returns the options for the page linked as rel="next"
+// in a Link header, e.g. <https://...?page_info=abc&limit=250>; rel="next".
+func nextPageOptions(link string) (interface{}, error) {
+	for _, l := range strings.Split(link, ",") {
+		parts := strings.Split(l, ";")
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) != `rel="next"` {
+			continue
+		}
+		u, err := url.Parse(strings.Trim(strings.TrimSpace(parts[0]), "<>"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid link header %q: %w", link, err)
+		}
+		limit, _ := strconv.Atoi(u.Query().Get("limit"))
+		return goshopify.ListOptions{PageInfo: u.Query().Get("page_info"), Limit: limit}, nil
+	}
+	return nil, nil
+}
+
+func (c *ShopifyClient) SaveMetafield(resource string, id int64, mf goshopify.Metafield) (*goshopify.Metafield, error) {
+	prefix := goshopify.MetafieldPathPrefix(resource, id)
+	body := goshopify.MetafieldResource{Metafield: &goshopify.Metafield{
+		ID:        mf.ID,
+		Namespace: mf.Namespace,
+		Key:       mf.Key,
+		Type:      mf.Type,
+		Value:     mf.Value,
+	}}
+	result := goshopify.MetafieldResource{}
+	var err error
+	if mf.ID == 0 {
+		err = c.client.Post(prefix+".json", body, &result)
+	} else {
+		err = c.client.Put(fmt.Sprintf("%s/%d.json", prefix, mf.ID), body, &result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result.Metafield, nil
+}
+
+func (c *ShopifyClient) DeleteMetafield(resource string, id, metafieldID int64) error {
+	return c.client.Delete(fmt.Sprintf("%s/%d.json", goshopify.MetafieldPathPrefix(resource, id), metafieldID))
+}
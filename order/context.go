@@ -0,0 +1,134 @@
+package order
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// The go-shopify client does not take a context, so the context of its
+// requests is set when the client is created, see WithContext, and
+// returned by Client.Context. Cancelling the context or reaching its
+// deadline aborts the requests in flight.
+//
+// Batch functions, e.g. DeleteContext or ImportCustomersContext, check ctx
+// before each order, customer or product instead. They create a client
+// for OperationContext(ctx) for each item, see RunOperation, so that an
+// item in flight is completed when ctx is cancelled and the batch stops
+// before the next one. They return the results so far together with
+// ctx.Err(). Functions changing a single order, e.g. CreateContext or
+// MergeContext, run it as such an operation, functions only reading, e.g.
+// ListContext, send their requests with ctx.
+
+type operationTimeoutKey struct{}
+
+// ClientFunc returns a client whose requests use ctx.
+type ClientFunc func(ctx context.Context) Client
+
+// contextTransport sets the context of all requests.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+// detachedContext carries the values of a context but is never
+// cancelled.
+type detachedContext struct {
+	context.Context
+}
+
+// WithContext returns a go-shopify client option making all requests of
+// the client use ctx, each limited to timeout.
+func WithContext(ctx context.Context, timeout time.Duration) goshopify.Option {
+	return func(c *goshopify.Client) {
+		c.Client = &http.Client{Timeout: timeout, Transport: contextTransport{ctx: ctx, base: c.Client.Transport}}
+	}
+}
+
+// WithOperationTimeout returns a copy of ctx with the deadline used by
+// OperationContext.
+func WithOperationTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, operationTimeoutKey{}, timeout)
+}
+
+// OperationContext returns the context for a single operation, e.g.
+// creating an order with its fulfillments, started within ctx. It has the
+// values of ctx and the deadline set with WithOperationTimeout, but is not
+// cancelled with ctx.
+func OperationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	opCtx := context.Context(detachedContext{ctx})
+	if timeout, _ := ctx.Value(operationTimeoutKey{}).(time.Duration); timeout > 0 {
+		return context.WithTimeout(opCtx, timeout)
+	}
+	return context.WithCancel(opCtx)
+}
+
+// RunOperation runs fn as single operation of a batch with a client
+// created by newClient for OperationContext(ctx). fn is not run if ctx is
+// done.
+func RunOperation[C any](ctx context.Context, newClient func(context.Context) C, fn func(client C) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	opCtx, cancel := OperationContext(ctx)
+	defer cancel()
+	return fn(newClient(opCtx))
+}
+
+// operationResult runs fn like RunOperation and returns its result.
+func operationResult[T any](ctx context.Context, newClient ClientFunc, fn func(client Client) (T, error)) (T, error) {
+	var result T
+	err := RunOperation(ctx, newClient, func(client Client) error {
+		var err error
+		result, err = fn(client)
+		return err
+	})
+	return result, err
+}
+
+// clientContext returns the context of the requests of client.
+func clientContext(client *goshopify.Client) context.Context {
+	if t, ok := client.Client.Transport.(contextTransport); ok {
+		return t.ctx
+	}
+	return context.Background()
+}
+
+// staticClient returns a ClientFunc returning client for any context.
+func staticClient[C any](client C) func(context.Context) C {
+	return func(context.Context) C { return client }
+}
+
+// sleep pauses for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (t contextTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(r.WithContext(t.ctx))
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
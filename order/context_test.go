@@ -0,0 +1,115 @@
+package order
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+// hookTransport calls after for each request sent.
+type hookTransport struct {
+	base  http.RoundTripper
+	after func(r *http.Request)
+}
+
+func (t hookTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(r)
+	t.after(r)
+	return resp, err
+}
+
+func TestWithContext(t *testing.T) {
+	srv := fake.NewServer(goshopify.Order{ID: 1, Name: "order1"})
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client := srv.Client()
+	WithContext(ctx, time.Minute)(client)
+	require.Equal(t, ctx, clientContext(client))
+	require.Equal(t, time.Minute, client.Client.Timeout)
+	_, err := List(NewShopifyClient(client), "order1")
+	require.ErrorIs(t, err, context.Canceled)
+
+	client = srv.Client()
+	require.Equal(t, context.Background(), clientContext(client))
+	WithContext(context.Background(), time.Minute)(client)
+	orders, err := List(NewShopifyClient(client), "order1")
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+}
+
+func TestOperationContext(t *testing.T) {
+	type key struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "v"))
+	ctx = WithOperationTimeout(ctx, time.Hour)
+	opCtx, opCancel := OperationContext(ctx)
+	defer opCancel()
+	cancel()
+	require.NoError(t, opCtx.Err())
+	require.Equal(t, "v", opCtx.Value(key{}))
+	deadline, ok := opCtx.Deadline()
+	require.True(t, ok)
+	require.WithinDuration(t, time.Now().Add(time.Hour), deadline, time.Minute)
+
+	opCtx, opCancel = OperationContext(context.Background())
+	defer opCancel()
+	_, ok = opCtx.Deadline()
+	require.False(t, ok)
+}
+
+func TestDeleteContextStopsAfterOrderInFlight(t *testing.T) {
+	srv := fake.NewServer(goshopify.Order{ID: 1, Name: "order1"}, goshopify.Order{ID: 2, Name: "order1"}, goshopify.Order{ID: 3, Name: "order1"})
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	newClient := func(ctx context.Context) Client {
+		client := srv.Client()
+		client.Client.Transport = hookTransport{base: client.Client.Transport, after: func(r *http.Request) {
+			if r.Method == http.MethodDelete {
+				cancel()
+			}
+		}}
+		WithContext(ctx, time.Minute)(client)
+		return NewShopifyClient(client)
+	}
+
+	ids, err := DeleteContext(ctx, newClient, "order1", DeleteOptions{Max: -1})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, []int64{1}, ids)
+	require.Len(t, srv.Orders(), 2)
+}
+
+func TestOrderContext(t *testing.T) {
+	srv := fake.NewServer(goshopify.Order{ID: 1, Name: "order1"})
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	newClient := func(ctx context.Context) Client {
+		client := srv.Client()
+		client.Client.Transport = hookTransport{base: client.Client.Transport, after: func(r *http.Request) {
+			if r.Method == http.MethodGet {
+				cancel()
+			}
+		}}
+		WithContext(ctx, time.Minute)(client)
+		return NewShopifyClient(client)
+	}
+
+	// the update is completed after the order has been found
+	result, err := MergeContext(ctx, newClient, &goshopify.Order{Name: "order1", Email: "mary@example.com"}, MergeOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "updated", result.Label)
+	require.Equal(t, "mary@example.com", srv.Orders()[0].Email)
+
+	_, err = ListContext(ctx, newClient, "order1")
+	require.ErrorIs(t, err, context.Canceled)
+	_, err = CreateContext(ctx, newClient, &goshopify.Order{Name: "order2"}, CreateOptions{})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Len(t, srv.Orders(), 1)
+}
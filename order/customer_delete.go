@@ -0,0 +1,123 @@
+package order
+
+import (
+	"fmt"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// Customer delete actions.
+const (
+	CustomerDeleted  = "deleted"
+	CustomerRedacted = "redacted"
+	CustomerKept     = "kept"
+)
+
+type CustomerDeleteOptions struct {
+	// Cascade deletes the customer's orders, or reassigns them to
+	// ReassignTo if set, before deleting the customer.
+	Cascade    bool
+	ReassignTo int64
+	// Redact requests erasure of the customer's personal data instead of
+	// deleting the customer, which keeps orders intact.
+	Redact bool
+	DryRun bool
+}
+
+type CustomerDeleteResult struct {
+	CustomerID int64   `json:"customer_id"`
+	Action     string  `json:"action"`
+	Orders     []int64 `json:"orders,omitempty"`
+	// OrderAction is what happened to Orders: deleted or reassigned.
+	OrderAction string `json:"order_action,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+const customerDataErasureMutation = `mutation($customerId: ID!) {
+  customerRequestDataErasure(customerId: $customerId) {
+    customerId
+    userErrors { field message }
+  }
+}`
+
+// CustomerOrders returns all open and closed orders of the customer,
+// paging through the REST API.
+func CustomerOrders(client Client, customerID int64) ([]goshopify.Order, error) {
+	return client.ListAllOrders("", customerID)
+}
+
+// DeleteCustomer deletes the customer with the given ID after checking
+// for orders, which Shopify does not allow to be orphaned. A customer with
+// orders is kept unless opts.Cascade or opts.Redact is set. With DryRun
+// the result describes what would be done.
+func DeleteCustomer(client Client, customerID int64, opts CustomerDeleteOptions) (*CustomerDeleteResult, error) {
+	if opts.Cascade && opts.Redact {
+		return nil, fmt.Errorf("customer %d: cascade and redact are mutually exclusive", customerID)
+	}
+	if opts.ReassignTo != 0 && !opts.Cascade {
+		return nil, fmt.Errorf("customer %d: reassigning orders requires cascade", customerID)
+	}
+	result := &CustomerDeleteResult{CustomerID: customerID}
+	if opts.Redact {
+		result.Action = CustomerRedacted
+		if opts.DryRun {
+			return result, nil
+		}
+		return result, RequestCustomerDataErasure(client, customerID)
+	}
+	orders, err := CustomerOrders(client, customerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range orders {
+		result.Orders = append(result.Orders, o.ID)
+	}
+	if len(orders) != 0 && !opts.Cascade {
+		result.Action = CustomerKept
+		result.Reason = fmt.Sprintf("customer has %d orders, use cascade or redact", len(orders))
+		return result, nil
+	}
+	result.Action = CustomerDeleted
+	if len(orders) != 0 {
+		result.OrderAction = "deleted"
+		if opts.ReassignTo != 0 {
+			result.OrderAction = "reassigned"
+		}
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+	for _, o := range orders {
+		if opts.ReassignTo != 0 {
+			update := goshopify.Order{ID: o.ID, Customer: &goshopify.Customer{ID: opts.ReassignTo}}
+			if _, err := client.UpdateOrder(update); err != nil {
+				return result, fmt.Errorf("customer %d: cannot reassign order %d: %w", customerID, o.ID, err)
+			}
+			continue
+		}
+		if err := DeleteByID(client, o.ID); err != nil {
+			return result, fmt.Errorf("customer %d: cannot delete order %d: %w", customerID, o.ID, err)
+		}
+	}
+	if err := client.DeleteCustomer(customerID); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// RequestCustomerDataErasure asks Shopify to erase the customer's
+// personal data. Erasure happens asynchronously, the customer's orders
+// are kept.
+func RequestCustomerDataErasure(client Client, customerID int64) error {
+	result := struct {
+		CustomerRequestDataErasure struct {
+			CustomerID string
+			UserErrors UserErrors
+		}
+	}{}
+	vars := Vars{"customerId": GID("Customer", customerID)}
+	if err := NewGraphQL(client).Do(customerDataErasureMutation, vars, &result); err != nil {
+		return err
+	}
+	return result.CustomerRequestDataErasure.UserErrors.Err()
+}
@@ -0,0 +1,59 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteCustomer(t *testing.T) {
+	srv := fake.NewServer(
+		goshopify.Order{ID: 11, Name: "order1", Customer: &goshopify.Customer{ID: 1}},
+		goshopify.Order{ID: 12, Name: "order2", Customer: &goshopify.Customer{ID: 1}},
+		goshopify.Order{ID: 21, Name: "order3", Customer: &goshopify.Customer{ID: 2}},
+	)
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	srv.AddCustomers(goshopify.Customer{ID: 1}, goshopify.Customer{ID: 2}, goshopify.Customer{ID: 3}, goshopify.Customer{ID: 4})
+
+	result, err := DeleteCustomer(client, 1, CustomerDeleteOptions{})
+	require.NoError(t, err)
+	require.Equal(t, &CustomerDeleteResult{CustomerID: 1, Action: CustomerKept, Orders: []int64{11, 12}, Reason: "customer has 2 orders, use cascade or redact"}, result)
+
+	result, err = DeleteCustomer(client, 1, CustomerDeleteOptions{Cascade: true, DryRun: true})
+	require.NoError(t, err)
+	require.Equal(t, CustomerDeleted, result.Action)
+	require.Len(t, srv.Orders(), 3)
+
+	result, err = DeleteCustomer(client, 1, CustomerDeleteOptions{Cascade: true, ReassignTo: 3})
+	require.NoError(t, err)
+	require.Equal(t, "reassigned", result.OrderAction)
+	orders := srv.Orders()
+	require.Len(t, orders, 3)
+	require.Equal(t, int64(3), orders[0].Customer.ID)
+
+	result, err = DeleteCustomer(client, 2, CustomerDeleteOptions{Cascade: true})
+	require.NoError(t, err)
+	require.Equal(t, &CustomerDeleteResult{CustomerID: 2, Action: CustomerDeleted, Orders: []int64{21}, OrderAction: "deleted"}, result)
+	require.Len(t, srv.Orders(), 2)
+
+	result, err = DeleteCustomer(client, 3, CustomerDeleteOptions{Redact: true})
+	require.NoError(t, err)
+	require.Equal(t, CustomerRedacted, result.Action)
+	require.True(t, srv.ErasureRequested(3))
+	require.Len(t, srv.Orders(), 2)
+
+	result, err = DeleteCustomer(client, 4, CustomerDeleteOptions{})
+	require.NoError(t, err)
+	require.Equal(t, CustomerDeleted, result.Action)
+	require.Len(t, srv.Customers(), 1)
+
+	_, err = DeleteCustomer(client, 99, CustomerDeleteOptions{Redact: true})
+	require.Error(t, err)
+	_, err = DeleteCustomer(client, 3, CustomerDeleteOptions{Cascade: true, Redact: true})
+	require.Error(t, err)
+	_, err = DeleteCustomer(client, 3, CustomerDeleteOptions{ReassignTo: 4})
+	require.Error(t, err)
+}
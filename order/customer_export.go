@@ -0,0 +1,156 @@
+package order
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// CustomerExport holds the data stored about a customer, e.g. to answer
+// a data subject access request.
+type CustomerExport struct {
+	ExportedAt time.Time                   `json:"exported_at"`
+	Customer   *goshopify.Customer         `json:"customer"`
+	Addresses  []goshopify.CustomerAddress `json:"addresses"`
+	Metafields []goshopify.Metafield       `json:"metafields"`
+	Orders     []CustomerExportOrder       `json:"orders"`
+}
+
+type CustomerExportOrder struct {
+	Order        goshopify.Order         `json:"order"`
+	Metafields   []goshopify.Metafield   `json:"metafields"`
+	Transactions []goshopify.Transaction `json:"transactions"`
+}
+
+// ExportCustomer gathers the customer with the given ID, its addresses
+// and metafields and all its orders with their metafields and
+// transactions.
+func ExportCustomer(client Client, customerID int64) (*CustomerExport, error) {
+	customer, err := client.GetCustomer(customerID)
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := client.ListCustomerAddresses(customerID)
+	if err != nil {
+		return nil, fmt.Errorf("customer %d: cannot list addresses: %w", customerID, err)
+	}
+	metafields, err := Metafields(client, ResourceCustomers, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("customer %d: cannot list metafields: %w", customerID, err)
+	}
+	orders, err := CustomerOrders(client, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("customer %d: cannot list orders: %w", customerID, err)
+	}
+	export := &CustomerExport{
+		ExportedAt: time.Now().UTC(),
+		Customer:   customer,
+		Addresses:  addresses,
+		Metafields: metafields,
+		Orders:     make([]CustomerExportOrder, len(orders)),
+	}
+	for i, o := range orders {
+		export.Orders[i].Order = o
+		if export.Orders[i].Metafields, err = Meta(client, o.ID); err != nil {
+			return nil, fmt.Errorf("order %q: cannot list metafields: %w", o.Name, err)
+		}
+		if export.Orders[i].Transactions, err = Transactions(client, o.ID); err != nil {
+			return nil, fmt.Errorf("order %q: cannot list transactions: %w", o.Name, err)
+		}
+	}
+	return export, nil
+}
+
+// WriteArchive writes a zip archive containing the export as
+// customer.json and a human-readable summary.txt.
+func (e *CustomerExport) WriteArchive(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	header := &zip.FileHeader{Name: "customer.json", Method: zip.Deflate, Modified: e.ExportedAt}
+	f, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(e); err != nil {
+		return err
+	}
+	header = &zip.FileHeader{Name: "summary.txt", Method: zip.Deflate, Modified: e.ExportedAt}
+	if f, err = zw.CreateHeader(header); err != nil {
+		return err
+	}
+	if err := e.WriteSummary(f); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// WriteSummary writes a plain text overview of the export.
+func (e *CustomerExport) WriteSummary(w io.Writer) error {
+	c := e.Customer
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Customer data export of %s\n\n", e.ExportedAt.Format(time.RFC3339))
+	fmt.Fprintf(tw, "ID:\t%d\n", c.ID)
+	fmt.Fprintf(tw, "Name:\t%s\n", strings.TrimSpace(c.FirstName+" "+c.LastName))
+	fmt.Fprintf(tw, "Email:\t%s\n", c.Email)
+	fmt.Fprintf(tw, "Phone:\t%s\n", c.Phone)
+	fmt.Fprintf(tw, "State:\t%s\n", c.State)
+	fmt.Fprintf(tw, "Accepts marketing:\t%t\n", c.AcceptsMarketing)
+	fmt.Fprintf(tw, "Tags:\t%s\n", c.Tags)
+	fmt.Fprintf(tw, "Note:\t%s\n", c.Note)
+	if c.CreatedAt != nil {
+		fmt.Fprintf(tw, "Created:\t%s\n", c.CreatedAt.Format(time.RFC3339))
+	}
+	fmt.Fprintf(tw, "\nAddresses (%d)\n", len(e.Addresses))
+	for _, a := range e.Addresses {
+		fields := []string{}
+		for _, f := range []string{a.FirstName + " " + a.LastName, a.Company, a.Address1, a.Address2, a.City, a.Province, a.Zip, a.Country, a.Phone} {
+			if f = strings.TrimSpace(f); f != "" {
+				fields = append(fields, f)
+			}
+		}
+		def := ""
+		if a.Default {
+			def = " (default)"
+		}
+		fmt.Fprintf(tw, "  %d%s:\t%s\n", a.ID, def, strings.Join(fields, ", "))
+	}
+	writeMetafieldSummary(tw, "  ", e.Metafields)
+	fmt.Fprintf(tw, "\nOrders (%d)\n", len(e.Orders))
+	for _, o := range e.Orders {
+		created := ""
+		if o.Order.CreatedAt != nil {
+			created = o.Order.CreatedAt.Format("2006-01-02")
+		}
+		total := ""
+		if o.Order.TotalPrice != nil {
+			total = o.Order.TotalPrice.StringFixed(2) + " " + o.Order.Currency
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%d line items\n", o.Order.Name, created, o.Order.FinancialStatus, total, len(o.Order.LineItems))
+		for _, t := range o.Transactions {
+			amount := ""
+			if t.Amount != nil {
+				amount = t.Amount.StringFixed(2) + " " + t.Currency
+			}
+			fmt.Fprintf(tw, "    transaction %d:\t%s\t%s\t%s\t%s\n", t.ID, t.Kind, t.Status, amount, t.Gateway)
+		}
+		writeMetafieldSummary(tw, "    ", o.Metafields)
+	}
+	return tw.Flush()
+}
+
+func writeMetafieldSummary(w io.Writer, indent string, metafields []goshopify.Metafield) {
+	if len(metafields) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%sMetafields (%d)\n", indent, len(metafields))
+	for _, mf := range metafields {
+		fmt.Fprintf(w, "%s  %s.%s:\t%v\n", indent, mf.Namespace, mf.Key, mf.Value)
+	}
+}
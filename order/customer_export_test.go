@@ -0,0 +1,73 @@
+package order
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportCustomer(t *testing.T) {
+	amount := decimal.RequireFromString("10.50")
+	var orders []goshopify.Order
+	for i := 1; i <= 260; i++ {
+		orders = append(orders, goshopify.Order{ID: int64(i), Name: fmt.Sprintf("#%d", i), Customer: &goshopify.Customer{ID: 1}})
+	}
+	orders[0].Transactions = []goshopify.Transaction{{ID: 7, Kind: "sale", Status: "success", Amount: &amount, Currency: "AUD"}}
+	orders = append(orders, goshopify.Order{ID: 999, Name: "#999", Customer: &goshopify.Customer{ID: 2}})
+	srv := fake.NewServer(orders...)
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	address := &goshopify.CustomerAddress{ID: 5, CustomerID: 1, Address1: "1 Main St", City: "Melbourne", Country: "Australia", Default: true}
+	srv.AddCustomers(
+		goshopify.Customer{ID: 1, FirstName: "Jo", LastName: "Citizen", Email: "jo@example.com", Addresses: []*goshopify.CustomerAddress{address}, DefaultAddress: address},
+		goshopify.Customer{ID: 2},
+	)
+	_, err := SetMetafield(client, ResourceCustomers, 1, goshopify.Metafield{Namespace: "crm", Key: "id", Value: "c-1", Type: "single_line_text_field"})
+	require.NoError(t, err)
+	_, err = SetMetafield(client, ResourceOrders, 1, goshopify.Metafield{Namespace: "erp", Key: "ref", Value: "r-1", Type: "single_line_text_field"})
+	require.NoError(t, err)
+
+	export, err := ExportCustomer(client, 1)
+	require.NoError(t, err)
+	require.Equal(t, "jo@example.com", export.Customer.Email)
+	require.Len(t, export.Addresses, 1)
+	require.Len(t, export.Metafields, 1)
+	require.Len(t, export.Orders, 260)
+	require.Equal(t, "#1", export.Orders[0].Order.Name)
+	require.Len(t, export.Orders[0].Transactions, 1)
+	require.Len(t, export.Orders[0].Metafields, 1)
+	require.Empty(t, export.Orders[1].Transactions)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, export.WriteArchive(buf))
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 2)
+	require.Equal(t, "customer.json", zr.File[0].Name)
+	require.Equal(t, "summary.txt", zr.File[1].Name)
+	f, err := zr.File[0].Open()
+	require.NoError(t, err)
+	got := CustomerExport{}
+	require.NoError(t, json.NewDecoder(f).Decode(&got))
+	require.Len(t, got.Orders, 260)
+	f, err = zr.File[1].Open()
+	require.NoError(t, err)
+	summary, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Contains(t, string(summary), "Name:               Jo Citizen\n")
+	require.Contains(t, string(summary), "Orders (260)\n")
+	require.Contains(t, string(summary), "crm.id:")
+	require.Contains(t, string(summary), "transaction 7:")
+	require.Contains(t, string(summary), "10.50 AUD")
+
+	_, err = ExportCustomer(client, 12345)
+	require.Error(t, err)
+}
@@ -0,0 +1,619 @@
+package order
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// Customer import match keys.
+const (
+	KeyEmail      = "email"
+	KeyPhone      = "phone"
+	KeyExternalID = "external_id"
+)
+
+// Customer import actions.
+const (
+	ImportCreated    = "created"
+	ImportUpdated    = "updated"
+	ImportSkipped    = "skipped"
+	ImportConflicted = "conflicted"
+)
+
+// CustomerRecord is a customer to be imported. ExternalID is stored in
+// the customer metafield given by CustomerImportOptions.ExternalID.
+type CustomerRecord struct {
+	goshopify.Customer
+	ExternalID string `json:"external_id,omitempty"`
+}
+
+type CustomerImportOptions struct {
+	// Keys are the keys customers are matched by, within the input and
+	// against the store: email, phone and external_id.
+	Keys []string
+	// ExternalID is the customer metafield holding the external ID as
+	// "namespace.key".
+	ExternalID string
+	DryRun     bool
+	// PhoneRegion is the region of phone numbers without country code if
+	// the customer has no address country, see NormalisePhone.
+	PhoneRegion string
+	// Bulk configures the bulk operation used to match external IDs.
+	Bulk BulkOptions
+}
+
+type CustomerImportResult struct {
+	// Records are the input record numbers, starting at 1, merged into
+	// this result.
+	Records    []int  `json:"records"`
+	Action     string `json:"action"`
+	CustomerID int64  `json:"customer_id,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+type CustomerImportReport struct {
+	Created    int                    `json:"created"`
+	Updated    int                    `json:"updated"`
+	Skipped    int                    `json:"skipped"`
+	Conflicted int                    `json:"conflicted"`
+	Results    []CustomerImportResult `json:"results"`
+}
+
+// customerCSVColumns maps CSV header columns to customer record fields.
+var customerCSVColumns = map[string]func(r *CustomerRecord, a *goshopify.CustomerAddress, v string){
+	"first_name":    func(r *CustomerRecord, _ *goshopify.CustomerAddress, v string) { r.FirstName = v },
+	"last_name":     func(r *CustomerRecord, _ *goshopify.CustomerAddress, v string) { r.LastName = v },
+	"email":         func(r *CustomerRecord, _ *goshopify.CustomerAddress, v string) { r.Email = v },
+	"phone":         func(r *CustomerRecord, _ *goshopify.CustomerAddress, v string) { r.Phone = v },
+	"tags":          func(r *CustomerRecord, _ *goshopify.CustomerAddress, v string) { r.Tags = v },
+	"note":          func(r *CustomerRecord, _ *goshopify.CustomerAddress, v string) { r.Note = v },
+	"external_id":   func(r *CustomerRecord, _ *goshopify.CustomerAddress, v string) { r.ExternalID = v },
+	"company":       func(_ *CustomerRecord, a *goshopify.CustomerAddress, v string) { a.Company = v },
+	"address1":      func(_ *CustomerRecord, a *goshopify.CustomerAddress, v string) { a.Address1 = v },
+	"address2":      func(_ *CustomerRecord, a *goshopify.CustomerAddress, v string) { a.Address2 = v },
+	"city":          func(_ *CustomerRecord, a *goshopify.CustomerAddress, v string) { a.City = v },
+	"province":      func(_ *CustomerRecord, a *goshopify.CustomerAddress, v string) { a.Province = v },
+	"province_code": func(_ *CustomerRecord, a *goshopify.CustomerAddress, v string) { a.ProvinceCode = v },
+	"country":       func(_ *CustomerRecord, a *goshopify.CustomerAddress, v string) { a.Country = v },
+	"country_code":  func(_ *CustomerRecord, a *goshopify.CustomerAddress, v string) { a.CountryCode = v },
+	"zip":           func(_ *CustomerRecord, a *goshopify.CustomerAddress, v string) { a.Zip = v },
+}
+
+// customerGroup is a set of input records identifying the same customer.
+type customerGroup struct {
+	record  CustomerRecord
+	records []int
+	// conflict is set if the records match different groups.
+	conflict string
+}
+
+// ReadCustomers reads customer records in JSONL or CSV format. CSV files
+// need a header row with columns named like the JSON fields, address
+// columns such as city or zip make up the customer's address.
+func ReadCustomers(r io.Reader, format string) ([]CustomerRecord, error) {
+	switch format {
+	case "jsonl":
+		return readCustomersJSONL(r)
+	case "csv":
+		return readCustomersCSV(r)
+	default:
+		return nil, fmt.Errorf("unknown customer file format %q", format)
+	}
+}
+
+func readCustomersJSONL(r io.Reader) ([]CustomerRecord, error) {
+	var records []CustomerRecord
+	dec := json.NewDecoder(r)
+	for {
+		record := CustomerRecord{}
+		err := dec.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("customer record %d: %w", len(records)+1, err)
+		}
+		records = append(records, record)
+	}
+}
+
+func readCustomersCSV(r io.Reader) ([]CustomerRecord, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read CSV header: %w", err)
+	}
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		if customerCSVColumns[header[i]] == nil {
+			return nil, fmt.Errorf("unknown CSV column %q", column)
+		}
+	}
+	var records []CustomerRecord
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		record := CustomerRecord{}
+		address := goshopify.CustomerAddress{}
+		for i, v := range row {
+			customerCSVColumns[header[i]](&record, &address, strings.TrimSpace(v))
+		}
+		if address != (goshopify.CustomerAddress{}) {
+			address.FirstName = record.FirstName
+			address.LastName = record.LastName
+			record.Addresses = []*goshopify.CustomerAddress{&address}
+		}
+		records = append(records, record)
+	}
+}
+
+// ImportCustomers creates or updates customers from records. Records are
+// deduplicated by opts.Keys within the input first, duplicates are merged
+// into a single customer. The merged customer is then matched against the
+// store by the same keys: without match it is created, with a single
+// match it is updated and with multiple matches it is reported as
+// conflicted. Updates only add new addresses, the default address of
+// existing customers is kept.
+func ImportCustomers(client Client, records []CustomerRecord, opts CustomerImportOptions) (*CustomerImportReport, error) {
+	return ImportCustomersContext(client.Context(), staticClient(client), records, opts)
+}
+
+// ImportCustomersContext is like ImportCustomers but stops before the next
+// customer once ctx is done, returning the report so far. Each customer
+// is imported with a client created by newClient, see RunOperation.
+func ImportCustomersContext(ctx context.Context, newClient ClientFunc, records []CustomerRecord, opts CustomerImportOptions) (*CustomerImportReport, error) {
+	if err := validateImportKeys(opts); err != nil {
+		return nil, err
+	}
+	var externalIDs map[string][]int64
+	if containsString(opts.Keys, KeyExternalID) {
+		var err error
+		if externalIDs, err = CustomerIDsByMetafield(newClient(ctx), opts.ExternalID, opts.Bulk); err != nil {
+			return nil, err
+		}
+	}
+	report := &CustomerImportReport{}
+	for _, g := range groupCustomers(records, opts.Keys, opts.PhoneRegion) {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		result := CustomerImportResult{Records: g.records, Action: ImportConflicted, Reason: g.conflict}
+		if g.conflict == "" {
+			err := RunOperation(ctx, newClient, func(client Client) error {
+				var err error
+				result, err = importCustomer(client, g, externalIDs, opts)
+				return err
+			})
+			if err != nil {
+				return report, fmt.Errorf("customer records %v: %w", g.records, err)
+			}
+		}
+		switch result.Action {
+		case ImportCreated:
+			report.Created++
+		case ImportUpdated:
+			report.Updated++
+		case ImportSkipped:
+			report.Skipped++
+		case ImportConflicted:
+			report.Conflicted++
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// CustomerIDsByMetafield returns the IDs of all customers by the value of
+// their metafield given as "namespace.key".
+func CustomerIDsByMetafield(client Client, metafield string, opts BulkOptions) (map[string][]int64, error) {
+	namespace, key, err := splitMetafieldName(metafield)
+	if err != nil {
+		return nil, err
+	}
+	ns, err := json.Marshal(namespace)
+	if err != nil {
+		return nil, err
+	}
+	k, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(`{
+  customers {
+    edges {
+      node {
+        id
+        metafield(namespace: %s, key: %s) { value }
+      }
+    }
+  }
+}`, ns, k)
+	ids := map[string][]int64{}
+	err = BulkQuery(client, query, opts, func(line []byte) error {
+		row := struct {
+			ID        string `json:"id"`
+			Metafield *struct {
+				Value string `json:"value"`
+			} `json:"metafield"`
+		}{}
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		if row.Metafield == nil || row.Metafield.Value == "" {
+			return nil
+		}
+		id, err := IDFromGID(row.ID)
+		if err != nil {
+			return err
+		}
+		ids[row.Metafield.Value] = append(ids[row.Metafield.Value], id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// splitMetafieldName splits a metafield given as "namespace.key".
+func splitMetafieldName(metafield string) (string, string, error) {
+	namespace, key, ok := strings.Cut(metafield, ".")
+	if !ok || namespace == "" || key == "" {
+		return "", "", fmt.Errorf("invalid metafield %q, want namespace.key", metafield)
+	}
+	return namespace, key, nil
+}
+
+func validateImportKeys(opts CustomerImportOptions) error {
+	if len(opts.Keys) == 0 {
+		return fmt.Errorf("no customer match keys given")
+	}
+	for _, key := range opts.Keys {
+		switch key {
+		case KeyEmail, KeyPhone:
+		case KeyExternalID:
+			if opts.ExternalID == "" {
+				return fmt.Errorf("external ID metafield required to match by %s", KeyExternalID)
+			}
+		default:
+			return fmt.Errorf("unknown customer match key %q", key)
+		}
+	}
+	return nil
+}
+
+// groupCustomers merges records sharing a normalised value for any of
+// keys. Records matching more than one group are not merged but returned
+// as conflicted group.
+func groupCustomers(records []CustomerRecord, keys []string, phoneRegion string) []*customerGroup {
+	var groups []*customerGroup
+	index := map[string]*customerGroup{}
+	for i, record := range records {
+		record = normaliseRecord(record, phoneRegion)
+		var matched []*customerGroup
+		for _, k := range recordKeys(record, keys) {
+			if g := index[k]; g != nil && !containsGroup(matched, g) {
+				matched = append(matched, g)
+			}
+		}
+		switch len(matched) {
+		case 0:
+			g := &customerGroup{record: record, records: []int{i + 1}}
+			groups = append(groups, g)
+			for _, k := range recordKeys(record, keys) {
+				index[k] = g
+			}
+		case 1:
+			g := matched[0]
+			mergeRecord(&g.record, record)
+			g.records = append(g.records, i+1)
+			for _, k := range recordKeys(g.record, keys) {
+				if index[k] == nil {
+					index[k] = g
+				}
+			}
+		default:
+			var others []int
+			for _, g := range matched {
+				others = append(others, g.records[0])
+			}
+			groups = append(groups, &customerGroup{
+				record:   record,
+				records:  []int{i + 1},
+				conflict: fmt.Sprintf("matches different customers of records %v", others),
+			})
+		}
+	}
+	return groups
+}
+
+func importCustomer(client Client, g *customerGroup, externalIDs map[string][]int64, opts CustomerImportOptions) (CustomerImportResult, error) {
+	result := CustomerImportResult{Records: g.records}
+	ids, err := matchCustomers(client, g.record, externalIDs, opts.Keys)
+	if err != nil {
+		return result, err
+	}
+	if len(ids) > 1 {
+		result.Action = ImportConflicted
+		result.Reason = fmt.Sprintf("matches customers %v", ids)
+		return result, nil
+	}
+	var mf []goshopify.Metafield
+	if opts.ExternalID != "" && g.record.ExternalID != "" {
+		namespace, key, _ := strings.Cut(opts.ExternalID, ".")
+		mf = []goshopify.Metafield{{Namespace: namespace, Key: key, Type: "single_line_text_field", Value: g.record.ExternalID}}
+	}
+	if len(ids) == 0 {
+		result.Action = ImportCreated
+		if opts.DryRun {
+			return result, nil
+		}
+		c := g.record.Customer
+		c.Metafields = mf
+		created, err := client.CreateCustomer(c)
+		if err != nil {
+			return result, err
+		}
+		result.CustomerID = created.ID
+		return result, nil
+	}
+	result.CustomerID = ids[0]
+	changed, err := updateCustomer(client, ids[0], g.record.Customer, opts.DryRun)
+	if err != nil {
+		return result, err
+	}
+	if mf != nil {
+		changes, err := SyncMetafields(client, ResourceCustomers, ids[0], mf, SyncOptions{DryRun: opts.DryRun})
+		if err != nil {
+			return result, err
+		}
+		changed = changed || changes[0].Action != MetafieldUnchanged
+	}
+	result.Action = ImportSkipped
+	if changed {
+		result.Action = ImportUpdated
+	}
+	return result, nil
+}
+
+// matchCustomers returns the sorted IDs of all store customers matching
+// record by any of keys.
+func matchCustomers(client Client, record CustomerRecord, externalIDs map[string][]int64, keys []string) ([]int64, error) {
+	matched := map[int64]bool{}
+	for _, key := range keys {
+		switch {
+		case key == KeyEmail && record.Email != "":
+			customers, err := CustomerListByEmail(client, record.Email)
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range customers {
+				if strings.EqualFold(c.Email, record.Email) {
+					matched[c.ID] = true
+				}
+			}
+		case key == KeyPhone && record.Phone != "":
+			customers, err := CustomerListByPhone(client, record.Phone)
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range customers {
+				if phoneKey(c.Phone, "") == record.Phone {
+					matched[c.ID] = true
+				}
+			}
+		case key == KeyExternalID && record.ExternalID != "":
+			for _, id := range externalIDs[record.ExternalID] {
+				matched[id] = true
+			}
+		}
+	}
+	return sortedKeys(matched), nil
+}
+
+// updateCustomer updates the customer with the given ID with the
+// non-empty fields of c, adds c's tags and adds c's addresses not yet
+// known. It returns whether anything changed.
+func updateCustomer(client Client, id int64, c goshopify.Customer, dryRun bool) (bool, error) {
+	existing, err := client.GetCustomer(id)
+	if err != nil {
+		return false, err
+	}
+	update := goshopify.Customer{ID: id}
+	changed := false
+	set := func(dst *string, cur, v string) {
+		if v != "" && v != cur {
+			*dst = v
+			changed = true
+		}
+	}
+	set(&update.FirstName, existing.FirstName, c.FirstName)
+	set(&update.LastName, existing.LastName, c.LastName)
+	if !strings.EqualFold(c.Email, existing.Email) {
+		set(&update.Email, existing.Email, c.Email)
+	}
+	set(&update.Note, existing.Note, c.Note)
+	if c.Phone != "" && c.Phone != phoneKey(existing.Phone, "") {
+		update.Phone = c.Phone
+		changed = true
+	}
+	if tags := mergeTags(existing.Tags, c.Tags); tags != mergeTags("", existing.Tags) {
+		update.Tags = tags
+		changed = true
+	}
+	var addresses []goshopify.CustomerAddress
+	known := append([]*goshopify.CustomerAddress{existing.DefaultAddress}, existing.Addresses...)
+	for _, a := range c.Addresses {
+		if !containsAddress(known, a) {
+			addresses = append(addresses, *a)
+			known = append(known, a)
+		}
+	}
+	if dryRun {
+		return changed || len(addresses) != 0, nil
+	}
+	if changed {
+		if _, err := client.UpdateCustomer(update); err != nil {
+			return false, err
+		}
+	}
+	for _, a := range addresses {
+		a.ID = 0
+		a.Default = false
+		if _, err := client.CreateCustomerAddress(id, a); err != nil {
+			return false, err
+		}
+	}
+	return changed || len(addresses) != 0, nil
+}
+
+// normaliseRecord normalises email and phones, see NormalisePhone, and
+// moves the default address into the list of addresses. Phones that
+// cannot be normalised to E.164 are reduced to their digits.
+func normaliseRecord(r CustomerRecord, phoneRegion string) CustomerRecord {
+	r.Email = strings.ToLower(strings.TrimSpace(r.Email))
+	r.ExternalID = strings.TrimSpace(r.ExternalID)
+	r.Tags = mergeTags("", r.Tags)
+	addresses := make([]*goshopify.CustomerAddress, 0, len(r.Addresses)+1)
+	if r.DefaultAddress != nil {
+		a := *r.DefaultAddress
+		a.Default = true
+		addresses = append(addresses, &a)
+	}
+	for _, a := range r.Addresses {
+		if a != nil && !containsAddress(addresses, a) {
+			a := *a
+			addresses = append(addresses, &a)
+		}
+	}
+	r.DefaultAddress = nil
+	r.Addresses = addresses
+	if len(addresses) != 0 {
+		phoneRegion = regionOr(addressRegion(addresses[0].CountryCode, addresses[0].Country), phoneRegion)
+	}
+	r.Phone = phoneKey(r.Phone, phoneRegion)
+	for _, a := range addresses {
+		a.Phone = phoneKey(a.Phone, regionOr(addressRegion(a.CountryCode, a.Country), phoneRegion))
+	}
+	return r
+}
+
+func recordKeys(r CustomerRecord, keys []string) []string {
+	var result []string
+	for _, key := range keys {
+		var v string
+		switch key {
+		case KeyEmail:
+			v = r.Email
+		case KeyPhone:
+			v = r.Phone
+		case KeyExternalID:
+			v = r.ExternalID
+		}
+		if v != "" {
+			result = append(result, key+":"+v)
+		}
+	}
+	return result
+}
+
+// mergeRecord fills empty fields of dst from src, adds src's tags and
+// appends src's addresses not yet in dst.
+func mergeRecord(dst *CustomerRecord, src CustomerRecord) {
+	for _, f := range []struct{ dst, src *string }{
+		{&dst.FirstName, &src.FirstName},
+		{&dst.LastName, &src.LastName},
+		{&dst.Email, &src.Email},
+		{&dst.Phone, &src.Phone},
+		{&dst.Note, &src.Note},
+		{&dst.ExternalID, &src.ExternalID},
+	} {
+		if *f.dst == "" {
+			*f.dst = *f.src
+		}
+	}
+	dst.Tags = mergeTags(dst.Tags, src.Tags)
+	for _, a := range src.Addresses {
+		if !containsAddress(dst.Addresses, a) {
+			a.Default = false
+			dst.Addresses = append(dst.Addresses, a)
+		}
+	}
+}
+
+// mergeTags returns the sorted union of two comma separated tag lists.
+func mergeTags(a, b string) string {
+	seen := map[string]bool{}
+	var tags []string
+	for _, tag := range strings.Split(a+","+b, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !seen[strings.ToLower(tag)] {
+			seen[strings.ToLower(tag)] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return strings.Join(tags, ", ")
+}
+
+// containsAddress reports whether addresses contains an address at the
+// same location as a, ignoring IDs, names and the default flag.
+func containsAddress(addresses []*goshopify.CustomerAddress, a *goshopify.CustomerAddress) bool {
+	for _, other := range addresses {
+		if other != nil && addressKey(other) == addressKey(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// addressKey returns the location of a with country and province as
+// codes if known, so that an address with country and province names
+// matches the same address with codes, e.g. as returned by Shopify.
+func addressKey(a *goshopify.CustomerAddress) string {
+	country := regionOr(strings.TrimSpace(a.CountryCode), a.Country)
+	province := regionOr(strings.TrimSpace(a.ProvinceCode), a.Province)
+	if code, ok := lookupCountry(country); ok {
+		country = code
+		if p := countries[code].province(province); p != nil {
+			province = p.code
+		}
+	}
+	zip := strings.ReplaceAll(a.Zip, " ", "")
+	fields := []string{a.Company, a.Address1, a.Address2, a.City, zip, province, country}
+	for i, f := range fields {
+		fields[i] = strings.TrimSpace(f)
+	}
+	return strings.ToLower(strings.Join(fields, "|"))
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsGroup(groups []*customerGroup, g *customerGroup) bool {
+	for _, other := range groups {
+		if other == g {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package order
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCustomers(t *testing.T) {
+	f, err := os.Open("../testdata/customers.csv")
+	require.NoError(t, err)
+	defer f.Close()
+	got, err := ReadCustomers(f, "csv")
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	require.Equal(t, "Morgen3@example.com", got[0].Email)
+	require.Equal(t, "C-1", got[0].ExternalID)
+	require.Len(t, got[0].Addresses, 1)
+	require.Equal(t, "Mary", got[0].Addresses[0].FirstName)
+	require.Equal(t, "3333", got[0].Addresses[0].Zip)
+	require.Empty(t, got[2].Addresses)
+
+	_, err = ReadCustomers(strings.NewReader("email,shoe_size\na@example.com,9\n"), "csv")
+	require.Error(t, err)
+
+	f, err = os.Open("../testdata/customers.jsonl")
+	require.NoError(t, err)
+	defer f.Close()
+	got, err = ReadCustomers(f, "jsonl")
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "C-2", got[1].ExternalID)
+	require.Equal(t, "12 Example St", got[0].DefaultAddress.Address1)
+}
+
+func TestGroupCustomers(t *testing.T) {
+	records := []CustomerRecord{
+		{Customer: goshopify.Customer{Email: "A@example.com", Tags: "b", Addresses: []*goshopify.CustomerAddress{{Address1: "1 St"}}}},
+		{Customer: goshopify.Customer{Email: "a@example.com", Phone: "+61 400 000 000", Tags: "a, b", Addresses: []*goshopify.CustomerAddress{{Address1: "1 st"}, {Address1: "2 St"}}}},
+		{Customer: goshopify.Customer{Email: "c@example.com", Phone: "+61400000001"}},
+		{Customer: goshopify.Customer{Email: "c@example.com", Phone: "+61400000000"}},
+	}
+	groups := groupCustomers(records, []string{KeyEmail, KeyPhone}, "")
+	require.Len(t, groups, 3)
+	require.Equal(t, []int{1, 2}, groups[0].records)
+	require.Equal(t, "a@example.com", groups[0].record.Email)
+	require.Equal(t, "+61400000000", groups[0].record.Phone)
+	require.Equal(t, "a, b", groups[0].record.Tags)
+	require.Len(t, groups[0].record.Addresses, 2)
+	require.Equal(t, []int{3}, groups[1].records)
+	require.Equal(t, []int{4}, groups[2].records)
+	require.NotEmpty(t, groups[2].conflict)
+
+	groups = groupCustomers(records, []string{KeyPhone}, "")
+	require.Len(t, groups, 3)
+	require.Equal(t, []int{1}, groups[0].records)
+	require.Equal(t, []int{2, 4}, groups[1].records)
+}
+
+func TestContainsAddress(t *testing.T) {
+	existing := []*goshopify.CustomerAddress{
+		{ID: 1, Address1: "1 Example St", City: "Melbourne", Zip: "3000", ProvinceCode: "VIC", CountryCode: "AU", Province: "Victoria", Country: "Australia"},
+		{ID: 2, Address1: "10 Downing St", City: "London", Zip: "SW1A 2AA", CountryCode: "GB"},
+	}
+	for _, a := range []*goshopify.CustomerAddress{
+		{Address1: "1 Example St", City: "Melbourne", Zip: "3000", Province: "Victoria", Country: "Australia"},
+		{Address1: "1 example st ", City: "Melbourne", Zip: "3000", Province: "vic", Country: "AU"},
+		{Address1: "1 Example St", City: "Melbourne", Zip: "3000", ProvinceCode: "AU-VIC", CountryCode: "AU", FirstName: "Mary"},
+		{Address1: "10 Downing St", City: "London", Zip: "SW1A2AA", Country: "United Kingdom"},
+	} {
+		require.True(t, containsAddress(existing, a), a.Address1)
+	}
+	for _, a := range []*goshopify.CustomerAddress{
+		{Address1: "1 Example St", City: "Melbourne", Zip: "3000", Province: "New South Wales", Country: "Australia"},
+		{Address1: "1 Example St", City: "Melbourne", Zip: "3000", ProvinceCode: "VIC", CountryCode: "NZ"},
+		{Address1: "10 Downing St", City: "London", Zip: "SW1A 2AB", CountryCode: "GB"},
+	} {
+		require.False(t, containsAddress(existing, a), a.Address1)
+	}
+}
+
+func TestImportCustomers(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	srv.AddCustomers(
+		goshopify.Customer{ID: 1, Email: "morgen3@example.com", FirstName: "Mary", Tags: "vip", DefaultAddress: &goshopify.CustomerAddress{ID: 11, Address1: "1 Old Rd", Default: true}},
+		goshopify.Customer{ID: 2, Email: "jo@example.com", FirstName: "Jo", LastName: "Bloggs", Tags: "wholesale"},
+		goshopify.Customer{ID: 3, Email: "sam@example.com"},
+		goshopify.Customer{ID: 4, Email: "other@example.com"},
+	)
+	_, err := SetMetafield(client, ResourceCustomers, 2, goshopify.Metafield{Namespace: "crm", Key: "id", Type: "single_line_text_field", Value: "C-2"})
+	require.NoError(t, err)
+	_, err = SetMetafield(client, ResourceCustomers, 4, goshopify.Metafield{Namespace: "crm", Key: "id", Type: "single_line_text_field", Value: "C-3"})
+	require.NoError(t, err)
+
+	records := []CustomerRecord{
+		{Customer: goshopify.Customer{Email: "Morgen3@example.com", LastName: "Morgan", Addresses: []*goshopify.CustomerAddress{{Address1: "12 Example St", Zip: "3333"}}}, ExternalID: "C-1"},
+		{Customer: goshopify.Customer{Email: "jo@example.com", Tags: "wholesale"}, ExternalID: "C-2"},
+		{Customer: goshopify.Customer{Email: "sam@example.com"}, ExternalID: "C-3"},
+		{Customer: goshopify.Customer{Email: "new@example.com", DefaultAddress: &goshopify.CustomerAddress{Address1: "3 New St"}}, ExternalID: "C-4"},
+	}
+	opts := CustomerImportOptions{
+		Keys:       []string{KeyEmail, KeyExternalID},
+		ExternalID: "crm.id",
+		DryRun:     true,
+		Bulk:       BulkOptions{PollInterval: time.Millisecond},
+	}
+	report, err := ImportCustomers(client, records, opts)
+	require.NoError(t, err)
+	require.Equal(t, []string{ImportUpdated, ImportSkipped, ImportConflicted, ImportCreated}, resultActions(report))
+	require.Len(t, srv.Customers(), 4)
+
+	opts.DryRun = false
+	report, err = ImportCustomers(client, records, opts)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 1, 1, 1}, []int{report.Created, report.Updated, report.Skipped, report.Conflicted})
+	customers := srv.Customers()
+	require.Len(t, customers, 5)
+	mary := customers[0]
+	require.Equal(t, "Morgan", mary.LastName)
+	require.Equal(t, "morgen3@example.com", mary.Email)
+	require.Equal(t, int64(11), mary.DefaultAddress.ID)
+	require.Len(t, mary.Addresses, 1)
+	require.Equal(t, "12 Example St", mary.Addresses[0].Address1)
+	require.False(t, mary.Addresses[0].Default)
+	require.Equal(t, "C-1", srv.Metafields(ResourceCustomers, 1)[0].Value)
+	created := customers[4]
+	require.Equal(t, "3 New St", created.DefaultAddress.Address1)
+	require.Equal(t, "C-4", srv.Metafields(ResourceCustomers, created.ID)[0].Value)
+
+	report, err = ImportCustomers(client, records, opts)
+	require.NoError(t, err)
+	require.Equal(t, []string{ImportSkipped, ImportSkipped, ImportConflicted, ImportSkipped}, resultActions(report))
+
+	_, err = ImportCustomers(client, records, CustomerImportOptions{Keys: []string{KeyExternalID}})
+	require.Error(t, err)
+}
+
+func resultActions(report *CustomerImportReport) []string {
+	var actions []string
+	for _, r := range report.Results {
+		actions = append(actions, r.Action)
+	}
+	return actions
+}
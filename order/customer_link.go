@@ -0,0 +1,145 @@
+package order
+
+import (
+	"fmt"
+	"strings"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// CustomerStrategy defines how the customer embedded in an order is
+// matched to store customers before the order is saved.
+type CustomerStrategy string
+
+const (
+	CustomerStrategyNone   CustomerStrategy = "none"   // send the embedded customer as is
+	CustomerStrategyLink   CustomerStrategy = "link"   // link existing customer, fail if there is none
+	CustomerStrategyCreate CustomerStrategy = "create" // link existing customer or create a new one
+	CustomerStrategyMerge  CustomerStrategy = "merge"  // update existing customer or create a new one
+)
+
+// ResolveCustomer replaces the order's embedded customer with a reference
+// to a store customer by ID according to strategy. Customers are matched
+// case-insensitively by the email of the embedded customer or the order
+// and, without email, by phone. Orders without customer email or phone
+// are left unchanged.
+func ResolveCustomer(client Client, order *goshopify.Order, strategy CustomerStrategy) error {
+	if strategy == "" || strategy == CustomerStrategyNone {
+		return nil
+	}
+	customer := goshopify.Customer{}
+	if order.Customer != nil {
+		customer = *order.Customer
+	}
+	if customer.Email == "" {
+		customer.Email = order.Email
+	}
+	if customer.Phone == "" {
+		customer.Phone = order.Phone
+	}
+	if customer.Email == "" && customer.Phone == "" {
+		return nil
+	}
+	customers, err := FindCustomers(client, customer.Email, customer.Phone)
+	if err != nil {
+		return err
+	}
+	if len(customers) > 1 {
+		return fmt.Errorf("order %q: %d customers found for email %q, phone %q", order.Name, len(customers), customer.Email, customer.Phone)
+	}
+	var id int64
+	switch {
+	case len(customers) == 1 && strategy != CustomerStrategyMerge:
+		id = customers[0].ID
+	case strategy == CustomerStrategyLink:
+		return fmt.Errorf("order %q: no customer found for email %q, phone %q", order.Name, customer.Email, customer.Phone)
+	default:
+		customer.ID = 0
+		if len(customers) == 1 {
+			customer.ID = customers[0].ID
+		}
+		customer.CreatedAt = nil
+		customer.UpdatedAt = nil
+		var saved *goshopify.Customer
+		if customer.ID != 0 {
+			saved, err = client.UpdateCustomer(customer)
+		} else {
+			saved, err = client.CreateCustomer(customer)
+		}
+		if err != nil {
+			return fmt.Errorf("order %q: %w", order.Name, err)
+		}
+		id = saved.ID
+	}
+	order.Customer = &goshopify.Customer{ID: id}
+	return nil
+}
+
+// prepareCustomer optionally normalises the order's phones and then
+// resolves its customer according to strategy. Addresses and customer
+// are copied before they are changed.
+func prepareCustomer(client Client, order *goshopify.Order, normalisePhones bool, phoneRegion string, strategy CustomerStrategy) error {
+	if normalisePhones {
+		copyContacts(order)
+		if err := NormaliseOrderPhones(order, phoneRegion); err != nil {
+			return fmt.Errorf("order %q: %w", order.Name, err)
+		}
+	}
+	return ResolveCustomer(client, order, strategy)
+}
+
+func copyContacts(order *goshopify.Order) {
+	if a := order.BillingAddress; a != nil {
+		order.BillingAddress = &goshopify.Address{}
+		*order.BillingAddress = *a
+	}
+	if a := order.ShippingAddress; a != nil {
+		order.ShippingAddress = &goshopify.Address{}
+		*order.ShippingAddress = *a
+	}
+	if order.Customer == nil {
+		return
+	}
+	c := *order.Customer
+	if c.DefaultAddress != nil {
+		a := *c.DefaultAddress
+		c.DefaultAddress = &a
+	}
+	c.Addresses = make([]*goshopify.CustomerAddress, len(order.Customer.Addresses))
+	for i, a := range order.Customer.Addresses {
+		if a != nil {
+			a := *a
+			c.Addresses[i] = &a
+		}
+	}
+	order.Customer = &c
+}
+
+// FindCustomers returns the customers with the given email, compared
+// case-insensitively, or if email is empty with the given phone number,
+// which should be in E.164 format, see NormalisePhone.
+func FindCustomers(client Client, email, phone string) ([]goshopify.Customer, error) {
+	var result []goshopify.Customer
+	if email != "" {
+		customers, err := CustomerListByEmail(client, email)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range customers {
+			if strings.EqualFold(c.Email, email) {
+				result = append(result, c)
+			}
+		}
+		return result, nil
+	}
+	customers, err := CustomerListByPhone(client, phone)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range customers {
+		if phoneKey(c.Phone, "") == phoneKey(phone, "") {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
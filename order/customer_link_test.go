@@ -0,0 +1,56 @@
+package order
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveCustomer(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	srv.AddCustomers(goshopify.Customer{ID: 1, Email: "mary@example.com", FirstName: "Mary"})
+
+	o := &goshopify.Order{Name: "order1", Customer: &goshopify.Customer{Email: "Mary@Example.com", LastName: "Morgan"}}
+	require.NoError(t, ResolveCustomer(client, o, CustomerStrategyLink))
+	require.Equal(t, &goshopify.Customer{ID: 1}, o.Customer)
+
+	o = &goshopify.Order{Name: "order2", Email: "new@example.com"}
+	require.Error(t, ResolveCustomer(client, o, CustomerStrategyLink))
+	require.NoError(t, ResolveCustomer(client, o, CustomerStrategyCreate))
+	newID := o.Customer.ID
+	require.NotZero(t, newID)
+	require.Len(t, srv.Customers(), 2)
+
+	o = &goshopify.Order{Name: "order3", Customer: &goshopify.Customer{Email: "MARY@example.com", LastName: "Morgan"}}
+	require.NoError(t, ResolveCustomer(client, o, CustomerStrategyMerge))
+	require.Equal(t, int64(1), o.Customer.ID)
+	require.Equal(t, "Morgan", srv.Customers()[0].LastName)
+
+	embedded := &goshopify.Customer{Email: "mary@example.com"}
+	o = &goshopify.Order{Name: "order4", Customer: embedded}
+	require.NoError(t, ResolveCustomer(client, o, CustomerStrategyNone))
+	require.Same(t, embedded, o.Customer)
+}
+
+func TestCreateLinksCustomer(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	srv.AddCustomers(goshopify.Customer{ID: 1, Email: "mary@example.com"})
+	b, err := os.ReadFile("../testdata/detailed_order.json")
+	require.NoError(t, err)
+	o := &goshopify.Order{}
+	require.NoError(t, json.Unmarshal(b, o))
+	o.Customer.Email = "Mary@example.com"
+	embedded := o.Customer
+
+	created, err := Create(NewShopifyClient(srv.Client()), o, CreateOptions{Customer: CustomerStrategyLink})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), created.Customer.ID)
+	require.Same(t, embedded, o.Customer)
+}
@@ -0,0 +1,280 @@
+package order
+
+import (
+	"fmt"
+	"sort"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+type FulfillOptions struct {
+	// NotifyCustomer sends a shipping confirmation to the customer. It
+	// should stay off for historical imports.
+	NotifyCustomer bool
+}
+
+type FulfillmentOrder struct {
+	ID                 int64                      `json:"id"`
+	OrderID            int64                      `json:"order_id"`
+	AssignedLocationID int64                      `json:"assigned_location_id"`
+	Status             string                     `json:"status"`
+	LineItems          []FulfillmentOrderLineItem `json:"line_items"`
+}
+
+type FulfillmentOrderLineItem struct {
+	ID                  int64 `json:"id"`
+	FulfillmentOrderID  int64 `json:"fulfillment_order_id"`
+	LineItemID          int64 `json:"line_item_id"`
+	VariantID           int64 `json:"variant_id"`
+	Quantity            int   `json:"quantity"`
+	FulfillableQuantity int   `json:"fulfillable_quantity"`
+}
+
+type FulfillmentOrdersResource struct {
+	FulfillmentOrders []FulfillmentOrder `json:"fulfillment_orders"`
+}
+
+type FulfillmentRequest struct {
+	LineItemsByFulfillmentOrder []FulfillmentOrderLineItems `json:"line_items_by_fulfillment_order"`
+	TrackingInfo                *TrackingInfo               `json:"tracking_info,omitempty"`
+	NotifyCustomer              bool                        `json:"notify_customer"`
+}
+
+type FulfillmentOrderLineItems struct {
+	FulfillmentOrderID        int64                    `json:"fulfillment_order_id"`
+	FulfillmentOrderLineItems []FulfillmentLineItemQty `json:"fulfillment_order_line_items,omitempty"`
+}
+
+type FulfillmentLineItemQty struct {
+	ID       int64 `json:"id"`
+	Quantity int   `json:"quantity"`
+}
+
+type TrackingInfo struct {
+	Company string `json:"company,omitempty"`
+	Number  string `json:"number,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+// fulfillable is a fulfillment order line item with its location and the
+// quantity not yet allocated to a fulfillment.
+type fulfillable struct {
+	foID       int64
+	locationID int64
+	foLineID   int64
+	remaining  int
+}
+
+func FulfillmentOrders(client Client, orderID int64) ([]FulfillmentOrder, error) {
+	return client.ListFulfillmentOrders(orderID)
+}
+
+// Fulfill creates fulfillments for the order with given ID via its
+// fulfillment orders. Each spec results in one fulfillment per location
+// with the spec's tracking company, number and URL. Spec line items are
+// matched to order line items by ID, variant ID or SKU; a zero quantity
+// fulfills all remaining items of the line. A spec without line items
+// fulfills everything not yet fulfilled. If specs is empty all
+// remaining items are fulfilled without tracking information.
+func Fulfill(client Client, orderID int64, specs []goshopify.Fulfillment, opts FulfillOptions) ([]goshopify.Fulfillment, error) {
+	order, err := client.GetOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	fulfillmentOrders, err := FulfillmentOrders(client, orderID)
+	if err != nil {
+		return nil, err
+	}
+	byLineItem := map[int64][]*fulfillable{}
+	var lineItemIDs []int64
+	for _, fo := range fulfillmentOrders {
+		if fo.Status != "open" && fo.Status != "in_progress" {
+			continue
+		}
+		for _, li := range fo.LineItems {
+			if li.FulfillableQuantity == 0 {
+				continue
+			}
+			if _, ok := byLineItem[li.LineItemID]; !ok {
+				lineItemIDs = append(lineItemIDs, li.LineItemID)
+			}
+			f := &fulfillable{foID: fo.ID, locationID: fo.AssignedLocationID, foLineID: li.ID, remaining: li.FulfillableQuantity}
+			byLineItem[li.LineItemID] = append(byLineItem[li.LineItemID], f)
+		}
+	}
+	if len(specs) == 0 {
+		specs = []goshopify.Fulfillment{{}}
+	}
+	var results []goshopify.Fulfillment
+	for i, spec := range specs {
+		allocations := map[*fulfillable]int{}
+		if len(spec.LineItems) == 0 {
+			for _, id := range lineItemIDs {
+				for _, f := range byLineItem[id] {
+					if f.remaining > 0 {
+						allocations[f] += f.remaining
+						f.remaining = 0
+					}
+				}
+			}
+		}
+		for _, specItem := range spec.LineItems {
+			lineItemID, err := matchLineItem(order.LineItems, specItem, byLineItem)
+			if err != nil {
+				return nil, fmt.Errorf("fulfillment %d: %w", i, err)
+			}
+			if err := allocate(byLineItem[lineItemID], specItem.Quantity, allocations); err != nil {
+				return nil, fmt.Errorf("fulfillment %d: line item %d: %w", i, lineItemID, err)
+			}
+		}
+		if len(allocations) == 0 {
+			return nil, fmt.Errorf("fulfillment %d: nothing left to fulfill for order %d", i, orderID)
+		}
+		for _, request := range fulfillmentRequests(allocations, spec, opts) {
+			fulfillment, err := client.CreateFulfillment(request)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, *fulfillment)
+		}
+	}
+	return results, nil
+}
+
+// matchLineItem returns the ID of the order line item identified by ID,
+// variant ID or SKU of specItem, preferring line items with items left to
+// fulfill. IDs not found in lineItems, e.g. line item IDs of the store an
+// order was exported from, fall back to variant ID and SKU.
+func matchLineItem(lineItems []goshopify.LineItem, specItem goshopify.LineItem, byLineItem map[int64][]*fulfillable) (int64, error) {
+	if specItem.ID != 0 {
+		for _, li := range lineItems {
+			if li.ID == specItem.ID {
+				return li.ID, nil
+			}
+		}
+		if specItem.VariantID == 0 && specItem.SKU == "" {
+			return 0, fmt.Errorf("no line item found with id %d", specItem.ID)
+		}
+	}
+	var candidate int64
+	for _, li := range lineItems {
+		if (specItem.VariantID == 0 || li.VariantID != specItem.VariantID) && (specItem.SKU == "" || li.SKU != specItem.SKU) {
+			continue
+		}
+		candidate = li.ID
+		for _, f := range byLineItem[li.ID] {
+			if f.remaining > 0 {
+				return li.ID, nil
+			}
+		}
+	}
+	if candidate == 0 {
+		return 0, fmt.Errorf("no line item found for variant %d, sku %q", specItem.VariantID, specItem.SKU)
+	}
+	return candidate, nil
+}
+
+// prepareFulfillments checks that the line items of the order's
+// fulfillments match line items of the order before it is created. As
+// the line item IDs of the created order are not known yet, the returned
+// copies identify line items by variant ID and SKU.
+func prepareFulfillments(order *goshopify.Order) ([]goshopify.Fulfillment, error) {
+	requested := make([]int, len(order.LineItems))
+	fulfillments := make([]goshopify.Fulfillment, len(order.Fulfillments))
+	for i, spec := range order.Fulfillments {
+		spec.LineItems = append([]goshopify.LineItem(nil), spec.LineItems...)
+		for j, specItem := range spec.LineItems {
+			k := -1
+			for idx, li := range order.LineItems {
+				if specItem.ID != 0 && li.ID == specItem.ID {
+					k = idx
+					break
+				}
+				if k == -1 && ((specItem.VariantID != 0 && li.VariantID == specItem.VariantID) || (specItem.SKU != "" && li.SKU == specItem.SKU)) {
+					k = idx
+				}
+			}
+			if k == -1 {
+				return nil, fmt.Errorf("fulfillment %d: no line item found for id %d, variant %d, sku %q", i, specItem.ID, specItem.VariantID, specItem.SKU)
+			}
+			li := order.LineItems[k]
+			if li.VariantID == 0 && li.SKU == "" {
+				return nil, fmt.Errorf("fulfillment %d: line item %d has neither variant nor sku", i, k)
+			}
+			requested[k] += specItem.Quantity
+			if requested[k] > li.Quantity {
+				return nil, fmt.Errorf("fulfillment %d: cannot fulfill %d items of line item %d, quantity %d", i, requested[k], k, li.Quantity)
+			}
+			spec.LineItems[j] = goshopify.LineItem{VariantID: li.VariantID, SKU: li.SKU, Quantity: specItem.Quantity}
+		}
+		fulfillments[i] = spec
+	}
+	return fulfillments, nil
+}
+
+func allocate(fs []*fulfillable, quantity int, allocations map[*fulfillable]int) error {
+	total := 0
+	for _, f := range fs {
+		total += f.remaining
+	}
+	if quantity == 0 {
+		quantity = total
+	}
+	if quantity == 0 || quantity > total {
+		return fmt.Errorf("cannot fulfill %d items, %d fulfillable", quantity, total)
+	}
+	for _, f := range fs {
+		n := f.remaining
+		if n > quantity {
+			n = quantity
+		}
+		allocations[f] += n
+		f.remaining -= n
+		quantity -= n
+	}
+	return nil
+}
+
+// fulfillmentRequests groups allocated fulfillment order line items by
+// location as a single fulfillment cannot span multiple locations.
+func fulfillmentRequests(allocations map[*fulfillable]int, spec goshopify.Fulfillment, opts FulfillOptions) []FulfillmentRequest {
+	var tracking *TrackingInfo
+	if spec.TrackingCompany != "" || spec.TrackingNumber != "" || spec.TrackingUrl != "" {
+		tracking = &TrackingInfo{Company: spec.TrackingCompany, Number: spec.TrackingNumber, URL: spec.TrackingUrl}
+	}
+	byLocation := map[int64]map[int64][]FulfillmentLineItemQty{}
+	for f, qty := range allocations {
+		if qty == 0 {
+			continue
+		}
+		if byLocation[f.locationID] == nil {
+			byLocation[f.locationID] = map[int64][]FulfillmentLineItemQty{}
+		}
+		byLocation[f.locationID][f.foID] = append(byLocation[f.locationID][f.foID], FulfillmentLineItemQty{ID: f.foLineID, Quantity: qty})
+	}
+	locationIDs := sortedKeys(byLocation)
+	requests := make([]FulfillmentRequest, 0, len(locationIDs))
+	for _, locationID := range locationIDs {
+		request := FulfillmentRequest{TrackingInfo: tracking, NotifyCustomer: opts.NotifyCustomer}
+		byFO := byLocation[locationID]
+		for _, foID := range sortedKeys(byFO) {
+			items := byFO[foID]
+			sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+			request.LineItemsByFulfillmentOrder = append(request.LineItemsByFulfillmentOrder, FulfillmentOrderLineItems{
+				FulfillmentOrderID:        foID,
+				FulfillmentOrderLineItems: items,
+			})
+		}
+		requests = append(requests, request)
+	}
+	return requests
+}
+
+func sortedKeys[V any](m map[int64]V) []int64 {
+	keys := make([]int64, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,103 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFulfill(t *testing.T) {
+	srv := fake.NewServer(goshopify.Order{ID: 1, Name: "order1", LineItems: []goshopify.LineItem{
+		{ID: 11, SKU: "TS-RED-M", VariantID: 101, Quantity: 2},
+		{ID: 12, SKU: "TS-RED-L", VariantID: 102, Quantity: 1},
+		{ID: 13, SKU: "MUG", Quantity: 1},
+	}})
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+
+	specs := []goshopify.Fulfillment{
+		{TrackingCompany: "Australia Post", TrackingNumber: "AP1", LineItems: []goshopify.LineItem{
+			{SKU: "TS-RED-M", Quantity: 1},
+			{VariantID: 102},
+		}},
+		{TrackingNumber: "AP2", LineItems: []goshopify.LineItem{{ID: 11}}},
+	}
+	got, err := Fulfill(client, 1, specs, FulfillOptions{})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "Australia Post", got[0].TrackingCompany)
+	require.Equal(t, "AP1", got[0].TrackingNumber)
+	require.Len(t, got[0].LineItems, 2)
+	require.Equal(t, 1, got[0].LineItems[0].Quantity)
+	require.False(t, got[0].NotifyCustomer)
+	require.Equal(t, "AP2", got[1].TrackingNumber)
+	require.Equal(t, "partial", srv.Orders()[0].FulfillmentStatus)
+
+	_, err = Fulfill(client, 1, []goshopify.Fulfillment{{LineItems: []goshopify.LineItem{{SKU: "TS-RED-L"}}}}, FulfillOptions{})
+	require.Error(t, err)
+	_, err = Fulfill(client, 1, []goshopify.Fulfillment{{LineItems: []goshopify.LineItem{{ID: 999}}}}, FulfillOptions{})
+	require.EqualError(t, err, "fulfillment 0: no line item found with id 999")
+
+	got, err = Fulfill(client, 1, nil, FulfillOptions{})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, []goshopify.LineItem{{ID: 13, SKU: "MUG", Quantity: 1}}, got[0].LineItems)
+	require.Equal(t, "fulfilled", srv.Orders()[0].FulfillmentStatus)
+
+	_, err = Fulfill(client, 1, nil, FulfillOptions{})
+	require.Error(t, err)
+}
+
+func TestCreateFulfill(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	o := &goshopify.Order{
+		Name:              "order1",
+		FulfillmentStatus: "fulfilled",
+		LineItems:         []goshopify.LineItem{{SKU: "A", Quantity: 1}, {SKU: "B", Quantity: 1}},
+		Fulfillments:      []goshopify.Fulfillment{{TrackingNumber: "AP1", LineItems: []goshopify.LineItem{{SKU: "A"}}}},
+	}
+	created, err := Create(NewShopifyClient(srv.Client()), o, CreateOptions{Fulfill: true})
+	require.NoError(t, err)
+	orders := srv.Orders()
+	require.Len(t, orders, 1)
+	require.Equal(t, created.ID, orders[0].ID)
+	require.Equal(t, "partial", orders[0].FulfillmentStatus)
+	require.Len(t, orders[0].Fulfillments, 1)
+	require.Equal(t, "AP1", orders[0].Fulfillments[0].TrackingNumber)
+	require.Len(t, o.Fulfillments, 1, "input order must not be modified")
+}
+
+func TestCreateFulfillForeignLineItemIDs(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	// line item IDs of the store the order was exported from
+	o := &goshopify.Order{
+		Name:      "order1",
+		LineItems: []goshopify.LineItem{{ID: 901, SKU: "A", Quantity: 1}, {ID: 902, SKU: "B", Quantity: 2}},
+		Fulfillments: []goshopify.Fulfillment{
+			{TrackingNumber: "AP1", LineItems: []goshopify.LineItem{{ID: 902, Quantity: 2}}},
+			{TrackingNumber: "AP2", LineItems: []goshopify.LineItem{{ID: 999, SKU: "A"}}},
+		},
+	}
+	_, err := Create(client, o, CreateOptions{Fulfill: true})
+	require.NoError(t, err)
+	orders := srv.Orders()
+	require.Len(t, orders, 1)
+	require.Equal(t, "fulfilled", orders[0].FulfillmentStatus)
+	require.Len(t, orders[0].Fulfillments, 2)
+	require.Equal(t, "B", orders[0].Fulfillments[0].LineItems[0].SKU)
+	require.Equal(t, "A", orders[0].Fulfillments[1].LineItems[0].SKU)
+
+	o.Name = "order2"
+	o.Fulfillments = []goshopify.Fulfillment{{LineItems: []goshopify.LineItem{{ID: 999}}}}
+	_, err = Create(client, o, CreateOptions{Fulfill: true})
+	require.EqualError(t, err, `fulfillment 0: no line item found for id 999, variant 0, sku ""`)
+	o.Fulfillments = []goshopify.Fulfillment{{LineItems: []goshopify.LineItem{{ID: 901, Quantity: 2}}}}
+	_, err = Create(client, o, CreateOptions{Fulfill: true})
+	require.EqualError(t, err, "fulfillment 0: cannot fulfill 2 items of line item 0, quantity 1")
+	require.Len(t, srv.Orders(), 1, "invalid fulfillments must not create an order")
+}
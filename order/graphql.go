@@ -0,0 +1,221 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// graphQLRetries is the number of attempts made for throttled GraphQL
+// requests.
+const graphQLRetries = 5
+
+// graphQLThrottleDelay is the minimum back-off before retrying a
+// throttled request without query cost data, growing with each attempt.
+const graphQLThrottleDelay = time.Second
+
+// GraphQL sends queries and mutations to the Shopify Admin GraphQL API.
+// It keeps track of the query cost reported by Shopify and waits before
+// sending a request if the remaining cost budget is insufficient.
+type GraphQL struct {
+	client Client
+	// Cost is the cost reported for the most recent request.
+	Cost  *QueryCost
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Vars holds GraphQL query variables.
+type Vars map[string]interface{}
+
+type GraphQLRequest struct {
+	Query     string `json:"query"`
+	Variables Vars   `json:"variables,omitempty"`
+}
+
+type GraphQLResponse struct {
+	Data       json.RawMessage `json:"data"`
+	Errors     GraphQLErrors   `json:"errors"`
+	Extensions struct {
+		Cost *QueryCost `json:"cost"`
+	} `json:"extensions"`
+}
+
+type GraphQLError struct {
+	Message    string        `json:"message"`
+	Path       []interface{} `json:"path,omitempty"`
+	Extensions struct {
+		Code string `json:"code"`
+	} `json:"extensions"`
+}
+
+type GraphQLErrors []GraphQLError
+
+// UserError is returned by mutations as part of their payload for
+// invalid input, e.g. in productCreate { userErrors { field message } }.
+type UserError struct {
+	Field   []string `json:"field"`
+	Message string   `json:"message"`
+	Code    string   `json:"code,omitempty"`
+}
+
+type UserErrors []UserError
+
+type QueryCost struct {
+	RequestedQueryCost float64        `json:"requestedQueryCost"`
+	ActualQueryCost    float64        `json:"actualQueryCost"`
+	ThrottleStatus     ThrottleStatus `json:"throttleStatus"`
+}
+
+type ThrottleStatus struct {
+	MaximumAvailable   float64 `json:"maximumAvailable"`
+	CurrentlyAvailable float64 `json:"currentlyAvailable"`
+	RestoreRate        float64 `json:"restoreRate"`
+}
+
+func NewGraphQL(client Client) *GraphQL {
+	return &GraphQL{client: client, sleep: sleep}
+}
+
+func (e GraphQLError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	path := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		path[i] = fmt.Sprint(p)
+	}
+	return strings.Join(path, ".") + ": " + e.Message
+}
+
+func (e GraphQLErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "graphql: " + strings.Join(msgs, "; ")
+}
+
+func (e GraphQLErrors) throttled() bool {
+	for _, err := range e {
+		if err.Extensions.Code == "THROTTLED" {
+			return true
+		}
+	}
+	return false
+}
+
+func (e UserError) Error() string {
+	if len(e.Field) == 0 {
+		return e.Message
+	}
+	return strings.Join(e.Field, ".") + ": " + e.Message
+}
+
+func (e UserErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Err returns e as error or nil if e is empty. It is intended for
+// checking the userErrors field of mutation payloads.
+func (e UserErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Do sends query with vars and decodes the data field of the response
+// into result. Throttled requests are retried once enough query cost
+// has been restored. Waiting ends early with an error once the context of
+// the client is done.
+func (g *GraphQL) Do(query string, vars Vars, result interface{}) error {
+	request := GraphQLRequest{Query: query, Variables: vars}
+	for attempt := 1; ; attempt++ {
+		waited, err := g.wait()
+		if err != nil {
+			return err
+		}
+		if !waited && attempt > 1 {
+			// throttled without cost data telling how long to wait
+			if err := g.sleep(g.client.Context(), time.Duration(attempt-1)*graphQLThrottleDelay); err != nil {
+				return err
+			}
+		}
+		resp, err := g.client.PostGraphQL(request)
+		if err != nil {
+			return err
+		}
+		if resp.Extensions.Cost != nil {
+			g.Cost = resp.Extensions.Cost
+		}
+		if len(resp.Errors) != 0 {
+			if resp.Errors.throttled() && attempt < graphQLRetries {
+				continue
+			}
+			return resp.Errors
+		}
+		if result == nil || len(resp.Data) == 0 {
+			return nil
+		}
+		return json.Unmarshal(resp.Data, result)
+	}
+}
+
+// wait sleeps until the query cost budget has been restored sufficiently
+// for a request as expensive as the previous one. It reports whether it
+// slept.
+func (g *GraphQL) wait() (bool, error) {
+	if g.Cost == nil {
+		return false, nil
+	}
+	status := g.Cost.ThrottleStatus
+	missing := g.Cost.RequestedQueryCost - status.CurrentlyAvailable
+	if missing <= 0 || status.RestoreRate <= 0 {
+		return false, nil
+	}
+	if err := g.sleep(g.client.Context(), time.Duration(missing/status.RestoreRate*float64(time.Second))); err != nil {
+		return false, err
+	}
+	// assume the budget has been restored, the next response updates it
+	g.Cost.ThrottleStatus.CurrentlyAvailable = g.Cost.RequestedQueryCost
+	return true, nil
+}
+
+// GID returns the GraphQL global ID for the resource type with the given
+// REST ID, e.g. GID("Order", 1) returns "gid://shopify/Order/1".
+func GID(resource string, id int64) string {
+	return fmt.Sprintf("gid://shopify/%s/%d", resource, id)
+}
+
+// ParseGID splits a GraphQL global ID such as
+// "gid://shopify/ProductVariant/43434424271066" into its resource type and
+// REST ID. Query parameters, as used for some resources, are ignored.
+func ParseGID(gid string) (string, int64, error) {
+	s := gid
+	if idx := strings.Index(s, "?"); idx != -1 {
+		s = s[:idx]
+	}
+	idx := strings.LastIndex(s, "/")
+	if idx == -1 {
+		return "", 0, fmt.Errorf("gid %q doesn't contain %q", gid, "/")
+	}
+	id, err := strconv.ParseInt(s[idx+1:], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid gid %q: %w", gid, err)
+	}
+	resource := strings.TrimPrefix(s[:idx], "gid://shopify/")
+	return resource, id, nil
+}
+
+// IDFromGID returns the REST ID of a GraphQL global ID.
+func IDFromGID(gid string) (int64, error) {
+	_, id, err := ParseGID(gid)
+	return id, err
+}
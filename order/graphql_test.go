@@ -0,0 +1,94 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphQLDo(t *testing.T) {
+	responses := []string{
+		`{"errors":[{"message":"Throttled","extensions":{"code":"THROTTLED"}}],"extensions":{"cost":{"requestedQueryCost":100,"actualQueryCost":null,"throttleStatus":{"maximumAvailable":1000,"currentlyAvailable":50,"restoreRate":50}}}}`,
+		`{"data":{"shop":{"name":"julias-delights"}},"extensions":{"cost":{"requestedQueryCost":100,"actualQueryCost":2,"throttleStatus":{"maximumAvailable":1000,"currentlyAvailable":998,"restoreRate":50}}}}`,
+		`{"errors":[{"message":"Field 'nope' doesn't exist on type 'Shop'","path":["query","shop","nope"],"extensions":{"code":"undefinedField"}}]}`,
+	}
+	requests := 0
+	client := testClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, responses[requests])
+		requests++
+	}))
+	gql := NewGraphQL(client)
+	var slept time.Duration
+	gql.sleep = func(_ context.Context, d time.Duration) error {
+		slept += d
+		return nil
+	}
+
+	result := struct{ Shop struct{ Name string } }{}
+	require.NoError(t, gql.Do("{ shop { name } }", nil, &result))
+	require.Equal(t, "julias-delights", result.Shop.Name)
+	require.Equal(t, 2, requests)
+	require.Equal(t, time.Second, slept)
+	require.Equal(t, float64(998), gql.Cost.ThrottleStatus.CurrentlyAvailable)
+
+	err := gql.Do("{ shop { nope } }", nil, &result)
+	require.EqualError(t, err, "graphql: query.shop.nope: Field 'nope' doesn't exist on type 'Shop'")
+}
+
+func TestGraphQLDoThrottledWithoutCost(t *testing.T) {
+	requests := 0
+	client := testClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			fmt.Fprint(w, `{"errors":[{"message":"Throttled","extensions":{"code":"THROTTLED"}}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"shop":{"name":"julias-delights"}}}`)
+	}))
+	gql := NewGraphQL(client)
+	var sleeps []time.Duration
+	gql.sleep = func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+
+	require.NoError(t, gql.Do("{ shop { name } }", nil, nil))
+	require.Equal(t, 3, requests)
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second}, sleeps)
+
+	// waiting ends with the context of the client
+	requests = 0
+	gql.sleep = func(context.Context, time.Duration) error {
+		return context.Canceled
+	}
+	require.ErrorIs(t, gql.Do("{ shop { name } }", nil, nil), context.Canceled)
+	require.Equal(t, 1, requests)
+}
+
+func TestUserErrors(t *testing.T) {
+	require.NoError(t, UserErrors{}.Err())
+	errs := UserErrors{{Field: []string{"input", "title"}, Message: "can't be blank"}, {Message: "oops"}}
+	require.EqualError(t, errs.Err(), "input.title: can't be blank; oops")
+}
+
+func TestGID(t *testing.T) {
+	gid := GID("ProductVariant", 43434424271066)
+	require.Equal(t, "gid://shopify/ProductVariant/43434424271066", gid)
+	resource, id, err := ParseGID(gid)
+	require.NoError(t, err)
+	require.Equal(t, "ProductVariant", resource)
+	require.Equal(t, int64(43434424271066), id)
+
+	id, err = IDFromGID("gid://shopify/InventoryLevel/123?inventory_item_id=456")
+	require.NoError(t, err)
+	require.Equal(t, int64(123), id)
+
+	_, err = IDFromGID("43434424271066")
+	require.Error(t, err)
+	_, err = IDFromGID("gid://shopify/ProductVariant/abc")
+	require.Error(t, err)
+}
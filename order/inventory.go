@@ -0,0 +1,164 @@
+package order
+
+import (
+	"fmt"
+)
+
+// locationInventoryPageSize is the number of inventory levels requested
+// per GraphQL page.
+const locationInventoryPageSize = 100
+
+const locationInventoryQuery = `query($id: ID!, $first: Int!, $after: String) {
+  location(id: $id) {
+    inventoryLevels(first: $first, after: $after) {
+      edges { cursor node { available item { id sku variant { id displayName } } } }
+      pageInfo { hasNextPage }
+    }
+  }
+}`
+
+// LocationInventory is the inventory level of an item at a location
+// together with the variant it belongs to.
+type LocationInventory struct {
+	InventoryItemID int64  `json:"inventory_item_id"`
+	VariantID       int64  `json:"variant_id,omitempty"`
+	SKU             string `json:"sku"`
+	Title           string `json:"title"`
+	Available       int    `json:"available"`
+}
+
+// InventoryTransfer holds the inventory levels at both locations after a
+// transfer.
+type InventoryTransfer struct {
+	From *InventoryLevel `json:"from"`
+	To   *InventoryLevel `json:"to"`
+}
+
+// InventoryItemID returns inventoryItemID or, if it is 0, the inventory
+// item ID of the variant.
+func InventoryItemID(client Client, inventoryItemID, variantID int64) (int64, error) {
+	if inventoryItemID != 0 {
+		return inventoryItemID, nil
+	}
+	variant, err := client.GetVariant(variantID)
+	if err != nil {
+		return 0, err
+	}
+	return variant.InventoryItemId, nil
+}
+
+// ConnectInventoryLevel stocks an inventory item at a location. An
+// existing inventory level is kept.
+func ConnectInventoryLevel(client Client, locationID, inventoryItemID int64) (*InventoryLevel, error) {
+	return client.ConnectInventoryLevel(InventoryLevel{InventoryItemID: inventoryItemID, LocationID: locationID})
+}
+
+// DisconnectInventoryLevel deletes the inventory level of an item at a
+// location. Shopify refuses to disconnect the last location of an item.
+func DisconnectInventoryLevel(client Client, locationID, inventoryItemID int64) error {
+	return client.DisconnectInventoryLevel(locationID, inventoryItemID)
+}
+
+// TransferInventory moves quantity available items from one location to
+// another, connecting the item to the destination location if needed.
+// If the destination cannot be adjusted, the source adjustment is
+// reverted.
+func TransferInventory(client Client, inventoryItemID, fromLocationID, toLocationID int64, quantity int) (*InventoryTransfer, error) {
+	if quantity < 1 {
+		return nil, fmt.Errorf("invalid transfer quantity %d", quantity)
+	}
+	if fromLocationID == toLocationID {
+		return nil, fmt.Errorf("cannot transfer inventory to the same location %d", fromLocationID)
+	}
+	levels, err := GetIventoryLevels(client, inventoryItemID, 0)
+	if err != nil {
+		return nil, err
+	}
+	var from, to *InventoryLevel
+	for _, level := range levels {
+		switch level.LocationID {
+		case fromLocationID:
+			from = level
+		case toLocationID:
+			to = level
+		}
+	}
+	if from == nil {
+		return nil, fmt.Errorf("inventory item %d is not stocked at location %d", inventoryItemID, fromLocationID)
+	}
+	if from.Available < quantity {
+		return nil, fmt.Errorf("inventory item %d: not enough items available at location %d (%d)", inventoryItemID, fromLocationID, from.Available)
+	}
+	if to == nil {
+		if _, err := ConnectInventoryLevel(client, toLocationID, inventoryItemID); err != nil {
+			return nil, fmt.Errorf("cannot connect inventory item %d to location %d: %w", inventoryItemID, toLocationID, err)
+		}
+	}
+	transfer := &InventoryTransfer{}
+	if transfer.From, err = AdjustIventoryLevel(client, fromLocationID, inventoryItemID, 0, -quantity); err != nil {
+		return nil, err
+	}
+	if transfer.To, err = AdjustIventoryLevel(client, toLocationID, inventoryItemID, 0, quantity); err != nil {
+		if _, revertErr := AdjustIventoryLevel(client, fromLocationID, inventoryItemID, 0, quantity); revertErr != nil {
+			return nil, fmt.Errorf("%w; cannot revert location %d: %v", err, fromLocationID, revertErr)
+		}
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// ListLocationInventory returns all inventory levels at a location.
+func ListLocationInventory(client Client, locationID int64) ([]LocationInventory, error) {
+	gql := NewGraphQL(client)
+	vars := Vars{"id": GID("Location", locationID), "first": locationInventoryPageSize}
+	var levels []LocationInventory
+	for {
+		result := struct {
+			Location *struct {
+				InventoryLevels struct {
+					Edges []struct {
+						Cursor string
+						Node   struct {
+							Available int
+							Item      struct {
+								ID      string
+								SKU     string
+								Variant *struct {
+									ID          string
+									DisplayName string
+								}
+							}
+						}
+					}
+					PageInfo struct {
+						HasNextPage bool
+					}
+				}
+			}
+		}{}
+		if err := gql.Do(locationInventoryQuery, vars, &result); err != nil {
+			return nil, err
+		}
+		if result.Location == nil {
+			return nil, fmt.Errorf("location %d not found", locationID)
+		}
+		for _, e := range result.Location.InventoryLevels.Edges {
+			level := LocationInventory{SKU: e.Node.Item.SKU, Available: e.Node.Available}
+			var err error
+			if level.InventoryItemID, err = IDFromGID(e.Node.Item.ID); err != nil {
+				return nil, err
+			}
+			if v := e.Node.Item.Variant; v != nil {
+				level.Title = v.DisplayName
+				if level.VariantID, err = IDFromGID(v.ID); err != nil {
+					return nil, err
+				}
+			}
+			levels = append(levels, level)
+			vars["after"] = e.Cursor
+		}
+		if !result.Location.InventoryLevels.PageInfo.HasNextPage {
+			return levels, nil
+		}
+	}
+}
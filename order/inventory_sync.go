@@ -0,0 +1,266 @@
+package order
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const inventoryBulkAdjustMutation = `mutation($locationId: ID!, $inventoryItemAdjustments: [InventoryAdjustItemInput!]!) {
+  inventoryBulkAdjustQuantityAtLocation(locationId: $locationId, inventoryItemAdjustments: $inventoryItemAdjustments) {
+    inventoryLevels { available item { id } }
+    userErrors { field message }
+  }
+}`
+
+// stockCSVColumns lists the accepted column names for each stock record
+// field. Other columns are ignored.
+var stockCSVColumns = map[string][]string{
+	"sku":      {"sku"},
+	"location": {"location", "location_id", "location_name"},
+	"quantity": {"quantity", "on_hand", "available"},
+}
+
+// StockRecord is the on-hand quantity of a SKU at a location, given by ID
+// or name, as exported by the warehouse system.
+type StockRecord struct {
+	Line     int
+	SKU      string
+	Location string
+	Quantity int
+}
+
+// InventoryChange compares a stock record with the inventory level in the
+// store. Stocked is false if the item is not connected to the location.
+type InventoryChange struct {
+	Line            int    `json:"line"`
+	SKU             string `json:"sku"`
+	LocationID      int64  `json:"location_id"`
+	InventoryItemID int64  `json:"inventory_item_id"`
+	Current         int    `json:"current"`
+	Quantity        int    `json:"quantity"`
+	Stocked         bool   `json:"stocked"`
+}
+
+// InventoryThreshold guards against applying a broken stock file: at most
+// MaxChangedPercent of the items may change by more than MaxChange.
+type InventoryThreshold struct {
+	MaxChangedPercent float64
+	MaxChange         int
+}
+
+// ReadStock reads stock records from CSV with a header row containing
+// sku, location and quantity columns.
+func ReadStock(r io.Reader) ([]StockRecord, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, column := range header {
+		column = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(column)), " ", "_")
+		for field, names := range stockCSVColumns {
+			if _, ok := columns[field]; !ok && containsString(names, column) {
+				columns[field] = i
+			}
+		}
+	}
+	for _, field := range []string{"sku", "location", "quantity"} {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("missing CSV column %q", field)
+		}
+	}
+	var records []StockRecord
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		record := StockRecord{
+			Line:     line,
+			SKU:      strings.TrimSpace(row[columns["sku"]]),
+			Location: strings.TrimSpace(row[columns["location"]]),
+		}
+		quantity := strings.TrimSpace(row[columns["quantity"]])
+		if record.Quantity, err = strconv.Atoi(quantity); err != nil {
+			return nil, fmt.Errorf("line %d: invalid quantity %q", line, quantity)
+		}
+		if record.SKU == "" || record.Location == "" {
+			return nil, fmt.Errorf("line %d: sku and location are required", line)
+		}
+		records = append(records, record)
+	}
+}
+
+// Delta returns the adjustment needed to reach the stock record quantity.
+func (c InventoryChange) Delta() int {
+	return c.Quantity - c.Current
+}
+
+// PlanInventorySync resolves the SKUs and locations of records and reads
+// their current inventory levels. Nothing is changed in the store.
+func PlanInventorySync(client Client, records []StockRecord) ([]InventoryChange, error) {
+	type item struct {
+		id     int64
+		levels map[int64]int
+	}
+	items := map[string]*item{}
+	var locations map[string]int64
+	seen := map[string]int{}
+	changes := make([]InventoryChange, 0, len(records))
+	for _, r := range records {
+		locationID, err := strconv.ParseInt(r.Location, 10, 64)
+		if err != nil {
+			if locations == nil {
+				if locations, err = locationIDsByName(client); err != nil {
+					return nil, err
+				}
+			}
+			if locationID = locations[r.Location]; locationID == 0 {
+				return nil, fmt.Errorf("line %d: unknown location %q", r.Line, r.Location)
+			}
+		}
+		key := fmt.Sprintf("%s@%d", r.SKU, locationID)
+		if line, ok := seen[key]; ok {
+			return nil, fmt.Errorf("line %d: sku %q at location %d already given in line %d", r.Line, r.SKU, locationID, line)
+		}
+		seen[key] = r.Line
+		it := items[r.SKU]
+		if it == nil {
+			variantID, err := GetVariantIDBySKU(client, r.SKU, false)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", r.Line, err)
+			}
+			it = &item{levels: map[int64]int{}}
+			if it.id, err = InventoryItemID(client, 0, variantID); err != nil {
+				return nil, fmt.Errorf("line %d: sku %q: %w", r.Line, r.SKU, err)
+			}
+			levels, err := GetIventoryLevels(client, it.id, 0)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: sku %q: %w", r.Line, r.SKU, err)
+			}
+			for _, l := range levels {
+				it.levels[l.LocationID] = l.Available
+			}
+			items[r.SKU] = it
+		}
+		current, stocked := it.levels[locationID]
+		changes = append(changes, InventoryChange{
+			Line:            r.Line,
+			SKU:             r.SKU,
+			LocationID:      locationID,
+			InventoryItemID: it.id,
+			Current:         current,
+			Quantity:        r.Quantity,
+			Stocked:         stocked,
+		})
+	}
+	return changes, nil
+}
+
+// Check returns an error if more than t.MaxChangedPercent of changes
+// differ by more than t.MaxChange.
+func (t InventoryThreshold) Check(changes []InventoryChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	n := 0
+	for _, c := range changes {
+		if d := c.Delta(); d > t.MaxChange || d < -t.MaxChange {
+			n++
+		}
+	}
+	percent := 100 * float64(n) / float64(len(changes))
+	if percent > t.MaxChangedPercent {
+		return fmt.Errorf("%d of %d items (%.1f%%) change by more than %d, threshold is %.1f%%", n, len(changes), percent, t.MaxChange, t.MaxChangedPercent)
+	}
+	return nil
+}
+
+// ApplyInventorySync adjusts the inventory levels to the quantities of
+// changes. Items stocked at a location are adjusted in batches of
+// batchSize per location, other items are connected by setting their
+// level. It returns the number of inventory levels changed.
+func ApplyInventorySync(client Client, changes []InventoryChange, batchSize int) (int, error) {
+	return ApplyInventorySyncContext(client.Context(), staticClient(client), changes, batchSize)
+}
+
+// ApplyInventorySyncContext is like ApplyInventorySync but stops before
+// the next inventory level or batch once ctx is done. Each level or batch
+// is applied with a client created by newClient, see RunOperation.
+func ApplyInventorySyncContext(ctx context.Context, newClient ClientFunc, changes []InventoryChange, batchSize int) (int, error) {
+	if batchSize < 1 {
+		return 0, fmt.Errorf("invalid batch size %d", batchSize)
+	}
+	applied := 0
+	var locationIDs []int64
+	byLocation := map[int64][]InventoryChange{}
+	for _, c := range changes {
+		if c.Delta() == 0 {
+			continue
+		}
+		if !c.Stocked {
+			if err := ctx.Err(); err != nil {
+				return applied, err
+			}
+			err := RunOperation(ctx, newClient, func(client Client) error {
+				_, err := SetInventoryLevel(client, c.LocationID, c.InventoryItemID, c.Quantity)
+				return err
+			})
+			if err != nil {
+				return applied, fmt.Errorf("line %d: sku %q: %w", c.Line, c.SKU, err)
+			}
+			applied++
+			continue
+		}
+		if byLocation[c.LocationID] == nil {
+			locationIDs = append(locationIDs, c.LocationID)
+		}
+		byLocation[c.LocationID] = append(byLocation[c.LocationID], c)
+	}
+	for _, locationID := range locationIDs {
+		pending := byLocation[locationID]
+		for start := 0; start < len(pending); start += batchSize {
+			end := start + batchSize
+			if end > len(pending) {
+				end = len(pending)
+			}
+			if err := ctx.Err(); err != nil {
+				return applied, err
+			}
+			err := RunOperation(ctx, newClient, func(client Client) error {
+				return bulkAdjustInventory(NewGraphQL(client), locationID, pending[start:end])
+			})
+			if err != nil {
+				return applied, fmt.Errorf("location %d: cannot adjust lines %d to %d: %w", locationID, pending[start].Line, pending[end-1].Line, err)
+			}
+			applied += end - start
+		}
+	}
+	return applied, nil
+}
+
+func bulkAdjustInventory(gql *GraphQL, locationID int64, changes []InventoryChange) error {
+	adjustments := make([]Vars, len(changes))
+	for i, c := range changes {
+		adjustments[i] = Vars{"inventoryItemId": GID("InventoryItem", c.InventoryItemID), "availableDelta": c.Delta()}
+	}
+	vars := Vars{"locationId": GID("Location", locationID), "inventoryItemAdjustments": adjustments}
+	result := struct {
+		InventoryBulkAdjustQuantityAtLocation struct {
+			UserErrors UserErrors
+		}
+	}{}
+	if err := gql.Do(inventoryBulkAdjustMutation, vars, &result); err != nil {
+		return err
+	}
+	return result.InventoryBulkAdjustQuantityAtLocation.UserErrors.Err()
+}
@@ -0,0 +1,68 @@
+package order
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadStock(t *testing.T) {
+	got, err := ReadStock(strings.NewReader("SKU,Location Name,On_Hand,Bin\nTEE-M,Warehouse,5,A1\n TEE-L ,2, 0 ,B2\n"))
+	require.NoError(t, err)
+	require.Equal(t, []StockRecord{{Line: 2, SKU: "TEE-M", Location: "Warehouse", Quantity: 5}, {Line: 3, SKU: "TEE-L", Location: "2", Quantity: 0}}, got)
+
+	_, err = ReadStock(strings.NewReader("sku,quantity\nTEE-M,5\n"))
+	require.ErrorContains(t, err, `missing CSV column "location"`)
+	_, err = ReadStock(strings.NewReader("sku,location,quantity\nTEE-M,1,many\n"))
+	require.ErrorContains(t, err, `line 2: invalid quantity "many"`)
+}
+
+func TestInventorySync(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	srv.AddLocations(goshopify.Location{ID: 1, Name: "Shop location"}, goshopify.Location{ID: 2, Name: "Warehouse"})
+	var variants []goshopify.Variant
+	for i, sku := range []string{"TEE-S", "TEE-M", "TEE-L", "TEE-XL"} {
+		id := int64(10 + 2*i)
+		variants = append(variants, goshopify.Variant{ID: id, ProductID: 1, InventoryItemId: id + 1, Sku: sku})
+		_, err := SetInventoryLevel(client, 1, id+1, 10)
+		require.NoError(t, err)
+	}
+	srv.AddProducts(goshopify.Product{ID: 1, Title: "Tee", Variants: variants})
+
+	records := []StockRecord{
+		{Line: 2, SKU: "TEE-S", Location: "1", Quantity: 10},
+		{Line: 3, SKU: "TEE-M", Location: "Shop location", Quantity: 12},
+		{Line: 4, SKU: "TEE-L", Location: "1", Quantity: 3},
+		{Line: 5, SKU: "TEE-XL", Location: "1", Quantity: 0},
+		{Line: 6, SKU: "TEE-M", Location: "Warehouse", Quantity: 4},
+	}
+	changes, err := PlanInventorySync(client, records)
+	require.NoError(t, err)
+	require.Len(t, changes, 5)
+	require.Equal(t, InventoryChange{Line: 3, SKU: "TEE-M", LocationID: 1, InventoryItemID: 13, Current: 10, Quantity: 12, Stocked: true}, changes[1])
+	require.Equal(t, InventoryChange{Line: 6, SKU: "TEE-M", LocationID: 2, InventoryItemID: 13, Current: 0, Quantity: 4}, changes[4])
+
+	require.NoError(t, InventoryThreshold{MaxChangedPercent: 40, MaxChange: 5}.Check(changes))
+	require.EqualError(t, InventoryThreshold{MaxChangedPercent: 20, MaxChange: 5}.Check(changes), "2 of 5 items (40.0%) change by more than 5, threshold is 20.0%")
+
+	applied, err := ApplyInventorySync(client, changes, 2)
+	require.NoError(t, err)
+	require.Equal(t, 4, applied)
+	require.Equal(t, 10, srv.Inventory(11, 1))
+	require.Equal(t, 12, srv.Inventory(13, 1))
+	require.Equal(t, 3, srv.Inventory(15, 1))
+	require.Equal(t, 0, srv.Inventory(17, 1))
+	require.Equal(t, 4, srv.Inventory(13, 2))
+
+	_, err = PlanInventorySync(client, append(records, StockRecord{Line: 7, SKU: "TEE-S", Location: "1", Quantity: 1}))
+	require.ErrorContains(t, err, `line 7: sku "TEE-S" at location 1 already given in line 2`)
+	_, err = PlanInventorySync(client, []StockRecord{{Line: 2, SKU: "TEE-S", Location: "Basement", Quantity: 1}})
+	require.ErrorContains(t, err, `line 2: unknown location "Basement"`)
+	_, err = PlanInventorySync(client, []StockRecord{{Line: 2, SKU: "MUG", Location: "1", Quantity: 1}})
+	require.ErrorContains(t, err, `line 2: 0 product variants found with sku "MUG"`)
+}
@@ -0,0 +1,76 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferInventory(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	srv.AddProducts(goshopify.Product{ID: 1, Title: "Tee", Variants: []goshopify.Variant{{ID: 2, ProductID: 1, InventoryItemId: 3, Title: "M", Sku: "TEE-M"}}})
+	variant := srv.Products()[0].Variants[0]
+	itemID, err := InventoryItemID(client, 0, variant.ID)
+	require.NoError(t, err)
+	require.Equal(t, variant.InventoryItemId, itemID)
+	_, err = SetInventoryLevel(client, 1, itemID, 10)
+	require.NoError(t, err)
+
+	_, err = TransferInventory(client, itemID, 1, 2, 11)
+	require.ErrorContains(t, err, "not enough items available at location 1 (10)")
+	_, err = TransferInventory(client, itemID, 2, 1, 1)
+	require.ErrorContains(t, err, "not stocked at location 2")
+	_, err = TransferInventory(client, itemID, 1, 1, 1)
+	require.Error(t, err)
+
+	transfer, err := TransferInventory(client, itemID, 1, 2, 4)
+	require.NoError(t, err)
+	require.Equal(t, &InventoryLevel{InventoryItemID: itemID, LocationID: 1, Available: 6}, transfer.From)
+	require.Equal(t, &InventoryLevel{InventoryItemID: itemID, LocationID: 2, Available: 4}, transfer.To)
+	require.Equal(t, 6, srv.Inventory(itemID, 1))
+	require.Equal(t, 4, srv.Inventory(itemID, 2))
+
+	levels, err := ListLocationInventory(client, 2)
+	require.NoError(t, err)
+	require.Equal(t, []LocationInventory{{InventoryItemID: itemID, VariantID: variant.ID, SKU: "TEE-M", Title: "Tee - M", Available: 4}}, levels)
+}
+
+func TestConnectInventoryLevel(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	_, err := SetInventoryLevel(client, 1, 100, 3)
+	require.NoError(t, err)
+
+	level, err := ConnectInventoryLevel(client, 1, 100)
+	require.NoError(t, err)
+	require.Equal(t, 3, level.Available)
+	level, err = ConnectInventoryLevel(client, 2, 100)
+	require.NoError(t, err)
+	require.Equal(t, 0, level.Available)
+	levels, err := GetIventoryLevels(client, 100, 0)
+	require.NoError(t, err)
+	require.Len(t, levels, 2)
+
+	require.NoError(t, DisconnectInventoryLevel(client, 2, 100))
+	require.Error(t, DisconnectInventoryLevel(client, 2, 100))
+	require.Error(t, DisconnectInventoryLevel(client, 1, 100), "last location cannot be disconnected")
+}
+
+func TestListLocationInventoryPagination(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	for i := int64(1); i <= locationInventoryPageSize+5; i++ {
+		_, err := SetInventoryLevel(client, 1, i, int(i))
+		require.NoError(t, err)
+	}
+	levels, err := ListLocationInventory(client, 1)
+	require.NoError(t, err)
+	require.Len(t, levels, locationInventoryPageSize+5)
+	require.Equal(t, LocationInventory{InventoryItemID: 105, Available: 105}, levels[104])
+}
@@ -0,0 +1,183 @@
+package order
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// locationsPageSize is the number of locations requested per GraphQL
+// page.
+const locationsPageSize = 100
+
+const locationFields = `fragment LocationFields on Location {
+  id name isActive fulfillsOnlineOrders hasActiveInventory
+  fulfillmentService { serviceName }
+  address { address1 address2 city province zip country phone }
+}`
+
+const locationsQuery = `query($first: Int!, $after: String) {
+  locations(first: $first, after: $after, includeInactive: true, includeLegacy: true) {
+    edges { cursor node { ...LocationFields } }
+    pageInfo { hasNextPage }
+  }
+}
+` + locationFields
+
+const locationQuery = `query($id: ID!) {
+  location(id: $id) { ...LocationFields }
+}
+` + locationFields
+
+// Location is a store location with its address and whether it can
+// fulfill orders. FulfillmentService is set for locations managed by a
+// fulfillment service app.
+type Location struct {
+	ID                   int64           `json:"id"`
+	Name                 string          `json:"name"`
+	Active               bool            `json:"active"`
+	FulfillsOnlineOrders bool            `json:"fulfills_online_orders"`
+	HasActiveInventory   bool            `json:"has_active_inventory"`
+	FulfillmentService   string          `json:"fulfillment_service,omitempty"`
+	Address              LocationAddress `json:"address"`
+}
+
+type LocationAddress struct {
+	Address1 string `json:"address1"`
+	Address2 string `json:"address2"`
+	City     string `json:"city"`
+	Province string `json:"province"`
+	Zip      string `json:"zip"`
+	Country  string `json:"country"`
+	Phone    string `json:"phone"`
+}
+
+// locationNode is a Location as returned by GraphQL queries.
+type locationNode struct {
+	ID                   string
+	Name                 string
+	IsActive             bool
+	FulfillsOnlineOrders bool
+	HasActiveInventory   bool
+	FulfillmentService   *struct {
+		ServiceName string
+	}
+	Address LocationAddress
+}
+
+// ListLocations returns all locations of the store including inactive
+// and fulfillment service locations.
+func ListLocations(client Client) ([]Location, error) {
+	gql := NewGraphQL(client)
+	vars := Vars{"first": locationsPageSize}
+	var locations []Location
+	for {
+		result := struct {
+			Locations struct {
+				Edges []struct {
+					Cursor string
+					Node   locationNode
+				}
+				PageInfo struct {
+					HasNextPage bool
+				}
+			}
+		}{}
+		if err := gql.Do(locationsQuery, vars, &result); err != nil {
+			return nil, err
+		}
+		for _, e := range result.Locations.Edges {
+			l, err := e.Node.location()
+			if err != nil {
+				return nil, err
+			}
+			locations = append(locations, l)
+			vars["after"] = e.Cursor
+		}
+		if !result.Locations.PageInfo.HasNextPage {
+			return locations, nil
+		}
+	}
+}
+
+// GetLocation returns the location given by ID or name.
+func GetLocation(client Client, location string) (*Location, error) {
+	id, err := LocationID(client, location)
+	if err != nil {
+		return nil, err
+	}
+	result := struct {
+		Location *locationNode
+	}{}
+	if err := NewGraphQL(client).Do(locationQuery, Vars{"id": GID("Location", id)}, &result); err != nil {
+		return nil, err
+	}
+	if result.Location == nil {
+		return nil, fmt.Errorf("location %d not found", id)
+	}
+	l, err := result.Location.location()
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// LocationID returns the ID of a location given by ID or name. Names are
+// matched exactly first, then case-insensitively.
+func LocationID(client Client, location string) (int64, error) {
+	location = strings.TrimSpace(location)
+	if id, err := strconv.ParseInt(location, 10, 64); err == nil {
+		return id, nil
+	}
+	if location == "" {
+		return 0, fmt.Errorf("location ID or name is empty")
+	}
+	ids, err := locationIDsByName(client)
+	if err != nil {
+		return 0, err
+	}
+	if id, ok := ids[location]; ok {
+		return id, nil
+	}
+	var matches []int64
+	for name, id := range ids {
+		if strings.EqualFold(name, location) {
+			matches = append(matches, id)
+		}
+	}
+	if len(matches) != 1 {
+		return 0, fmt.Errorf("%d locations found with name %q", len(matches), location)
+	}
+	return matches[0], nil
+}
+
+func locationIDsByName(client Client) (map[string]int64, error) {
+	locations, err := client.ListLocations()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list locations: %w", err)
+	}
+	ids := make(map[string]int64, len(locations))
+	for _, l := range locations {
+		ids[l.Name] = l.ID
+	}
+	return ids, nil
+}
+
+func (n locationNode) location() (Location, error) {
+	id, err := IDFromGID(n.ID)
+	if err != nil {
+		return Location{}, err
+	}
+	l := Location{
+		ID:                   id,
+		Name:                 n.Name,
+		Active:               n.IsActive,
+		FulfillsOnlineOrders: n.FulfillsOnlineOrders,
+		HasActiveInventory:   n.HasActiveInventory,
+		Address:              n.Address,
+	}
+	if n.FulfillmentService != nil {
+		l.FulfillmentService = n.FulfillmentService.ServiceName
+	}
+	return l, nil
+}
@@ -0,0 +1,53 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocations(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	srv.AddLocations(
+		goshopify.Location{ID: 1, Name: "Shop location", Active: true, Address1: "1 Main St", City: "Sydney", Country: "AU"},
+		goshopify.Location{ID: 2, Name: "Warehouse", Active: true, Legacy: true},
+		goshopify.Location{ID: 3, Name: "Old store"},
+	)
+
+	locations, err := ListLocations(client)
+	require.NoError(t, err)
+	require.Len(t, locations, 3)
+	require.Equal(t, Location{
+		ID:                   1,
+		Name:                 "Shop location",
+		Active:               true,
+		FulfillsOnlineOrders: true,
+		HasActiveInventory:   true,
+		Address:              LocationAddress{Address1: "1 Main St", City: "Sydney", Country: "AU"},
+	}, locations[0])
+	require.Equal(t, "Fulfillment service", locations[1].FulfillmentService)
+	require.False(t, locations[1].FulfillsOnlineOrders)
+	require.False(t, locations[2].Active)
+
+	id, err := LocationID(client, "Warehouse")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), id)
+	id, err = LocationID(client, " old STORE ")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), id)
+	id, err = LocationID(client, "42")
+	require.NoError(t, err)
+	require.Equal(t, int64(42), id)
+	_, err = LocationID(client, "Basement")
+	require.EqualError(t, err, `0 locations found with name "Basement"`)
+
+	location, err := GetLocation(client, "warehouse")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), location.ID)
+	_, err = GetLocation(client, "42")
+	require.EqualError(t, err, "location 42 not found")
+}
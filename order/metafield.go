@@ -0,0 +1,224 @@
+package order
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// Resources that metafields can be managed for, as used in REST API paths.
+const (
+	ResourceOrders    = "orders"
+	ResourceCustomers = "customers"
+	ResourceVariants  = "variants"
+)
+
+// Metafield sync actions.
+const (
+	MetafieldCreate    = "create"
+	MetafieldUpdate    = "update"
+	MetafieldReplace   = "replace" // delete and recreate, types cannot be updated
+	MetafieldDelete    = "delete"
+	MetafieldUnchanged = "unchanged"
+)
+
+type SyncOptions struct {
+	// Delete removes metafields that are not in the local set but share
+	// a namespace with it.
+	Delete bool
+	DryRun bool
+}
+
+type MetafieldChange struct {
+	Action    string              `json:"action"`
+	Metafield goshopify.Metafield `json:"metafield"`
+}
+
+// Metafields lists the metafields of the resource with the given ID, e.g.
+// Metafields(client, ResourceCustomers, 1).
+func Metafields(client Client, resource string, id int64) ([]goshopify.Metafield, error) {
+	return client.ListMetafields(resource, id)
+}
+
+// SetMetafield creates the metafield or updates the existing metafield
+// with the same namespace and key, replacing it if its type changed.
+func SetMetafield(client Client, resource string, id int64, mf goshopify.Metafield) (*goshopify.Metafield, error) {
+	if err := ValidateMetafields([]goshopify.Metafield{mf}); err != nil {
+		return nil, err
+	}
+	existing, err := Metafields(client, resource, id)
+	if err != nil {
+		return nil, err
+	}
+	if current := findMetafield(existing, mf.Namespace, mf.Key); current != nil {
+		if current.Type != mf.Type {
+			return replaceMetafield(client, resource, id, current.ID, mf)
+		}
+		mf.ID = current.ID
+	}
+	return client.SaveMetafield(resource, id, mf)
+}
+
+// DeleteMetafield deletes the metafield with the given namespace and key.
+// It returns false if there is no such metafield.
+func DeleteMetafield(client Client, resource string, id int64, namespace, key string) (bool, error) {
+	existing, err := Metafields(client, resource, id)
+	if err != nil {
+		return false, err
+	}
+	current := findMetafield(existing, namespace, key)
+	if current == nil {
+		return false, nil
+	}
+	return true, client.DeleteMetafield(resource, id, current.ID)
+}
+
+// SyncMetafields reconciles the resource's metafields with local so that
+// every local metafield exists with the same type and value. The changes
+// made, or to be made for a dry run, are returned sorted by namespace and
+// key.
+func SyncMetafields(client Client, resource string, id int64, local []goshopify.Metafield, opts SyncOptions) ([]MetafieldChange, error) {
+	if err := ValidateMetafields(local); err != nil {
+		return nil, err
+	}
+	existing, err := Metafields(client, resource, id)
+	if err != nil {
+		return nil, err
+	}
+	var changes []MetafieldChange
+	namespaces := map[string]bool{}
+	for _, mf := range local {
+		namespaces[mf.Namespace] = true
+		current := findMetafield(existing, mf.Namespace, mf.Key)
+		switch {
+		case current == nil:
+			changes = append(changes, MetafieldChange{Action: MetafieldCreate, Metafield: mf})
+		case current.Type != mf.Type:
+			mf.ID = current.ID
+			changes = append(changes, MetafieldChange{Action: MetafieldReplace, Metafield: mf})
+		case !metafieldValueEqual(mf.Type, current.Value, mf.Value):
+			mf.ID = current.ID
+			changes = append(changes, MetafieldChange{Action: MetafieldUpdate, Metafield: mf})
+		default:
+			changes = append(changes, MetafieldChange{Action: MetafieldUnchanged, Metafield: *current})
+		}
+	}
+	if opts.Delete {
+		for _, mf := range existing {
+			if namespaces[mf.Namespace] && findMetafield(local, mf.Namespace, mf.Key) == nil {
+				changes = append(changes, MetafieldChange{Action: MetafieldDelete, Metafield: mf})
+			}
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		a, b := changes[i].Metafield, changes[j].Metafield
+		return a.Namespace < b.Namespace || (a.Namespace == b.Namespace && a.Key < b.Key)
+	})
+	if opts.DryRun {
+		return changes, nil
+	}
+	for i, c := range changes {
+		switch c.Action {
+		case MetafieldCreate, MetafieldUpdate:
+			mf, err := client.SaveMetafield(resource, id, c.Metafield)
+			if err != nil {
+				return nil, err
+			}
+			changes[i].Metafield = *mf
+		case MetafieldReplace:
+			mf, err := replaceMetafield(client, resource, id, c.Metafield.ID, c.Metafield)
+			if err != nil {
+				return nil, err
+			}
+			changes[i].Metafield = *mf
+		case MetafieldDelete:
+			if err := client.DeleteMetafield(resource, id, c.Metafield.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+// replaceMetafield deletes the metafield with the given ID and creates mf
+// instead.
+func replaceMetafield(client Client, resource string, id, metafieldID int64, mf goshopify.Metafield) (*goshopify.Metafield, error) {
+	if err := client.DeleteMetafield(resource, id, metafieldID); err != nil {
+		return nil, err
+	}
+	mf.ID = 0
+	return client.SaveMetafield(resource, id, mf)
+}
+
+// ValidateMetafields checks that metafields have namespace, key, type and
+// value and that there are no duplicates.
+func ValidateMetafields(metafields []goshopify.Metafield) error {
+	seen := map[string]bool{}
+	for i, mf := range metafields {
+		if mf.Namespace == "" || mf.Key == "" || mf.Type == "" || mf.Value == nil {
+			return fmt.Errorf("metafield %d: namespace, key, type and value required", i)
+		}
+		if seen[mf.Namespace+"."+mf.Key] {
+			return fmt.Errorf("metafield %d: duplicate %s.%s", i, mf.Namespace, mf.Key)
+		}
+		seen[mf.Namespace+"."+mf.Key] = true
+	}
+	return nil
+}
+
+func findMetafield(metafields []goshopify.Metafield, namespace, key string) *goshopify.Metafield {
+	for i := range metafields {
+		if metafields[i].Namespace == namespace && metafields[i].Key == key {
+			return &metafields[i]
+		}
+	}
+	return nil
+}
+
+// metafieldValueEqual reports whether metafield values a and b of type
+// typ are the same. Values of JSON types such as json, list.* or money are
+// compared decoded, as Shopify does not keep the formatting and key order
+// of the value written.
+func metafieldValueEqual(typ string, a, b interface{}) bool {
+	sa, sb := metafieldValue(a), metafieldValue(b)
+	if sa == sb {
+		return true
+	}
+	if !jsonMetafieldType(typ) {
+		return false
+	}
+	var va, vb interface{}
+	if json.Unmarshal([]byte(sa), &va) != nil || json.Unmarshal([]byte(sb), &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func jsonMetafieldType(typ string) bool {
+	switch typ {
+	case "json", "money", "rating", "dimension", "volume", "weight":
+		return true
+	}
+	return strings.HasPrefix(typ, "list.")
+}
+
+// metafieldValue returns the string representation of a metafield value
+// as decoded from JSON, e.g. 5 and "5" are the same.
+func metafieldValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
@@ -0,0 +1,142 @@
+package order
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetafields(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+
+	mf := goshopify.Metafield{Namespace: "source", Key: "id", Type: "single_line_text_field", Value: "A-1"}
+	created, err := SetMetafield(client, ResourceCustomers, 7, mf)
+	require.NoError(t, err)
+	mf.Value = "A-2"
+	updated, err := SetMetafield(client, ResourceCustomers, 7, mf)
+	require.NoError(t, err)
+	require.Equal(t, created.ID, updated.ID)
+	got, err := Metafields(client, ResourceCustomers, 7)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, "A-2", got[0].Value)
+
+	// the type of a metafield is changed by replacing it
+	mf.Type, mf.Value = "number_integer", "3"
+	replaced, err := SetMetafield(client, ResourceCustomers, 7, mf)
+	require.NoError(t, err)
+	require.NotEqual(t, created.ID, replaced.ID)
+	got, err = Metafields(client, ResourceCustomers, 7)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, "number_integer", got[0].Type)
+
+	_, err = SetMetafield(client, ResourceCustomers, 7, goshopify.Metafield{Namespace: "source", Key: "id"})
+	require.Error(t, err)
+
+	deleted, err := DeleteMetafield(client, ResourceCustomers, 7, "source", "id")
+	require.NoError(t, err)
+	require.True(t, deleted)
+	deleted, err = DeleteMetafield(client, ResourceCustomers, 7, "source", "id")
+	require.NoError(t, err)
+	require.False(t, deleted)
+}
+
+func TestSyncMetafields(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	for _, mf := range []goshopify.Metafield{
+		{Namespace: "loyalty", Key: "points", Type: "number_integer", Value: "10"},
+		{Namespace: "loyalty", Key: "tier", Type: "single_line_text_field", Value: "gold"},
+		{Namespace: "loyalty", Key: "old", Type: "single_line_text_field", Value: "x"},
+		{Namespace: "other", Key: "keep", Type: "single_line_text_field", Value: "y"},
+		{Namespace: "loyalty", Key: "prefs", Type: "json", Value: `{"b": [1, 2], "a": "x"}`},
+		{Namespace: "loyalty", Key: "level", Type: "single_line_text_field", Value: "2"},
+	} {
+		_, err := SetMetafield(client, ResourceVariants, 3, mf)
+		require.NoError(t, err)
+	}
+	local := []goshopify.Metafield{
+		{Namespace: "loyalty", Key: "points", Type: "number_integer", Value: float64(10)},
+		{Namespace: "loyalty", Key: "tier", Type: "single_line_text_field", Value: "platinum"},
+		{Namespace: "loyalty", Key: "since", Type: "date", Value: "2020-01-01"},
+		{Namespace: "loyalty", Key: "prefs", Type: "json", Value: map[string]interface{}{"a": "x", "b": []interface{}{float64(1), float64(2)}}},
+		{Namespace: "loyalty", Key: "level", Type: "number_integer", Value: "2"},
+	}
+	opts := SyncOptions{Delete: true, DryRun: true}
+	changes, err := SyncMetafields(client, ResourceVariants, 3, local, opts)
+	require.NoError(t, err)
+	actions := map[string]string{}
+	for _, c := range changes {
+		actions[c.Metafield.Namespace+"."+c.Metafield.Key] = c.Action
+	}
+	want := map[string]string{
+		"loyalty.points": MetafieldUnchanged,
+		"loyalty.tier":   MetafieldUpdate,
+		"loyalty.since":  MetafieldCreate,
+		"loyalty.old":    MetafieldDelete,
+		"loyalty.prefs":  MetafieldUnchanged,
+		"loyalty.level":  MetafieldReplace,
+	}
+	require.Equal(t, want, actions)
+	require.Len(t, srv.Metafields(ResourceVariants, 3), 6)
+
+	opts.DryRun = false
+	_, err = SyncMetafields(client, ResourceVariants, 3, local, opts)
+	require.NoError(t, err)
+	got := srv.Metafields(ResourceVariants, 3)
+	require.Len(t, got, 6)
+	level := findMetafield(got, "loyalty", "level")
+	require.Equal(t, "number_integer", level.Type)
+	changes, err = SyncMetafields(client, ResourceVariants, 3, local, opts)
+	require.NoError(t, err)
+	for _, c := range changes {
+		require.Equal(t, MetafieldUnchanged, c.Action)
+	}
+
+	dup := append(local, local[0])
+	_, err = SyncMetafields(client, ResourceVariants, 3, dup, opts)
+	require.Error(t, err)
+}
+
+func TestMetafieldsPaginated(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	for i := 0; i < 260; i++ {
+		mf := goshopify.Metafield{Namespace: "erp", Key: fmt.Sprintf("k%d", i), Type: "number_integer", Value: fmt.Sprint(i)}
+		_, err := client.SaveMetafield(ResourceOrders, 1, mf)
+		require.NoError(t, err)
+	}
+	got, err := Metafields(client, ResourceOrders, 1)
+	require.NoError(t, err)
+	require.Len(t, got, 260)
+	require.Equal(t, "k259", got[259].Key)
+}
+
+func TestMergeMetafields(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	o := &goshopify.Order{
+		Name:       "order1",
+		LineItems:  []goshopify.LineItem{{Title: "Mug", Quantity: 1}},
+		Metafields: []goshopify.Metafield{{Namespace: "source", Key: "id", Type: "single_line_text_field", Value: "S-1"}},
+	}
+	result, err := Merge(client, o, MergeOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "created", result.Label)
+	o.Metafields[0].Value = "S-2"
+	result, err = Merge(client, o, MergeOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "updated", result.Label)
+	got := srv.Metafields(ResourceOrders, result.OrderID)
+	require.Len(t, got, 1)
+	require.Equal(t, "S-2", got[0].Value)
+}
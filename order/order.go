@@ -1,8 +1,8 @@
 package order
 
 import (
+	"context"
 	"fmt"
-	"strconv"
 	"strings"
 
 	goshopify "github.com/bold-commerce/go-shopify/v3"
@@ -12,10 +12,28 @@ type CreateOptions struct {
 	Unique        bool
 	VerifyProduct bool
 	Inventory     bool
+	// Fulfill creates fulfillments from the order's fulfillments, or for
+	// all line items if there are none, after the order has been created.
+	Fulfill bool
+	// ValidateTransactions checks that transaction amounts reconcile with
+	// the order's total price before creating the order.
+	ValidateTransactions bool
+	// Customer links the order's customer to a store customer.
+	Customer CustomerStrategy
+	// NormalisePhones converts the order's phones to E.164 before
+	// customer lookup and creation, using PhoneRegion as default region.
+	NormalisePhones bool
+	PhoneRegion     string
 }
 
 type MergeOptions struct {
-	VerifyProduct bool
+	VerifyProduct        bool
+	Inventory            bool // update inventory if order is created
+	Fulfill              bool // fulfill order if it is created
+	ValidateTransactions bool // validate transactions if order is created
+	Customer             CustomerStrategy
+	NormalisePhones      bool
+	PhoneRegion          string
 }
 
 type UpdateOptions struct {
@@ -70,22 +88,88 @@ type VariantGQLResult struct {
 	}
 }
 
-func List(client *goshopify.Client, orderName string) ([]goshopify.Order, error) {
-	if orderName == "" {
-		return client.Order.List(nil)
+// ProductVariantsResult is the data of a productVariants query decoded
+// by GraphQL.Do.
+type ProductVariantsResult struct {
+	ProductVariants struct {
+		Edges []struct {
+			Node struct {
+				ID            string
+				Title         string
+				SKU           string
+				InventoryItem struct {
+					ID             string
+					LocationsCount int
+				}
+			}
+		}
 	}
-	ordersResource := goshopify.OrdersResource{}
-	query := struct {
-		Name string `url:"name"`
-	}{Name: orderName}
-	err := client.Get("orders.json", &ordersResource, query)
+}
+
+func List(client Client, orderName string) ([]goshopify.Order, error) {
+	return client.ListOrders(orderName)
+}
+
+// ListContext is like List but sends its requests with ctx.
+func ListContext(ctx context.Context, newClient ClientFunc, orderName string) ([]goshopify.Order, error) {
+	return List(newClient(ctx), orderName)
+}
+
+// IDByName returns the ID of the single order with the given name.
+func IDByName(client Client, orderName string) (int64, error) {
+	orders, err := List(client, orderName)
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+	if len(orders) != 1 {
+		return 0, fmt.Errorf("expected one order with name %q, found %d", orderName, len(orders))
 	}
-	return ordersResource.Orders, nil
+	return orders[0].ID, nil
+}
+
+// ListAll returns all orders, open or closed, optionally filtered by
+// order name, paging through the REST API.
+func ListAll(client Client, orderName string) ([]goshopify.Order, error) {
+	return client.ListAllOrders(orderName, 0)
+}
+
+// EachOrder is like ListAll but calls fn with each order as its page is
+// received instead of keeping all orders in memory.
+func EachOrder(client Client, orderName string, fn func(goshopify.Order) error) error {
+	return client.ListOrderPages(orderName, 0, func(orders []goshopify.Order) error {
+		for _, o := range orders {
+			if err := fn(o); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListAllContext is like ListAll but sends its requests with ctx.
+func ListAllContext(ctx context.Context, newClient ClientFunc, orderName string) ([]goshopify.Order, error) {
+	return ListAll(newClient(ctx), orderName)
 }
 
-func Create(client *goshopify.Client, order *goshopify.Order, opts CreateOptions) (*goshopify.Order, error) {
+// Create creates order with the checks and follow-up changes of opts. If
+// the order has been created but adjusting the inventory or fulfilling it
+// fails, the created order is returned together with the error.
+func Create(client Client, order *goshopify.Order, opts CreateOptions) (*goshopify.Order, error) {
+	if err := ValidateMetafields(order.Metafields); err != nil {
+		return nil, err
+	}
+	if opts.ValidateTransactions {
+		if err := ValidateTransactions(order); err != nil {
+			return nil, err
+		}
+	}
+	var fulfillments []goshopify.Fulfillment
+	if opts.Fulfill {
+		var err error
+		if fulfillments, err = prepareFulfillments(order); err != nil {
+			return nil, err
+		}
+	}
 	if opts.Unique {
 		orders, err := List(client, order.Name)
 		if err != nil {
@@ -102,23 +186,50 @@ func Create(client *goshopify.Client, order *goshopify.Order, opts CreateOptions
 			return nil, err
 		}
 	}
-	result, err := client.Order.Create(*order)
+	if opts.NormalisePhones || (opts.Customer != "" && opts.Customer != CustomerStrategyNone) {
+		o := *order
+		if err := prepareCustomer(client, &o, opts.NormalisePhones, opts.PhoneRegion, opts.Customer); err != nil {
+			return nil, err
+		}
+		order = &o
+	}
+	if opts.Fulfill {
+		// fulfillments are created via fulfillment orders after creation
+		o := *order
+		o.Fulfillments = nil
+		o.FulfillmentStatus = ""
+		order = &o
+	}
+	result, err := client.CreateOrder(*order)
 	if err != nil {
 		return nil, err
 	}
-	if !opts.Inventory {
-		return result, nil
+	if opts.Inventory {
+		for _, i := range inventories {
+			_, err := AdjustIventoryLevel(client, i.LocationID, i.InventoryItemID, 0, -1)
+			if err != nil {
+				return result, fmt.Errorf("order %d created but inventory not adjusted: %w", result.ID, err)
+			}
+		}
 	}
-	for _, i := range inventories {
-		_, err := AdjustIventoryLevel(client, i.LocationID, i.InventoryItemID, 0, -1)
-		if err != nil {
-			return nil, err
+	if opts.Fulfill {
+		if _, err := Fulfill(client, result.ID, fulfillments, FulfillOptions{}); err != nil {
+			return result, fmt.Errorf("order %d created but not fulfilled: %w", result.ID, err)
 		}
 	}
 	return result, nil
 }
 
-func Update(client *goshopify.Client, order *goshopify.Order) (*goshopify.Order, error) {
+// CreateContext is like Create but does not start once ctx is done. The
+// order is created with a client created by newClient, see RunOperation,
+// so that it is completed with its fulfillments if ctx is cancelled.
+func CreateContext(ctx context.Context, newClient ClientFunc, order *goshopify.Order, opts CreateOptions) (*goshopify.Order, error) {
+	return operationResult(ctx, newClient, func(client Client) (*goshopify.Order, error) {
+		return Create(client, order, opts)
+	})
+}
+
+func Update(client Client, order *goshopify.Order) (*goshopify.Order, error) {
 	orders, err := List(client, order.Name)
 	if err != nil {
 		return nil, err
@@ -130,13 +241,24 @@ func Update(client *goshopify.Client, order *goshopify.Order) (*goshopify.Order,
 		return nil, fmt.Errorf("more than one order with name %q", order.Name)
 	}
 	o := orders[0]
-	if _, err = client.Order.Update(o); err != nil {
+	if _, err = client.UpdateOrder(o); err != nil {
 		return nil, err
 	}
 	return &o, nil
 }
 
-func Merge(client *goshopify.Client, order *goshopify.Order, opts MergeOptions) (*MergeResult, error) {
+// UpdateContext is like Update but does not start once ctx is done, see
+// RunOperation.
+func UpdateContext(ctx context.Context, newClient ClientFunc, order *goshopify.Order) (*goshopify.Order, error) {
+	return operationResult(ctx, newClient, func(client Client) (*goshopify.Order, error) {
+		return Update(client, order)
+	})
+}
+
+// Merge creates order or updates the existing order with its name. If the
+// order has been created or updated but a follow-up change, e.g. its
+// fulfillment, fails, the result is returned together with the error.
+func Merge(client Client, order *goshopify.Order, opts MergeOptions) (*MergeResult, error) {
 	orders, err := List(client, order.Name)
 	if err != nil {
 		return nil, err
@@ -145,12 +267,20 @@ func Merge(client *goshopify.Client, order *goshopify.Order, opts MergeOptions)
 		return nil, fmt.Errorf("expected at most one order with name %q, found %d'", order.Name, len(orders))
 	}
 	if len(orders) == 0 {
-		order, err := Create(client, order, CreateOptions{VerifyProduct: opts.VerifyProduct})
-		if err != nil {
+		order, err := Create(client, order, CreateOptions{
+			VerifyProduct:        opts.VerifyProduct,
+			Inventory:            opts.Inventory,
+			Fulfill:              opts.Fulfill,
+			ValidateTransactions: opts.ValidateTransactions,
+			Customer:             opts.Customer,
+			NormalisePhones:      opts.NormalisePhones,
+			PhoneRegion:          opts.PhoneRegion,
+		})
+		if order == nil {
 			return nil, err
 		}
 		result := &MergeResult{Label: "created", OrderID: order.ID}
-		return result, nil
+		return result, err
 	}
 	if opts.VerifyProduct {
 		_, err := getInventories(client, order)
@@ -158,23 +288,46 @@ func Merge(client *goshopify.Client, order *goshopify.Order, opts MergeOptions)
 			return nil, err
 		}
 	}
-	order.ID = orders[0].ID
-	order, err = client.Order.Update(*order)
+	metafields := order.Metafields
+	if err := ValidateMetafields(metafields); err != nil {
+		return nil, err
+	}
+	o := *order
+	o.ID = orders[0].ID
+	o.Metafields = nil // synced separately, updates cannot change existing metafields
+	if err := prepareCustomer(client, &o, opts.NormalisePhones, opts.PhoneRegion, opts.Customer); err != nil {
+		return nil, err
+	}
+	order, err = client.UpdateOrder(o)
 	if err != nil {
 		return nil, err
 	}
 	result := &MergeResult{Label: "updated", OrderID: order.ID}
+	if len(metafields) != 0 {
+		if _, err := SyncMetafields(client, ResourceOrders, order.ID, metafields, SyncOptions{}); err != nil {
+			return result, err
+		}
+	}
 	return result, nil
 }
 
-func Delete(client *goshopify.Client, orderName string, opts DeleteOptions) ([]int64, error) {
-	var orders []goshopify.Order
-	var err error
-	if orderName == "" {
-		orders, err = client.Order.List(nil)
-	} else {
-		orders, err = List(client, orderName)
-	}
+// MergeContext is like Merge but does not start once ctx is done, see
+// CreateContext.
+func MergeContext(ctx context.Context, newClient ClientFunc, order *goshopify.Order, opts MergeOptions) (*MergeResult, error) {
+	return operationResult(ctx, newClient, func(client Client) (*MergeResult, error) {
+		return Merge(client, order, opts)
+	})
+}
+
+func Delete(client Client, orderName string, opts DeleteOptions) ([]int64, error) {
+	return DeleteContext(client.Context(), staticClient(client), orderName, opts)
+}
+
+// DeleteContext is like Delete but stops before the next order once ctx
+// is done, returning the IDs of the orders deleted so far. Each order is
+// deleted with a client created by newClient, see RunOperation.
+func DeleteContext(ctx context.Context, newClient ClientFunc, orderName string, opts DeleteOptions) ([]int64, error) {
+	orders, err := List(newClient(ctx), orderName)
 	if err != nil {
 		return nil, err
 	}
@@ -190,20 +343,20 @@ func Delete(client *goshopify.Client, orderName string, opts DeleteOptions) ([]i
 			deletedIDs = append(deletedIDs, o.ID)
 			continue
 		}
-		if err := client.Delete(fmt.Sprintf("orders/%d.json", o.ID)); err != nil {
-			return nil, err
+		if err := RunOperation(ctx, newClient, func(client Client) error { return DeleteByID(client, o.ID) }); err != nil {
+			return deletedIDs, err
 		}
 		deletedIDs = append(deletedIDs, o.ID)
 	}
 	return deletedIDs, nil
 }
 
-func DeleteByID(client *goshopify.Client, orderID int64) error {
-	return client.Delete(fmt.Sprintf("orders/%d.json", orderID))
+func DeleteByID(client Client, orderID int64) error {
+	return client.DeleteOrder(orderID)
 }
 
-func Replace(client *goshopify.Client, order *goshopify.Order, createOpts CreateOptions) (*goshopify.Order, error) {
-	delOpts := DeleteOptions{Unique: true}
+func Replace(client Client, order *goshopify.Order, createOpts CreateOptions) (*goshopify.Order, error) {
+	delOpts := DeleteOptions{Unique: true, Max: -1}
 	ids, err := Delete(client, order.Name, delOpts)
 	if err != nil {
 		return nil, err
@@ -214,46 +367,37 @@ func Replace(client *goshopify.Client, order *goshopify.Order, createOpts Create
 	return Create(client, order, createOpts)
 }
 
-func Meta(client *goshopify.Client, orderID int64) ([]goshopify.Metafield, error) {
-	resource := goshopify.MetafieldsResource{}
-	path := fmt.Sprintf("orders/%d/metafields.json", orderID)
-	err := client.Get(path, &resource, nil)
-	if err != nil {
-		return nil, err
-	}
-	return resource.Metafields, nil
+// ReplaceContext is like Replace but does not start once ctx is done, see
+// CreateContext.
+func ReplaceContext(ctx context.Context, newClient ClientFunc, order *goshopify.Order, createOpts CreateOptions) (*goshopify.Order, error) {
+	return operationResult(ctx, newClient, func(client Client) (*goshopify.Order, error) {
+		return Replace(client, order, createOpts)
+	})
 }
 
-func Transactions(client *goshopify.Client, orderID int64) ([]goshopify.Transaction, error) {
-	resource := goshopify.TransactionsResource{}
-	path := fmt.Sprintf("orders/%d/transactions.json", orderID)
-	err := client.Get(path, &resource, nil)
-	if err != nil {
-		return nil, err
-	}
-	return resource.Transactions, nil
+func Meta(client Client, orderID int64) ([]goshopify.Metafield, error) {
+	return Metafields(client, ResourceOrders, orderID)
 }
 
-func GetIventoryLevels(client *goshopify.Client, inventoryItemID, variantID int64) ([]*InventoryLevel, error) {
-	if inventoryItemID == 0 {
-		variant, err := client.Variant.Get(variantID, nil)
-		if err != nil {
-			return nil, err
-		}
-		inventoryItemID = variant.InventoryItemId
-	}
-	query := struct {
-		InventoryItemID int64 `url:"inventory_item_ids"`
-	}{InventoryItemID: inventoryItemID}
-	resource := InventoryLevelsResource{}
-	err := client.Get("inventory_levels.json", &resource, query)
+func Transactions(client Client, orderID int64) ([]goshopify.Transaction, error) {
+	return client.ListTransactions(orderID)
+}
+
+func GetIventoryLevels(client Client, inventoryItemID, variantID int64) ([]*InventoryLevel, error) {
+	inventoryItemID, err := InventoryItemID(client, inventoryItemID, variantID)
 	if err != nil {
 		return nil, err
 	}
-	return resource.InventoryLevels, nil
+	return client.ListInventoryLevels(inventoryItemID)
 }
 
-func GetIventoryLevel(client *goshopify.Client, inventoryItemID, variantID int64) (*InventoryLevel, error) {
+// GetIventoryLevelsContext is like GetIventoryLevels but sends its
+// requests with ctx.
+func GetIventoryLevelsContext(ctx context.Context, newClient ClientFunc, inventoryItemID, variantID int64) ([]*InventoryLevel, error) {
+	return GetIventoryLevels(newClient(ctx), inventoryItemID, variantID)
+}
+
+func GetIventoryLevel(client Client, inventoryItemID, variantID int64) (*InventoryLevel, error) {
 	levels, err := GetIventoryLevels(client, inventoryItemID, variantID)
 	if err != nil {
 		return nil, err
@@ -268,7 +412,7 @@ func GetIventoryLevel(client *goshopify.Client, inventoryItemID, variantID int64
 	return level, nil
 }
 
-func AdjustIventoryLevel(client *goshopify.Client, locaitonID, inventoryItemID, variantID int64, amount int) (*InventoryLevel, error) {
+func AdjustIventoryLevel(client Client, locaitonID, inventoryItemID, variantID int64, amount int) (*InventoryLevel, error) {
 	adjustment := InventoryLevelAdjustment{
 		InventoryItemID:     inventoryItemID,
 		LocationID:          locaitonID,
@@ -282,96 +426,71 @@ func AdjustIventoryLevel(client *goshopify.Client, locaitonID, inventoryItemID,
 		adjustment.InventoryItemID = level.InventoryItemID
 		adjustment.LocationID = level.LocationID
 	}
-	resource := InventoryLevelResource{}
-	err := client.Post("inventory_levels/adjust.json", adjustment, &resource)
-	if err != nil {
-		return nil, err
-	}
-	return resource.InventoryLevel, nil
+	return client.AdjustInventoryLevel(adjustment)
 }
 
-func GetVariantIDBySKU(client *goshopify.Client, sku string, includeInvenotry bool) (int64, error) {
+// SetInventoryLevel sets the available quantity of an inventory item at
+// a location.
+func SetInventoryLevel(client Client, locationID, inventoryItemID int64, available int) (*InventoryLevel, error) {
+	return client.SetInventoryLevel(InventoryLevel{InventoryItemID: inventoryItemID, LocationID: locationID, Available: available})
+}
+
+func GetVariantIDBySKU(client Client, sku string, includeInvenotry bool) (int64, error) {
 	query := "query($filter: String!) { productVariants(first: 2, query: $filter) { edges { node { id  title } } } }"
 	if includeInvenotry {
 		query = "query($filter: String!) { productVariants(first: 2, query: $filter) { edges { node { id  title inventoryItem  { id locationsCount } } } } }"
 	}
-
-	requestPayload := struct {
-		Query     string `json:"query"`
-		Variables struct {
-			Filter string `json:"filter"`
-		} `json:"variables"`
-	}{
-		Query: query,
-		Variables: struct {
-			Filter string `json:"filter"`
-		}{
-			Filter: fmt.Sprintf("sku:%s", sku),
-		},
-	}
-	resource := VariantGQLResult{}
-	err := client.Post("graphql.json", requestPayload, &resource)
+	result := ProductVariantsResult{}
+	err := NewGraphQL(client).Do(query, Vars{"filter": fmt.Sprintf("sku:%s", sku)}, &result)
 	if err != nil {
 		return 0, err
 	}
-	e := resource.Data.ProductVariants.Edges
+	e := result.ProductVariants.Edges
 	if len(e) > 1 || len(e) == 0 {
 		return 0, fmt.Errorf("%d product variants found with sku %q", len(e), sku)
 	}
 	// potentially later use locationCount for early checks
-	return idFromGID(e[0].Node.ID)
+	return IDFromGID(e[0].Node.ID)
 }
 
-func CustomerListByEmail(client *goshopify.Client, email string) ([]goshopify.Customer, error) {
+func CustomerListByEmail(client Client, email string) ([]goshopify.Customer, error) {
 	if email == "" {
 		return nil, fmt.Errorf("email is empty")
 	}
-	query := struct {
-		Email string `url:"email"`
-	}{Email: email}
-	return client.Customer.Search(query)
+	return client.SearchCustomers("email:" + quoteSearchValue(email))
 }
 
-func CustomerListByPhone(client *goshopify.Client, phone string) ([]goshopify.Customer, error) {
+func CustomerListByPhone(client Client, phone string) ([]goshopify.Customer, error) {
 	if phone == "" {
 		return nil, fmt.Errorf("phone is empty")
 	}
-	query := struct {
-		Phone string `url:"phone"`
-	}{Phone: phone}
-	return client.Customer.Search(query)
+	return client.SearchCustomers("phone:" + quoteSearchValue(phone))
 }
 
-func CustomerMerge(client *goshopify.Client, customer *goshopify.Customer) (*goshopify.Customer, error) {
-	customers, err := CustomerListByEmail(client, customer.Email)
+func CustomerMerge(client Client, customer *goshopify.Customer) (*goshopify.Customer, error) {
+	found, err := CustomerListByEmail(client, customer.Email)
 	if err != nil {
 		return nil, err
 	}
+	var customers []goshopify.Customer
+	for _, c := range found {
+		// the search also matches similar emails
+		if strings.EqualFold(c.Email, customer.Email) {
+			customers = append(customers, c)
+		}
+	}
 	if len(customers) > 1 {
 		return nil, fmt.Errorf("more than 1 customer found for email %q", customer.Email)
 	}
 	if len(customers) == 1 {
 		c := *customer
 		c.ID = customers[0].ID
-		return client.Customer.Update(c)
-	}
-	return client.Customer.Create(*customer)
-}
-
-func idFromGID(gid string) (int64, error) {
-	idx := strings.LastIndex(gid, "/")
-	if idx == -1 {
-		return 0, fmt.Errorf("gid %q doesn't contain %q", gid, "/")
-	}
-	idStr := gid[idx+1:]
-	i, err := strconv.Atoi(idStr)
-	if err != nil {
-		return 0, err
+		return client.UpdateCustomer(c)
 	}
-	return int64(i), nil
+	return client.CreateCustomer(*customer)
 }
 
-func getInventories(client *goshopify.Client, order *goshopify.Order) ([]*InventoryLevel, error) {
+func getInventories(client Client, order *goshopify.Order) ([]*InventoryLevel, error) {
 	levels := make([]*InventoryLevel, 0, len(order.LineItems))
 	for _, lineItem := range order.LineItems {
 		if lineItem.VariantID != 0 {
@@ -0,0 +1,115 @@
+package order
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// orderCSVColumns maps CSV header columns to the order or line item field
+// they set, see setCSVValue.
+var orderCSVColumns = map[string]func(o *goshopify.Order, li *goshopify.LineItem) interface{}{
+	"name":             func(o *goshopify.Order, _ *goshopify.LineItem) interface{} { return &o.Name },
+	"email":            func(o *goshopify.Order, _ *goshopify.LineItem) interface{} { return &o.Email },
+	"phone":            func(o *goshopify.Order, _ *goshopify.LineItem) interface{} { return &o.Phone },
+	"currency":         func(o *goshopify.Order, _ *goshopify.LineItem) interface{} { return &o.Currency },
+	"financial_status": func(o *goshopify.Order, _ *goshopify.LineItem) interface{} { return &o.FinancialStatus },
+	"note":             func(o *goshopify.Order, _ *goshopify.LineItem) interface{} { return &o.Note },
+	"tags":             func(o *goshopify.Order, _ *goshopify.LineItem) interface{} { return &o.Tags },
+	"source_name":      func(o *goshopify.Order, _ *goshopify.LineItem) interface{} { return &o.SourceName },
+	"sku":              func(_ *goshopify.Order, li *goshopify.LineItem) interface{} { return &li.SKU },
+	"variant_id":       func(_ *goshopify.Order, li *goshopify.LineItem) interface{} { return &li.VariantID },
+	"title":            func(_ *goshopify.Order, li *goshopify.LineItem) interface{} { return &li.Title },
+	"quantity":         func(_ *goshopify.Order, li *goshopify.LineItem) interface{} { return &li.Quantity },
+	"price":            func(_ *goshopify.Order, li *goshopify.LineItem) interface{} { return &li.Price },
+}
+
+// ReadOrders reads orders from JSON, a single order or an array, JSON
+// lines or CSV. CSV files need a header row with columns named like the
+// JSON fields, e.g. name, email, sku and quantity. Rows with the same name
+// are line items of the same order, order columns are taken from the
+// first row that sets them.
+func ReadOrders(r io.Reader, format string) ([]goshopify.Order, error) {
+	switch format {
+	case "json", "jsonl":
+		return readJSONRecords[goshopify.Order](r, "order")
+	case "csv":
+		return readOrdersCSV(r)
+	default:
+		return nil, fmt.Errorf("unknown order file format %q", format)
+	}
+}
+
+func readOrdersCSV(r io.Reader) ([]goshopify.Order, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read CSV header: %w", err)
+	}
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		if orderCSVColumns[header[i]] == nil {
+			return nil, fmt.Errorf("unknown CSV column %q", column)
+		}
+	}
+	var orders []*goshopify.Order
+	byName := map[string]*goshopify.Order{}
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		o := goshopify.Order{}
+		li := goshopify.LineItem{}
+		for i, s := range row {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			if err := setCSVValue(orderCSVColumns[header[i]](&o, &li), s); err != nil {
+				return nil, fmt.Errorf("line %d: %s: %w", line, header[i], err)
+			}
+		}
+		if o.Name == "" {
+			return nil, fmt.Errorf("line %d: order name is required", line)
+		}
+		existing := byName[o.Name]
+		if existing == nil {
+			existing = &goshopify.Order{}
+			byName[o.Name] = existing
+			orders = append(orders, existing)
+		}
+		mergeOrderFields(existing, o)
+		existing.LineItems = append(existing.LineItems, li)
+	}
+	result := make([]goshopify.Order, len(orders))
+	for i, o := range orders {
+		result[i] = *o
+	}
+	return result, nil
+}
+
+// mergeOrderFields sets the order fields of dst that are empty to those
+// of src.
+func mergeOrderFields(dst *goshopify.Order, src goshopify.Order) {
+	for _, f := range []struct{ dst, src *string }{
+		{&dst.Name, &src.Name},
+		{&dst.Email, &src.Email},
+		{&dst.Phone, &src.Phone},
+		{&dst.Currency, &src.Currency},
+		{&dst.FinancialStatus, &src.FinancialStatus},
+		{&dst.Note, &src.Note},
+		{&dst.Tags, &src.Tags},
+		{&dst.SourceName, &src.SourceName},
+	} {
+		if *f.dst == "" {
+			*f.dst = *f.src
+		}
+	}
+}
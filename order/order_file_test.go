@@ -0,0 +1,43 @@
+package order
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOrders(t *testing.T) {
+	f, err := os.Open("../testdata/orders.csv")
+	require.NoError(t, err)
+	defer f.Close()
+	got, err := ReadOrders(f, "csv")
+	require.NoError(t, err)
+	price := func(s string) *decimal.Decimal {
+		d := decimal.RequireFromString(s)
+		return &d
+	}
+	want := []goshopify.Order{
+		{Name: "#1001", Email: "jay@example.com", FinancialStatus: "paid", LineItems: []goshopify.LineItem{
+			{SKU: "TEE-S-BLK", Quantity: 2, Price: price("25.00")},
+			{SKU: "GIFT-50", Quantity: 1, Price: price("50")},
+		}},
+		{Name: "#1002", Email: "mary@example.com", FinancialStatus: "pending", LineItems: []goshopify.LineItem{
+			{SKU: "TEE-M-BLK", Quantity: 1, Price: price("25.00")},
+		}},
+	}
+	require.Equal(t, want, got)
+
+	got, err = ReadOrders(strings.NewReader("name,variant_id\n#1,42\n"), "csv")
+	require.NoError(t, err)
+	require.Equal(t, int64(42), got[0].LineItems[0].VariantID)
+	_, err = ReadOrders(strings.NewReader("name,colour\n#1,red\n"), "csv")
+	require.ErrorContains(t, err, `unknown CSV column "colour"`)
+	_, err = ReadOrders(strings.NewReader("name,sku\n,A\n"), "csv")
+	require.ErrorContains(t, err, "line 2: order name is required")
+	_, err = ReadOrders(strings.NewReader(`{"name": "#1"}`), "xml")
+	require.ErrorContains(t, err, `unknown order file format "xml"`)
+}
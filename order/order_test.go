@@ -0,0 +1,186 @@
+package order
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+// testClient returns a client sending all requests to a local test
+// server running handler.
+func testClient(t *testing.T, handler http.Handler) Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewShopifyClient(fake.Client(srv.URL))
+}
+
+// failFulfillClient is a client whose fulfillments fail.
+type failFulfillClient struct {
+	Client
+}
+
+func (failFulfillClient) CreateFulfillment(FulfillmentRequest) (*goshopify.Fulfillment, error) {
+	return nil, fmt.Errorf("fulfillment failed")
+}
+
+func TestMerge(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	o := &goshopify.Order{Name: "order1", Email: "jay@example.com", LineItems: []goshopify.LineItem{{Title: "Tee", Quantity: 1}}}
+
+	result, err := Merge(client, o, MergeOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "created", result.Label)
+	orders := srv.Orders()
+	require.Len(t, orders, 1)
+	require.Equal(t, result.OrderID, orders[0].ID)
+
+	mf := goshopify.Metafield{Namespace: "erp", Key: "ref", Type: "single_line_text_field", Value: "r-1"}
+	update := &goshopify.Order{Name: "order1", Email: "mary@example.com", Metafields: []goshopify.Metafield{mf}}
+	result, err = Merge(client, update, MergeOptions{})
+	require.NoError(t, err)
+	require.Equal(t, &MergeResult{Label: "updated", OrderID: orders[0].ID}, result)
+	orders = srv.Orders()
+	require.Len(t, orders, 1)
+	require.Equal(t, "mary@example.com", orders[0].Email)
+	require.Len(t, orders[0].LineItems, 1)
+	require.Len(t, srv.Metafields(ResourceOrders, orders[0].ID), 1)
+
+	// transactions are only validated when the order is created
+	update.Transactions = []goshopify.Transaction{tx("gift", "1")}
+	_, err = Merge(client, update, MergeOptions{ValidateTransactions: true})
+	require.NoError(t, err)
+	o2 := &goshopify.Order{Name: "order2", Transactions: update.Transactions}
+	_, err = Merge(client, o2, MergeOptions{ValidateTransactions: true})
+	require.Error(t, err)
+	require.Len(t, srv.Orders(), 1)
+
+	_, err = client.CreateOrder(goshopify.Order{Name: "order1"})
+	require.NoError(t, err)
+	_, err = Merge(client, update, MergeOptions{})
+	require.ErrorContains(t, err, `expected at most one order with name "order1", found 2`)
+
+	// the order created before the fulfillment failed is returned
+	o3 := &goshopify.Order{Name: "order3", LineItems: []goshopify.LineItem{{Title: "Tee", Quantity: 1}}}
+	result, err = Merge(failFulfillClient{client}, o3, MergeOptions{Fulfill: true})
+	require.EqualError(t, err, fmt.Sprintf("order %d created but not fulfilled: fulfillment failed", result.OrderID))
+	require.Equal(t, "created", result.Label)
+}
+
+func TestReplace(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	srv.AddProducts(goshopify.Product{ID: 1, Variants: []goshopify.Variant{{ID: 2, ProductID: 1, InventoryItemId: 3, Sku: "TEE-M"}}})
+	client := NewShopifyClient(srv.Client())
+	_, err := client.SetInventoryLevel(InventoryLevel{InventoryItemID: 3, LocationID: 1, Available: 5})
+	require.NoError(t, err)
+	o := &goshopify.Order{Name: "order1", LineItems: []goshopify.LineItem{{VariantID: 2, Quantity: 1}}}
+
+	// The first order decrements the inventory.
+	created, err := Replace(client, o, CreateOptions{Inventory: true})
+	require.NoError(t, err)
+	require.Equal(t, 4, srv.Inventory(3, 1))
+
+	// Replacing it does not, as the inventory has been decremented for
+	// the deleted order.
+	replaced, err := Replace(client, o, CreateOptions{Inventory: true})
+	require.NoError(t, err)
+	require.NotEqual(t, created.ID, replaced.ID)
+	require.Equal(t, 4, srv.Inventory(3, 1))
+	orders := srv.Orders()
+	require.Len(t, orders, 1)
+	require.Equal(t, replaced.ID, orders[0].ID)
+}
+
+func TestCreate(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	srv.AddProducts(goshopify.Product{ID: 1, Variants: []goshopify.Variant{{ID: 2, ProductID: 1, InventoryItemId: 3}}})
+	srv.AddCustomers(goshopify.Customer{ID: 7, Email: "mary@example.com"})
+	client := NewShopifyClient(srv.Client())
+	o := &goshopify.Order{
+		Name:      "order1",
+		Email:     "Mary@example.com",
+		LineItems: []goshopify.LineItem{{VariantID: 2, Quantity: 2}},
+	}
+
+	_, err := Create(client, o, CreateOptions{VerifyProduct: true})
+	require.ErrorContains(t, err, "invalid inventory")
+	_, err = client.SetInventoryLevel(InventoryLevel{InventoryItemID: 3, LocationID: 1, Available: 5})
+	require.NoError(t, err)
+
+	created, err := Create(client, o, CreateOptions{VerifyProduct: true, Inventory: true, Fulfill: true, Customer: CustomerStrategyLink})
+	require.NoError(t, err)
+	require.Equal(t, int64(7), created.Customer.ID)
+	require.Nil(t, o.Customer, "order must not be changed")
+	require.Equal(t, 4, srv.Inventory(3, 1))
+	got, err := client.GetOrder(created.ID)
+	require.NoError(t, err)
+	require.Equal(t, "fulfilled", got.FulfillmentStatus)
+
+	partial, err := Create(failFulfillClient{client}, o, CreateOptions{Fulfill: true})
+	require.EqualError(t, err, fmt.Sprintf("order %d created but not fulfilled: fulfillment failed", partial.ID))
+	require.NoError(t, DeleteByID(client, partial.ID))
+
+	_, err = Create(client, o, CreateOptions{Unique: true})
+	require.EqualError(t, err, `order with name "order1" already exists`)
+
+	ids, err := Delete(client, "order1", DeleteOptions{Max: -1})
+	require.NoError(t, err)
+	require.Equal(t, []int64{created.ID}, ids)
+	require.Empty(t, srv.Orders())
+	require.Error(t, DeleteByID(client, created.ID))
+}
+
+func TestEachOrder(t *testing.T) {
+	var orders []goshopify.Order
+	for i := 1; i <= 251; i++ {
+		orders = append(orders, goshopify.Order{ID: int64(i), Name: fmt.Sprintf("order%d", i)})
+	}
+	srv := fake.NewServer(orders...)
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+
+	var ids []int64
+	require.NoError(t, EachOrder(client, "", func(o goshopify.Order) error {
+		ids = append(ids, o.ID)
+		return nil
+	}))
+	require.Len(t, ids, 251)
+	require.Equal(t, int64(251), ids[250])
+
+	n := 0
+	err := EachOrder(client, "", func(o goshopify.Order) error {
+		if n++; n == 3 {
+			return fmt.Errorf("write failed")
+		}
+		return nil
+	})
+	require.EqualError(t, err, "write failed")
+	require.Equal(t, 3, n)
+}
+
+func TestCustomerMerge(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	srv.AddCustomers(goshopify.Customer{ID: 1, Email: "mary@example.com", Phone: "+61400000001"})
+
+	c, err := CustomerMerge(client, &goshopify.Customer{Email: "Mary@example.com", FirstName: "Mary"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), c.ID)
+
+	// customers are matched by email only, not by phone
+	c, err = CustomerMerge(client, &goshopify.Customer{Email: "jay@example.com", Phone: "+61400000001"})
+	require.NoError(t, err)
+	require.NotEqual(t, int64(1), c.ID)
+	_, err = CustomerMerge(client, &goshopify.Customer{Phone: "+61400000001"})
+	require.EqualError(t, err, "email is empty")
+}
@@ -0,0 +1,241 @@
+package order
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// phoneRegion holds the dialling rules of a country.
+type phoneRegion struct {
+	callingCode string
+	// trunkPrefix is dropped from national numbers, e.g. the 0 in
+	// (03) 9123 4567.
+	trunkPrefix string
+	// internationalPrefix introduces a number with country code, e.g. 00.
+	internationalPrefix string
+}
+
+// phoneRegions maps the ISO 3166-1 alpha-2 codes of all countries, see
+// countries, to their dialling rules.
+var phoneRegions = map[string]phoneRegion{}
+
+//go:embed data/phone_regions.csv
+var phoneRegionsCSV string
+
+func init() {
+	rows, err := csv.NewReader(strings.NewReader(phoneRegionsCSV)).ReadAll()
+	if err != nil {
+		panic(err)
+	}
+	for _, row := range rows[1:] {
+		phoneRegions[row[0]] = phoneRegion{callingCode: row[1], trunkPrefix: row[2], internationalPrefix: row[3]}
+	}
+}
+
+// PhoneError reports a phone number that cannot be normalised.
+type PhoneError struct {
+	Field  string // e.g. customer.phone
+	Phone  string
+	Reason string
+}
+
+type PhoneErrors []PhoneError
+
+func (e PhoneError) Error() string {
+	return fmt.Sprintf("%s: phone %q: %s", e.Field, e.Phone, e.Reason)
+}
+
+func (e PhoneErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, pe := range e {
+		msgs[i] = pe.Error()
+	}
+	return "invalid phone numbers: " + strings.Join(msgs, "; ")
+}
+
+// NormalisePhone converts phone to E.164, e.g. "(03) 9123 4567" with
+// region AU becomes "+61391234567". Numbers starting with "+" or the
+// region's international prefix keep their country code, all other
+// numbers are taken as national numbers of region, given as ISO 3166-1
+// alpha-2 country code or country name.
+func NormalisePhone(phone, region string) (string, error) {
+	phone = strings.TrimSpace(phone)
+	if phone == "" {
+		return "", nil
+	}
+	digits := phoneDigits(phone)
+	// the trunk prefix often written after the country code, as in
+	// +61 (0)4..., is not dialled with it
+	international := phoneDigits(strings.Replace(phone, "(0)", "", 1))
+	if strings.HasPrefix(phone, "+") {
+		return e164(international)
+	}
+	code, r, ok := lookupPhoneRegion(region)
+	if !ok {
+		if region == "" {
+			return "", fmt.Errorf("phone %q: no country code and no region", phone)
+		}
+		return "", fmt.Errorf("phone %q: unknown region %q", phone, region)
+	}
+	if r.internationalPrefix != "" && strings.HasPrefix(digits, r.internationalPrefix) {
+		return e164(strings.TrimPrefix(international, r.internationalPrefix))
+	}
+	national := digits
+	if r.trunkPrefix == "1" {
+		// NANP numbers have 10 digits, optionally prefixed by 1
+		if len(national) == 11 && strings.HasPrefix(national, "1") {
+			national = national[1:]
+		}
+		if len(national) != 10 {
+			return "", fmt.Errorf("phone %q: %s numbers have 10 digits", phone, countries[code].name)
+		}
+	} else if r.trunkPrefix != "" {
+		national = strings.TrimPrefix(national, r.trunkPrefix)
+	}
+	return e164(r.callingCode + national)
+}
+
+// NormaliseOrderPhones converts the phones of the order, its customer and
+// its billing and shipping address to E.164. Address phones use the
+// address country as region, the order and customer phone use the
+// country of the shipping, billing or customer default address and
+// defaultRegion as fallback.
+func NormaliseOrderPhones(order *goshopify.Order, defaultRegion string) error {
+	region := ""
+	for _, a := range []*goshopify.Address{order.ShippingAddress, order.BillingAddress} {
+		if a != nil && region == "" {
+			region = addressRegion(a.CountryCode, a.Country)
+		}
+	}
+	if c := order.Customer; c != nil && c.DefaultAddress != nil && region == "" {
+		region = addressRegion(c.DefaultAddress.CountryCode, c.DefaultAddress.Country)
+	}
+	region = regionOr(region, defaultRegion)
+	n := &phoneNormaliser{}
+	n.normalise("phone", &order.Phone, region)
+	if a := order.BillingAddress; a != nil {
+		n.normalise("billing_address.phone", &a.Phone, regionOr(addressRegion(a.CountryCode, a.Country), region))
+	}
+	if a := order.ShippingAddress; a != nil {
+		n.normalise("shipping_address.phone", &a.Phone, regionOr(addressRegion(a.CountryCode, a.Country), region))
+	}
+	if order.Customer != nil {
+		n.customer("customer.", order.Customer, region)
+	}
+	return n.err()
+}
+
+// NormaliseCustomerPhones converts the phones of customer and its
+// addresses to E.164, using the country of the default or first address
+// or defaultRegion as region of the customer phone.
+func NormaliseCustomerPhones(customer *goshopify.Customer, defaultRegion string) error {
+	region := ""
+	if a := customer.DefaultAddress; a != nil {
+		region = addressRegion(a.CountryCode, a.Country)
+	} else if len(customer.Addresses) != 0 && customer.Addresses[0] != nil {
+		region = addressRegion(customer.Addresses[0].CountryCode, customer.Addresses[0].Country)
+	}
+	n := &phoneNormaliser{}
+	n.customer("", customer, regionOr(region, defaultRegion))
+	return n.err()
+}
+
+// phoneNormaliser normalises phones in place, collecting errors.
+type phoneNormaliser struct {
+	errs PhoneErrors
+}
+
+func (n *phoneNormaliser) normalise(field string, phone *string, region string) {
+	p, err := NormalisePhone(*phone, region)
+	if err != nil {
+		n.errs = append(n.errs, PhoneError{Field: field, Phone: *phone, Reason: phoneErrorReason(err)})
+		return
+	}
+	*phone = p
+}
+
+func (n *phoneNormaliser) customer(prefix string, c *goshopify.Customer, region string) {
+	n.normalise(prefix+"phone", &c.Phone, region)
+	if a := c.DefaultAddress; a != nil {
+		n.normalise(prefix+"default_address.phone", &a.Phone, regionOr(addressRegion(a.CountryCode, a.Country), region))
+	}
+	for i, a := range c.Addresses {
+		if a != nil && a != c.DefaultAddress {
+			n.normalise(fmt.Sprintf("%saddresses.%d.phone", prefix, i), &a.Phone, regionOr(addressRegion(a.CountryCode, a.Country), region))
+		}
+	}
+}
+
+func (n *phoneNormaliser) err() error {
+	if len(n.errs) != 0 {
+		return n.errs
+	}
+	return nil
+}
+
+// phoneKey returns phone in E.164 or, if it cannot be normalised, its
+// digits so that it can still be compared.
+func phoneKey(phone, region string) string {
+	if p, err := NormalisePhone(phone, region); err == nil {
+		return p
+	}
+	return phoneDigits(strings.TrimSpace(phone))
+}
+
+func e164(digits string) (string, error) {
+	if len(digits) < 8 || len(digits) > 15 {
+		return "", fmt.Errorf("phone +%s: E.164 numbers have 8 to 15 digits", digits)
+	}
+	return "+" + digits, nil
+}
+
+// phoneDigits strips everything but digits from phone.
+func phoneDigits(phone string) string {
+	var b strings.Builder
+	for _, c := range phone {
+		if c >= '0' && c <= '9' {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// lookupPhoneRegion returns the country code and dialling rules of region.
+func lookupPhoneRegion(region string) (string, phoneRegion, bool) {
+	code, ok := lookupCountry(region)
+	if !ok {
+		return "", phoneRegion{}, false
+	}
+	r, ok := phoneRegions[code]
+	return code, r, ok
+}
+
+// addressRegion returns the country code of an address if known for
+// phone normalisation, falling back to the country name.
+func addressRegion(countryCode, country string) string {
+	for _, region := range []string{countryCode, country} {
+		if _, _, ok := lookupPhoneRegion(region); ok {
+			return region
+		}
+	}
+	return ""
+}
+
+func regionOr(region, fallback string) string {
+	if region != "" {
+		return region
+	}
+	return fallback
+}
+
+func phoneErrorReason(err error) string {
+	msg := err.Error()
+	if _, reason, ok := strings.Cut(msg, ": "); ok {
+		return reason
+	}
+	return msg
+}
@@ -0,0 +1,93 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalisePhone(t *testing.T) {
+	tests := []struct {
+		phone, region, want string
+	}{
+		{"0412 345 678", "AU", "+61412345678"},
+		{"+61 412 345 678", "", "+61412345678"},
+		{"+61 (0)412 345 678", "", "+61412345678"},
+		{"0011 44 (0)20 7946 0018", "AU", "+442079460018"},
+		{"(03) 9123 4567", "AU", "+61391234567"},
+		{"(03) 9123 4567", "Australia", "+61391234567"},
+		{"0011 64 21 123 4567", "AU", "+64211234567"},
+		{"020 7946 0018", "gb", "+442079460018"},
+		{"(212) 555-0100", "US", "+12125550100"},
+		{"1-212-555-0100", "US", "+12125550100"},
+		{"06 1234 5678", "IT", "+390612345678"},
+		{"8 (495) 123-45-67", "RU", "+74951234567"},
+		{"0301 234 5678", "Pakistan", "+923012345678"},
+		{"679 1234", "FJ", "+6796791234"},
+		{"(868) 555-0100", "TT", "+18685550100"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		got, err := NormalisePhone(tt.phone, tt.region)
+		require.NoError(t, err, tt.phone)
+		require.Equal(t, tt.want, got, tt.phone)
+	}
+	for _, tt := range []struct{ phone, region string }{
+		{"0412 345 678", ""},
+		{"0412 345 678", "XX"},
+		{"555-0100", "US"},
+		{"+61 4", ""},
+	} {
+		_, err := NormalisePhone(tt.phone, tt.region)
+		require.Error(t, err, tt.phone)
+	}
+}
+
+func TestPhoneRegionsCoverCountries(t *testing.T) {
+	for code := range countries {
+		_, _, ok := lookupPhoneRegion(code)
+		require.True(t, ok, code)
+	}
+}
+
+func TestNormaliseOrderPhones(t *testing.T) {
+	o := &goshopify.Order{
+		Phone:           "0412 345 678",
+		BillingAddress:  &goshopify.Address{Phone: "(03) 9123 4567", CountryCode: "AU"},
+		ShippingAddress: &goshopify.Address{Phone: "09 123 4567", Country: "New Zealand"},
+		Customer: &goshopify.Customer{
+			Phone:     "0412345678",
+			Addresses: []*goshopify.CustomerAddress{{Phone: "(212) 555-0100", CountryCode: "US"}},
+		},
+	}
+	require.NoError(t, NormaliseOrderPhones(o, ""))
+	require.Equal(t, "+64412345678", o.Phone) // region of shipping address
+	require.Equal(t, "+61391234567", o.BillingAddress.Phone)
+	require.Equal(t, "+6491234567", o.ShippingAddress.Phone)
+	require.Equal(t, "+64412345678", o.Customer.Phone)
+	require.Equal(t, "+12125550100", o.Customer.Addresses[0].Phone)
+
+	o = &goshopify.Order{Phone: "0412 345 678", Customer: &goshopify.Customer{Phone: "12"}}
+	err := NormaliseOrderPhones(o, "")
+	require.Error(t, err)
+	require.Len(t, err.(PhoneErrors), 2)
+	require.NoError(t, NormaliseOrderPhones(&goshopify.Order{Phone: "0412 345 678"}, "AU"))
+}
+
+func TestCreateNormalisesPhones(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	srv.AddCustomers(goshopify.Customer{ID: 1, Email: "mary@example.com", Phone: "+61412345678"})
+	o := &goshopify.Order{
+		Name:           "order1",
+		Customer:       &goshopify.Customer{Phone: "0412 345 678"},
+		BillingAddress: &goshopify.Address{Phone: "0412 345 678", CountryCode: "AU"},
+	}
+	created, err := Create(NewShopifyClient(srv.Client()), o, CreateOptions{NormalisePhones: true, Customer: CustomerStrategyLink})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), created.Customer.ID)
+	require.Equal(t, "+61412345678", created.BillingAddress.Phone)
+	require.Equal(t, "0412 345 678", o.BillingAddress.Phone)
+}
@@ -0,0 +1,396 @@
+package order
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/shopspring/decimal"
+)
+
+type ProductImportOptions struct {
+	// LocationID is the location of the initial inventory given by the
+	// variants' inventory_quantity. Defaults to the store's only active
+	// location.
+	LocationID int64
+	DryRun     bool
+}
+
+type ProductImportResult struct {
+	Handle    string `json:"handle"`
+	Action    string `json:"action"` // created or skipped
+	ProductID int64  `json:"product_id,omitempty"`
+	Variants  int    `json:"variants"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+type ProductImportReport struct {
+	Created int                   `json:"created"`
+	Skipped int                   `json:"skipped"`
+	Results []ProductImportResult `json:"results"`
+}
+
+// productCSVColumns maps CSV header columns to the product or variant
+// field they set, a *string, *int or **decimal.Decimal.
+var productCSVColumns = map[string]func(p *goshopify.Product, v *goshopify.Variant) interface{}{
+	"handle":             func(p *goshopify.Product, _ *goshopify.Variant) interface{} { return &p.Handle },
+	"title":              func(p *goshopify.Product, _ *goshopify.Variant) interface{} { return &p.Title },
+	"body_html":          func(p *goshopify.Product, _ *goshopify.Variant) interface{} { return &p.BodyHTML },
+	"vendor":             func(p *goshopify.Product, _ *goshopify.Variant) interface{} { return &p.Vendor },
+	"product_type":       func(p *goshopify.Product, _ *goshopify.Variant) interface{} { return &p.ProductType },
+	"tags":               func(p *goshopify.Product, _ *goshopify.Variant) interface{} { return &p.Tags },
+	"option1_name":       func(p *goshopify.Product, _ *goshopify.Variant) interface{} { return optionName(p, 0) },
+	"option2_name":       func(p *goshopify.Product, _ *goshopify.Variant) interface{} { return optionName(p, 1) },
+	"option3_name":       func(p *goshopify.Product, _ *goshopify.Variant) interface{} { return optionName(p, 2) },
+	"option1":            func(_ *goshopify.Product, v *goshopify.Variant) interface{} { return &v.Option1 },
+	"option2":            func(_ *goshopify.Product, v *goshopify.Variant) interface{} { return &v.Option2 },
+	"option3":            func(_ *goshopify.Product, v *goshopify.Variant) interface{} { return &v.Option3 },
+	"sku":                func(_ *goshopify.Product, v *goshopify.Variant) interface{} { return &v.Sku },
+	"barcode":            func(_ *goshopify.Product, v *goshopify.Variant) interface{} { return &v.Barcode },
+	"price":              func(_ *goshopify.Product, v *goshopify.Variant) interface{} { return &v.Price },
+	"compare_at_price":   func(_ *goshopify.Product, v *goshopify.Variant) interface{} { return &v.CompareAtPrice },
+	"grams":              func(_ *goshopify.Product, v *goshopify.Variant) interface{} { return &v.Grams },
+	"inventory_quantity": func(_ *goshopify.Product, v *goshopify.Variant) interface{} { return &v.InventoryQuantity },
+	"inventory_policy":   func(_ *goshopify.Product, v *goshopify.Variant) interface{} { return &v.InventoryPolicy },
+}
+
+// ListProducts returns all products matching opts, paging through the
+// REST API.
+func ListProducts(client Client, opts goshopify.ProductListOptions) ([]goshopify.Product, error) {
+	return client.ListProducts(opts)
+}
+
+// ReadProducts reads products with their variants from JSON, a single
+// product or an array, JSON lines or CSV. CSV files need a header row with
+// columns named like the JSON fields, e.g. title, sku and price, and
+// option1_name and option1 for options. Rows with the same handle, or
+// title if there is no handle, are variants of the same product, product
+// columns are taken from the first row that sets them.
+func ReadProducts(r io.Reader, format string) ([]goshopify.Product, error) {
+	switch format {
+	case "json", "jsonl":
+		return readJSONRecords[goshopify.Product](r, "product")
+	case "csv":
+		return readProductsCSV(r)
+	default:
+		return nil, fmt.Errorf("unknown product file format %q", format)
+	}
+}
+
+// readJSONRecords reads a JSON array, a single JSON object or JSON lines
+// of records.
+func readJSONRecords[T any](r io.Reader, kind string) ([]T, error) {
+	br := bufio.NewReader(r)
+	if first, err := firstNonSpace(br); err == nil && first == '[' {
+		var records []T
+		if err := json.NewDecoder(br).Decode(&records); err != nil {
+			return nil, err
+		}
+		return records, nil
+	}
+	var records []T
+	dec := json.NewDecoder(br)
+	for {
+		var record T
+		err := dec.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s record %d: %w", kind, len(records)+1, err)
+		}
+		records = append(records, record)
+	}
+}
+
+// firstNonSpace returns the first non-space byte of br without consuming
+// it.
+func firstNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if !unicode.IsSpace(rune(b)) {
+			return b, br.UnreadByte()
+		}
+	}
+}
+
+func readProductsCSV(r io.Reader) ([]goshopify.Product, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read CSV header: %w", err)
+	}
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		if productCSVColumns[header[i]] == nil {
+			return nil, fmt.Errorf("unknown CSV column %q", column)
+		}
+	}
+	var products []*goshopify.Product
+	byKey := map[string]*goshopify.Product{}
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		p := goshopify.Product{}
+		v := goshopify.Variant{}
+		for i, s := range row {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			if err := setCSVValue(productCSVColumns[header[i]](&p, &v), s); err != nil {
+				return nil, fmt.Errorf("line %d: %s: %w", line, header[i], err)
+			}
+		}
+		key := p.Handle
+		if key == "" {
+			key = ProductHandle(p.Title)
+		}
+		if key == "" {
+			return nil, fmt.Errorf("line %d: product has neither handle nor title", line)
+		}
+		existing := byKey[key]
+		if existing == nil {
+			existing = &goshopify.Product{}
+			byKey[key] = existing
+			products = append(products, existing)
+		}
+		mergeProduct(existing, p)
+		existing.Variants = append(existing.Variants, v)
+	}
+	result := make([]goshopify.Product, len(products))
+	for i, p := range products {
+		result[i] = *p
+	}
+	return result, nil
+}
+
+// mergeProduct sets the product fields of dst that are empty to those of
+// src.
+func mergeProduct(dst *goshopify.Product, src goshopify.Product) {
+	for _, f := range []struct{ dst, src *string }{
+		{&dst.Handle, &src.Handle},
+		{&dst.Title, &src.Title},
+		{&dst.BodyHTML, &src.BodyHTML},
+		{&dst.Vendor, &src.Vendor},
+		{&dst.ProductType, &src.ProductType},
+		{&dst.Tags, &src.Tags},
+	} {
+		if *f.dst == "" {
+			*f.dst = *f.src
+		}
+	}
+	for i, o := range src.Options {
+		if i >= len(dst.Options) {
+			dst.Options = append(dst.Options, o)
+		} else if dst.Options[i].Name == "" {
+			dst.Options[i].Name = o.Name
+		}
+	}
+}
+
+// optionName returns the name of the i-th product option, adding options
+// as needed.
+func optionName(p *goshopify.Product, i int) *string {
+	for len(p.Options) <= i {
+		p.Options = append(p.Options, goshopify.ProductOption{})
+	}
+	return &p.Options[i].Name
+}
+
+func setCSVValue(dst interface{}, s string) error {
+	switch dst := dst.(type) {
+	case *string:
+		*dst = s
+	case *int:
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		*dst = i
+	case *int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		*dst = i
+	case **decimal.Decimal:
+		d, err := decimal.NewFromString(s)
+		if err != nil {
+			return err
+		}
+		*dst = &d
+	default:
+		return fmt.Errorf("unsupported field type %T", dst)
+	}
+	return nil
+}
+
+// ProductHandle returns the handle Shopify derives from a product title,
+// e.g. "Blue T-Shirt (XL)" becomes "blue-t-shirt-xl".
+func ProductHandle(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() != 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
+
+// ImportProducts creates products with their variants and sets the
+// initial inventory of variants with inventory_quantity at
+// opts.LocationID. Products are matched by handle, derived from the title
+// if not set, and existing products are skipped so that the import can
+// be repeated.
+func ImportProducts(client Client, products []goshopify.Product, opts ProductImportOptions) (*ProductImportReport, error) {
+	return ImportProductsContext(client.Context(), staticClient(client), products, opts)
+}
+
+// ImportProductsContext is like ImportProducts but stops before the next
+// product once ctx is done, returning the report so far. Each product is
+// imported with a client created by newClient, see RunOperation.
+func ImportProductsContext(ctx context.Context, newClient ClientFunc, products []goshopify.Product, opts ProductImportOptions) (*ProductImportReport, error) {
+	report := &ProductImportReport{}
+	locationID := opts.LocationID
+	for _, p := range products {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		err := RunOperation(ctx, newClient, func(client Client) error {
+			return importProduct(client, p, &locationID, opts.DryRun, report)
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// importProduct creates p unless a product with its handle exists, and
+// adds the result to report. locationID is set to the default location
+// if zero and needed for the initial inventory.
+func importProduct(client Client, p goshopify.Product, locationID *int64, dryRun bool, report *ProductImportReport) error {
+	if p.Handle == "" {
+		p.Handle = ProductHandle(p.Title)
+	}
+	result := ProductImportResult{Handle: p.Handle, Variants: len(p.Variants)}
+	existing, err := client.ListProducts(goshopify.ProductListOptions{Handle: p.Handle})
+	if err != nil {
+		return fmt.Errorf("product %q: %w", p.Handle, err)
+	}
+	if len(existing) != 0 {
+		result.Action = ImportSkipped
+		result.ProductID = existing[0].ID
+		result.Reason = "product with handle exists"
+		report.Skipped++
+		report.Results = append(report.Results, result)
+		return nil
+	}
+	result.Action = ImportCreated
+	report.Created++
+	if dryRun {
+		report.Results = append(report.Results, result)
+		return nil
+	}
+	quantities := make([]int, len(p.Variants))
+	p.Variants = append([]goshopify.Variant(nil), p.Variants...)
+	stocked := false
+	for i := range p.Variants {
+		v := &p.Variants[i]
+		quantities[i] = v.InventoryQuantity
+		v.InventoryQuantity = 0
+		if quantities[i] != 0 {
+			stocked = true
+			if v.InventoryManagement == "" {
+				v.InventoryManagement = "shopify"
+			}
+		}
+	}
+	p.Options = productOptions(p)
+	created, err := client.CreateProduct(p)
+	if err != nil {
+		return fmt.Errorf("product %q: %w", p.Handle, err)
+	}
+	result.ProductID = created.ID
+	report.Results = append(report.Results, result)
+	if !stocked {
+		return nil
+	}
+	if *locationID == 0 {
+		if *locationID, err = DefaultLocationID(client); err != nil {
+			return err
+		}
+	}
+	for i, v := range created.Variants {
+		if i >= len(quantities) || quantities[i] == 0 {
+			continue
+		}
+		if _, err := SetInventoryLevel(client, *locationID, v.InventoryItemId, quantities[i]); err != nil {
+			return fmt.Errorf("product %q: variant %q: %w", p.Handle, v.Title, err)
+		}
+	}
+	return nil
+}
+
+// productOptions returns the product options with the values used by
+// its variants.
+func productOptions(p goshopify.Product) []goshopify.ProductOption {
+	if len(p.Options) == 0 {
+		return nil
+	}
+	options := make([]goshopify.ProductOption, len(p.Options))
+	for i, o := range p.Options {
+		options[i] = goshopify.ProductOption{Name: o.Name, Values: o.Values}
+		if len(o.Values) != 0 {
+			continue
+		}
+		for _, v := range p.Variants {
+			value := []string{v.Option1, v.Option2, v.Option3}[i]
+			if value != "" && !containsString(options[i].Values, value) {
+				options[i].Values = append(options[i].Values, value)
+			}
+		}
+	}
+	return options
+}
+
+// DefaultLocationID returns the ID of the store's only active location.
+func DefaultLocationID(client Client) (int64, error) {
+	locations, err := client.ListLocations()
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for _, l := range locations {
+		if l.Active {
+			ids = append(ids, l.ID)
+		}
+	}
+	if len(ids) != 1 {
+		return 0, fmt.Errorf("expected one active location, found %d, location ID required", len(ids))
+	}
+	return ids[0], nil
+}
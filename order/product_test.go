@@ -0,0 +1,99 @@
+package order
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadProducts(t *testing.T) {
+	f, err := os.Open("../testdata/products.csv")
+	require.NoError(t, err)
+	defer f.Close()
+	got, err := ReadProducts(f, "csv")
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	tee := got[0]
+	require.Equal(t, "Classic Tee", tee.Title)
+	require.Equal(t, "cotton, basics", tee.Tags)
+	require.Equal(t, []goshopify.ProductOption{{Name: "Size"}, {Name: "Colour"}}, tee.Options)
+	require.Len(t, tee.Variants, 3)
+	require.Equal(t, "L", tee.Variants[2].Option1)
+	require.Equal(t, "White", tee.Variants[2].Option2)
+	require.Equal(t, "27.5", tee.Variants[2].Price.String())
+	require.Equal(t, 5, tee.Variants[1].InventoryQuantity)
+	require.Equal(t, "", got[1].Handle)
+	require.Equal(t, "GIFT-50", got[1].Variants[0].Sku)
+
+	_, err = ReadProducts(strings.NewReader("title,colour\nTee,red\n"), "csv")
+	require.Error(t, err)
+	_, err = ReadProducts(strings.NewReader("title,price\nTee,cheap\n"), "csv")
+	require.ErrorContains(t, err, "line 2: price: can't convert cheap to decimal")
+
+	f, err = os.Open("../testdata/products.json")
+	require.NoError(t, err)
+	defer f.Close()
+	got, err = ReadProducts(f, "json")
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Len(t, got[0].Variants, 2)
+
+	got, err = ReadProducts(strings.NewReader(`{"title": "A"}`+"\n"+`{"title": "B"}`), "jsonl")
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+}
+
+func TestProductHandle(t *testing.T) {
+	require.Equal(t, "blue-t-shirt-xl", ProductHandle("Blue T-Shirt (XL)"))
+	require.Equal(t, "gift-card", ProductHandle("  Gift   Card! "))
+	require.Equal(t, "", ProductHandle("!!!"))
+}
+
+func TestImportProducts(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	srv.LocationID = 7
+	srv.AddProducts(goshopify.Product{ID: 1, Title: "Gift Card", Handle: "gift-card"})
+	client := NewShopifyClient(srv.Client())
+	f, err := os.Open("../testdata/products.csv")
+	require.NoError(t, err)
+	defer f.Close()
+	products, err := ReadProducts(f, "csv")
+	require.NoError(t, err)
+
+	report, err := ImportProducts(client, products, ProductImportOptions{DryRun: true})
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Created)
+	require.Equal(t, 1, report.Skipped)
+	require.Len(t, srv.Products(), 1)
+
+	report, err = ImportProducts(client, products, ProductImportOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []ProductImportResult{
+		{Handle: "classic-tee", Action: ImportCreated, ProductID: report.Results[0].ProductID, Variants: 3},
+		{Handle: "gift-card", Action: ImportSkipped, ProductID: 1, Variants: 1, Reason: "product with handle exists"},
+	}, report.Results)
+	all := srv.Products()
+	require.Len(t, all, 2)
+	tee := all[1]
+	require.Equal(t, []string{"S", "M", "L"}, tee.Options[0].Values)
+	require.Equal(t, []string{"Black", "White"}, tee.Options[1].Values)
+	require.Equal(t, "shopify", tee.Variants[0].InventoryManagement)
+	require.Equal(t, "", tee.Variants[2].InventoryManagement)
+	require.Equal(t, 10, srv.Inventory(tee.Variants[0].InventoryItemId, 7))
+	require.Equal(t, 5, srv.Inventory(tee.Variants[1].InventoryItemId, 7))
+	require.Equal(t, 5, products[0].Variants[1].InventoryQuantity, "input unchanged")
+
+	report, err = ImportProducts(client, products, ProductImportOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Skipped)
+
+	listed, err := ListProducts(client, goshopify.ProductListOptions{Handle: "classic-tee"})
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	require.Equal(t, tee.ID, listed[0].ID)
+}
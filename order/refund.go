@@ -0,0 +1,204 @@
+package order
+
+import (
+	"fmt"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/shopspring/decimal"
+)
+
+// Restock types for refunded line items.
+const (
+	RestockNone   = "no_restock"
+	RestockCancel = "cancel"
+	RestockReturn = "return"
+)
+
+type RefundOptions struct {
+	// LineItems maps line item IDs to the quantity to be refunded.
+	LineItems map[int64]int
+	// FullShipping refunds all shipping costs, ShippingAmount a part.
+	FullShipping   bool
+	ShippingAmount *decimal.Decimal
+	// Amount overrides the amount calculated by Shopify.
+	Amount      *decimal.Decimal
+	RestockType string
+	// LocationID is the location restocked items are returned to.
+	LocationID int64
+	Note       string
+	Notify     bool
+	// DryRun only returns Shopify's refund calculation.
+	DryRun bool
+}
+
+type CancelOptions struct {
+	Reason  string // customer, fraud, inventory, declined or other
+	Restock bool
+	Notify  bool
+}
+
+// Refund is used for both refund calculations and refund creation.
+type Refund struct {
+	ID              int64                   `json:"id,omitempty"`
+	OrderID         int64                   `json:"order_id,omitempty"`
+	Currency        string                  `json:"currency,omitempty"`
+	Notify          bool                    `json:"notify,omitempty"`
+	Note            string                  `json:"note,omitempty"`
+	Shipping        *RefundShipping         `json:"shipping,omitempty"`
+	RefundLineItems []RefundLineItem        `json:"refund_line_items,omitempty"`
+	Transactions    []goshopify.Transaction `json:"transactions,omitempty"`
+}
+
+type RefundShipping struct {
+	FullRefund        bool             `json:"full_refund,omitempty"`
+	Amount            *decimal.Decimal `json:"amount,omitempty"`
+	Tax               *decimal.Decimal `json:"tax,omitempty"`
+	MaximumRefundable *decimal.Decimal `json:"maximum_refundable,omitempty"`
+}
+
+type RefundLineItem struct {
+	LineItemID  int64            `json:"line_item_id"`
+	Quantity    int              `json:"quantity"`
+	RestockType string           `json:"restock_type,omitempty"`
+	LocationID  int64            `json:"location_id,omitempty"`
+	Subtotal    *decimal.Decimal `json:"subtotal,omitempty"`
+	TotalTax    *decimal.Decimal `json:"total_tax,omitempty"`
+}
+
+type RefundResource struct {
+	Refund *Refund `json:"refund"`
+}
+
+// CalculateRefund returns Shopify's calculation of the refund described
+// by refund, including suggested refund transactions.
+func CalculateRefund(client Client, orderID int64, refund Refund) (*Refund, error) {
+	return client.CalculateRefund(orderID, refund)
+}
+
+// CreateRefund calculates the refund for the given line items, shipping
+// and amount and creates it unless opts.DryRun is set, in which case the
+// calculation is returned.
+func CreateRefund(client Client, orderID int64, opts RefundOptions) (*Refund, error) {
+	request := Refund{Note: opts.Note, Notify: opts.Notify}
+	if opts.FullShipping {
+		request.Shipping = &RefundShipping{FullRefund: true}
+	} else if opts.ShippingAmount != nil {
+		request.Shipping = &RefundShipping{Amount: opts.ShippingAmount}
+	}
+	restockType := opts.RestockType
+	if restockType == "" {
+		restockType = RestockNone
+	}
+	for _, id := range sortedKeys(opts.LineItems) {
+		li := RefundLineItem{LineItemID: id, Quantity: opts.LineItems[id], RestockType: restockType}
+		if restockType != RestockNone {
+			li.LocationID = opts.LocationID
+		}
+		request.RefundLineItems = append(request.RefundLineItems, li)
+	}
+	calculated, err := CalculateRefund(client, orderID, request)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := refundTransactions(client, orderID, calculated.Transactions, opts.Amount)
+	if err != nil {
+		return nil, err
+	}
+	calculated.Transactions = transactions
+	if opts.DryRun {
+		return calculated, nil
+	}
+	request.Currency = calculated.Currency
+	request.Transactions = transactions
+	return client.CreateRefund(orderID, request)
+}
+
+// refundTransactions turns suggested refund transactions into refund
+// transactions. If amount is given it replaces the suggested amounts. It
+// is refunded against the suggested transaction, spread over the
+// suggested transactions if there are several, see spreadRefund, or, if
+// there is none, against the first successful sale or capture of the
+// order.
+func refundTransactions(client Client, orderID int64, suggested []goshopify.Transaction, amount *decimal.Decimal) ([]goshopify.Transaction, error) {
+	var result []goshopify.Transaction
+	for _, t := range suggested {
+		if t.Amount == nil || t.Amount.IsZero() {
+			continue
+		}
+		result = append(result, goshopify.Transaction{
+			ParentID: t.ParentID,
+			Amount:   t.Amount,
+			Kind:     "refund",
+			Gateway:  t.Gateway,
+		})
+	}
+	if amount == nil {
+		return result, nil
+	}
+	if len(result) > 1 {
+		return spreadRefund(client, orderID, result, *amount)
+	}
+	if len(result) != 0 {
+		result[0].Amount = amount
+		return result, nil
+	}
+	transactions, err := Transactions(client, orderID)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range transactions {
+		if (t.Kind == "sale" || t.Kind == "capture") && t.Status == "success" {
+			parentID := t.ID
+			return []goshopify.Transaction{{ParentID: &parentID, Amount: amount, Kind: "refund", Gateway: t.Gateway}}, nil
+		}
+	}
+	return nil, fmt.Errorf("order %d: no successful sale or capture transaction to refund", orderID)
+}
+
+// spreadRefund distributes amount over the refund transactions in
+// order, refunding at most the amount of each parent transaction that
+// has not been refunded yet.
+func spreadRefund(client Client, orderID int64, transactions []goshopify.Transaction, amount decimal.Decimal) ([]goshopify.Transaction, error) {
+	orderTransactions, err := Transactions(client, orderID)
+	if err != nil {
+		return nil, err
+	}
+	refundable := map[int64]decimal.Decimal{}
+	for _, t := range orderTransactions {
+		if t.Status != "success" || t.Amount == nil {
+			continue
+		}
+		switch {
+		case t.Kind == "sale" || t.Kind == "capture":
+			refundable[t.ID] = refundable[t.ID].Add(*t.Amount)
+		case t.Kind == "refund" && t.ParentID != nil:
+			refundable[*t.ParentID] = refundable[*t.ParentID].Sub(*t.Amount)
+		}
+	}
+	var result []goshopify.Transaction
+	remaining := amount
+	for _, t := range transactions {
+		if !remaining.IsPositive() {
+			break
+		}
+		if t.ParentID == nil {
+			continue
+		}
+		a := decimal.Min(remaining, refundable[*t.ParentID])
+		if !a.IsPositive() {
+			continue
+		}
+		t.Amount = &a
+		result = append(result, t)
+		remaining = remaining.Sub(a)
+	}
+	if remaining.IsPositive() {
+		return nil, fmt.Errorf("order %d: amount %s exceeds the amount refundable with the suggested transactions", orderID, amount)
+	}
+	return result, nil
+}
+
+func Cancel(client Client, orderID int64, opts CancelOptions) (*goshopify.Order, error) {
+	cancelOpts := goshopify.OrderCancelOptions{Reason: opts.Reason, Restock: opts.Restock, Email: opts.Notify}
+	return client.CancelOrder(orderID, cancelOpts)
+}
@@ -0,0 +1,98 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateRefund(t *testing.T) {
+	price := decimal.NewFromInt(10)
+	shipping := decimal.NewFromInt(5)
+	total := decimal.NewFromInt(25)
+	srv := fake.NewServer(goshopify.Order{
+		ID:            1,
+		Name:          "order1",
+		Currency:      "AUD",
+		LineItems:     []goshopify.LineItem{{ID: 11, Quantity: 2, Price: &price}},
+		ShippingLines: []goshopify.ShippingLines{{Price: &shipping}},
+		Transactions:  []goshopify.Transaction{{ID: 21, Kind: "sale", Status: "success", Amount: &total, Gateway: "manual"}},
+	})
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+
+	opts := RefundOptions{LineItems: map[int64]int{11: 1}, FullShipping: true, DryRun: true}
+	calculated, err := CreateRefund(client, 1, opts)
+	require.NoError(t, err)
+	require.Len(t, calculated.Transactions, 1)
+	require.Equal(t, "refund", calculated.Transactions[0].Kind)
+	require.Equal(t, "15", calculated.Transactions[0].Amount.String())
+	require.Equal(t, int64(21), *calculated.Transactions[0].ParentID)
+	require.Empty(t, srv.Orders()[0].Refunds)
+
+	opts.DryRun = false
+	amount := decimal.NewFromInt(12)
+	opts.Amount = &amount
+	refund, err := CreateRefund(client, 1, opts)
+	require.NoError(t, err)
+	require.NotZero(t, refund.ID)
+	require.Equal(t, "12", refund.Transactions[0].Amount.String())
+	require.Len(t, srv.Orders()[0].Refunds, 1)
+
+	_, err = CreateRefund(client, 1, RefundOptions{LineItems: map[int64]int{99: 1}})
+	require.Error(t, err)
+}
+
+func TestRefundTransactionsSpreadsAmount(t *testing.T) {
+	gift, card, refunded := decimal.NewFromInt(10), decimal.NewFromInt(30), decimal.NewFromInt(4)
+	giftID, cardID := int64(21), int64(22)
+	srv := fake.NewServer(goshopify.Order{
+		ID:   1,
+		Name: "order1",
+		Transactions: []goshopify.Transaction{
+			{ID: 21, Kind: "sale", Status: "success", Amount: &gift, Gateway: "gift_card"},
+			{ID: 22, Kind: "sale", Status: "success", Amount: &card, Gateway: "manual"},
+			{ID: 23, Kind: "refund", Status: "success", Amount: &refunded, ParentID: &giftID, Gateway: "gift_card"},
+		},
+	})
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	suggested := []goshopify.Transaction{
+		{ParentID: &giftID, Amount: &gift, Kind: "suggested_refund", Gateway: "gift_card"},
+		{ParentID: &cardID, Amount: &card, Kind: "suggested_refund", Gateway: "manual"},
+	}
+
+	amount := decimal.NewFromInt(15)
+	got, err := refundTransactions(client, 1, suggested, &amount)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "6", got[0].Amount.String())
+	require.Equal(t, "9", got[1].Amount.String())
+	require.Equal(t, "refund", got[1].Kind)
+
+	amount = decimal.NewFromInt(5)
+	got, err = refundTransactions(client, 1, suggested, &amount)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, "5", got[0].Amount.String())
+
+	amount = decimal.NewFromInt(37)
+	_, err = refundTransactions(client, 1, suggested, &amount)
+	require.EqualError(t, err, "order 1: amount 37 exceeds the amount refundable with the suggested transactions")
+}
+
+func TestCancel(t *testing.T) {
+	srv := fake.NewServer(goshopify.Order{ID: 1, Name: "order1"})
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	id, err := IDByName(client, "order1")
+	require.NoError(t, err)
+	o, err := Cancel(client, id, CancelOptions{Reason: "customer"})
+	require.NoError(t, err)
+	require.NotNil(t, o.CancelledAt)
+	_, err = IDByName(client, "missing")
+	require.Error(t, err)
+}
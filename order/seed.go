@@ -0,0 +1,551 @@
+package order
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// Seed actions.
+const (
+	SeedCreated = "created" // created in the store
+	SeedFound   = "found"   // matched an existing resource by handle, email or name
+	SeedLocked  = "locked"  // recorded in the lockfile and still in the store
+)
+
+// SeedRefs maps fixture refs to the IDs of the resources seeded for them
+// in one store.
+type SeedRefs struct {
+	Locations map[string]int64 `json:"locations,omitempty"`
+	Products  map[string]int64 `json:"products,omitempty"`
+	Variants  map[string]int64 `json:"variants,omitempty"`
+	Customers map[string]int64 `json:"customers,omitempty"`
+	Orders    map[string]int64 `json:"orders,omitempty"`
+}
+
+// Lockfile records the refs of seeded fixtures by store name.
+type Lockfile struct {
+	Stores map[string]*SeedRefs `json:"stores"`
+}
+
+// ProductFixture is a product to be seeded. Ref defaults to the handle.
+type ProductFixture struct {
+	Ref string `json:"ref,omitempty"`
+	goshopify.Product
+	Variants []VariantFixture `json:"variants,omitempty"`
+}
+
+// VariantFixture is a product variant to be seeded, matched by SKU or
+// position within existing products.
+type VariantFixture struct {
+	Ref string `json:"ref,omitempty"`
+	goshopify.Variant
+	// Inventory is the available quantity by location name.
+	Inventory map[string]int `json:"inventory,omitempty"`
+}
+
+// CustomerFixture is a customer to be seeded. Ref defaults to the email.
+type CustomerFixture struct {
+	Ref string `json:"ref,omitempty"`
+	goshopify.Customer
+}
+
+// Fixtures are the resources to be seeded. Orders are kept as JSON with
+// unresolved refs, see ResolveRefs, and an optional ref defaulting to the
+// order name.
+type Fixtures struct {
+	Products  []ProductFixture
+	Customers []CustomerFixture
+	Orders    []json.RawMessage
+}
+
+type SeedOptions struct {
+	DryRun bool
+}
+
+type SeedResult struct {
+	Kind   string `json:"kind"` // product, variant, customer or order
+	Ref    string `json:"ref"`
+	ID     int64  `json:"id,omitempty"`
+	Action string `json:"action"`
+}
+
+type SeedReport struct {
+	Results []SeedResult `json:"results"`
+}
+
+// refFields maps ref keys in order fixtures to the refs they are looked
+// up in and the ID field they are replaced by.
+var refFields = map[string]struct {
+	ids     func(*SeedRefs) map[string]int64
+	idField string
+}{
+	"variant_ref":  {func(r *SeedRefs) map[string]int64 { return r.Variants }, "variant_id"},
+	"product_ref":  {func(r *SeedRefs) map[string]int64 { return r.Products }, "product_id"},
+	"location_ref": {func(r *SeedRefs) map[string]int64 { return r.Locations }, "location_id"},
+	"customer_ref": {func(r *SeedRefs) map[string]int64 { return r.Customers }, "customer"},
+	"order_ref":    {func(r *SeedRefs) map[string]int64 { return r.Orders }, "order_id"},
+}
+
+// ReadLockfile reads the lockfile at path. A missing lockfile is empty.
+func ReadLockfile(path string) (*Lockfile, error) {
+	l := &Lockfile{Stores: map[string]*SeedRefs{}}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, l); err != nil {
+		return nil, fmt.Errorf("lockfile %s: %w", path, err)
+	}
+	if l.Stores == nil {
+		l.Stores = map[string]*SeedRefs{}
+	}
+	return l, nil
+}
+
+func (l *Lockfile) Write(path string) error {
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// Refs returns the refs of store, adding them if missing.
+func (l *Lockfile) Refs(store string) *SeedRefs {
+	refs := l.Stores[store]
+	if refs == nil {
+		refs = &SeedRefs{}
+		l.Stores[store] = refs
+	}
+	refs.init()
+	return refs
+}
+
+func (r *SeedRefs) init() {
+	for _, m := range []*map[string]int64{&r.Locations, &r.Products, &r.Variants, &r.Customers, &r.Orders} {
+		if *m == nil {
+			*m = map[string]int64{}
+		}
+	}
+}
+
+// LoadFixtures reads products.json, customers.json and orders.json, each
+// optionally as JSON lines with extension .jsonl, and orders/*.json from
+// dir.
+func LoadFixtures(dir string) (*Fixtures, error) {
+	fixtures := &Fixtures{}
+	var err error
+	if fixtures.Products, err = readFixtureFile[ProductFixture](dir, "products"); err != nil {
+		return nil, err
+	}
+	if fixtures.Customers, err = readFixtureFile[CustomerFixture](dir, "customers"); err != nil {
+		return nil, err
+	}
+	if fixtures.Orders, err = readFixtureFile[json.RawMessage](dir, "orders"); err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "orders", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		fixtures.Orders = append(fixtures.Orders, b)
+	}
+	return fixtures, nil
+}
+
+func readFixtureFile[T any](dir, name string) ([]T, error) {
+	var records []T
+	for _, ext := range []string{".json", ".jsonl"} {
+		f, err := os.Open(filepath.Join(dir, name+ext))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		r, err := readJSONRecords[T](f, name)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s%s: %w", name, ext, err)
+		}
+		records = append(records, r...)
+	}
+	return records, nil
+}
+
+// Seed creates the fixtures missing in the store and records their IDs in
+// refs. Resources are looked up by the ID recorded for their ref, then by
+// product handle, customer email or phone and order name, so that seeding
+// can be repeated. Variant inventory is set to the fixture quantities on
+// every run. With DryRun nothing is written and refs of resources that
+// would be created are recorded with ID 0.
+func Seed(client Client, fixtures *Fixtures, refs *SeedRefs, opts SeedOptions) (*SeedReport, error) {
+	return SeedContext(client.Context(), staticClient(client), fixtures, refs, opts)
+}
+
+// SeedContext is like Seed but stops before the next fixture once ctx is
+// done, returning the report so far. refs holds the fixtures seeded so
+// far. Each fixture is seeded with a client created by newClient, see
+// RunOperation.
+func SeedContext(ctx context.Context, newClient ClientFunc, fixtures *Fixtures, refs *SeedRefs, opts SeedOptions) (*SeedReport, error) {
+	refs.init()
+	s := &seeder{refs: refs, dryRun: opts.DryRun, report: &SeedReport{}}
+	for _, f := range fixtures.Products {
+		if err := RunOperation(ctx, newClient, s.run(func() error { return s.product(f) })); err != nil {
+			return s.report, err
+		}
+	}
+	for _, f := range fixtures.Customers {
+		if err := RunOperation(ctx, newClient, s.run(func() error { return s.customer(f) })); err != nil {
+			return s.report, err
+		}
+	}
+	for _, raw := range fixtures.Orders {
+		if err := RunOperation(ctx, newClient, s.run(func() error { return s.order(raw) })); err != nil {
+			return s.report, err
+		}
+	}
+	return s.report, nil
+}
+
+type seeder struct {
+	client    Client
+	api       Client
+	refs      *SeedRefs
+	dryRun    bool
+	report    *SeedReport
+	locations map[string]int64 // by name
+}
+
+// run returns an operation running fn with client.
+func (s *seeder) run(fn func() error) func(client Client) error {
+	return func(client Client) error {
+		s.client = client
+		return fn()
+	}
+}
+
+func (s *seeder) add(kind, ref string, id int64, action string) {
+	s.report.Results = append(s.report.Results, SeedResult{Kind: kind, Ref: ref, ID: id, Action: action})
+}
+
+func (s *seeder) product(f ProductFixture) error {
+	if f.Handle == "" {
+		f.Handle = ProductHandle(f.Title)
+	}
+	ref := f.Ref
+	if ref == "" {
+		ref = f.Handle
+	}
+	var product *goshopify.Product
+	action := SeedLocked
+	if id := s.refs.Products[ref]; id != 0 {
+		p, err := s.client.GetProduct(id)
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("product %q: %w", ref, err)
+		}
+		product = p
+	}
+	if product == nil {
+		action = SeedFound
+		existing, err := s.client.ListProducts(goshopify.ProductListOptions{Handle: f.Handle})
+		if err != nil {
+			return fmt.Errorf("product %q: %w", ref, err)
+		}
+		if len(existing) != 0 {
+			product = &existing[0]
+		}
+	}
+	if product == nil {
+		action = SeedCreated
+		p := f.Product
+		p.Variants = make([]goshopify.Variant, len(f.Variants))
+		for i, v := range f.Variants {
+			p.Variants[i] = seedVariant(v)
+		}
+		p.Options = productOptions(p)
+		if s.dryRun {
+			product = &goshopify.Product{Variants: p.Variants}
+		} else {
+			created, err := s.client.CreateProduct(p)
+			if err != nil {
+				return fmt.Errorf("product %q: %w", ref, err)
+			}
+			product = created
+		}
+	}
+	s.refs.Products[ref] = product.ID
+	s.add("product", ref, product.ID, action)
+	for i, vf := range f.Variants {
+		if err := s.variant(ref, product, action == SeedCreated, i, vf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// variant records the ref of the i-th variant fixture of product, adding
+// the variant to an existing product if missing, and sets its inventory.
+// Variants of created products are reported as created.
+func (s *seeder) variant(productRef string, product *goshopify.Product, created bool, i int, f VariantFixture) error {
+	ref := f.Ref
+	if ref == "" {
+		ref = f.Sku
+	}
+	var variant *goshopify.Variant
+	for j := range product.Variants {
+		v := &product.Variants[j]
+		if (f.Sku != "" && v.Sku == f.Sku) || (f.Sku == "" && j == i) {
+			variant = v
+			break
+		}
+	}
+	action := SeedFound
+	if created {
+		action = SeedCreated
+	}
+	if variant == nil {
+		action = SeedCreated
+		variant = &goshopify.Variant{}
+		if !s.dryRun {
+			created, err := s.client.CreateVariant(product.ID, seedVariant(f))
+			if err != nil {
+				return fmt.Errorf("product %q: variant %q: %w", productRef, ref, err)
+			}
+			variant = created
+		}
+	}
+	if ref != "" {
+		s.refs.Variants[ref] = variant.ID
+		s.add("variant", ref, variant.ID, action)
+	}
+	for _, name := range sortedNames(f.Inventory) {
+		locationID, err := s.location(name)
+		if err != nil {
+			return fmt.Errorf("product %q: variant %q: %w", productRef, ref, err)
+		}
+		if s.dryRun {
+			continue
+		}
+		if _, err := SetInventoryLevel(s.client, locationID, variant.InventoryItemId, f.Inventory[name]); err != nil {
+			return fmt.Errorf("product %q: variant %q: %w", productRef, ref, err)
+		}
+	}
+	return nil
+}
+
+// seedVariant returns the variant to be created for f, tracked by Shopify
+// if f has inventory.
+func seedVariant(f VariantFixture) goshopify.Variant {
+	v := f.Variant
+	if len(f.Inventory) != 0 && v.InventoryManagement == "" {
+		v.InventoryManagement = "shopify"
+	}
+	return v
+}
+
+// location returns the ID of the active location with the given name.
+func (s *seeder) location(name string) (int64, error) {
+	if s.locations == nil {
+		locations, err := s.client.ListLocations()
+		if err != nil {
+			return 0, err
+		}
+		s.locations = map[string]int64{}
+		for _, l := range locations {
+			if l.Active {
+				s.locations[l.Name] = l.ID
+			}
+		}
+	}
+	id, ok := s.locations[name]
+	if !ok {
+		return 0, fmt.Errorf("no active location named %q", name)
+	}
+	s.refs.Locations[name] = id
+	return id, nil
+}
+
+func (s *seeder) customer(f CustomerFixture) error {
+	ref := f.Ref
+	if ref == "" {
+		ref = f.Email
+	}
+	if ref == "" {
+		return fmt.Errorf("customer fixture without ref and email")
+	}
+	if id := s.refs.Customers[ref]; id != 0 {
+		c, err := s.client.GetCustomer(id)
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("customer %q: %w", ref, err)
+		}
+		if c != nil {
+			s.add("customer", ref, c.ID, SeedLocked)
+			return nil
+		}
+	}
+	if f.Email != "" || f.Phone != "" {
+		customers, err := FindCustomers(s.client, f.Email, f.Phone)
+		if err != nil {
+			return fmt.Errorf("customer %q: %w", ref, err)
+		}
+		if len(customers) > 1 {
+			return fmt.Errorf("customer %q: %d customers found for email %q, phone %q", ref, len(customers), f.Email, f.Phone)
+		}
+		if len(customers) == 1 {
+			s.refs.Customers[ref] = customers[0].ID
+			s.add("customer", ref, customers[0].ID, SeedFound)
+			return nil
+		}
+	}
+	var id int64
+	if !s.dryRun {
+		c, err := s.client.CreateCustomer(f.Customer)
+		if err != nil {
+			return fmt.Errorf("customer %q: %w", ref, err)
+		}
+		id = c.ID
+	}
+	s.refs.Customers[ref] = id
+	s.add("customer", ref, id, SeedCreated)
+	return nil
+}
+
+func (s *seeder) order(raw json.RawMessage) error {
+	fixture := struct {
+		Ref  string `json:"ref"`
+		Name string `json:"name"`
+	}{}
+	if err := json.Unmarshal(raw, &fixture); err != nil {
+		return fmt.Errorf("order fixture: %w", err)
+	}
+	ref := fixture.Ref
+	if ref == "" {
+		ref = fixture.Name
+	}
+	if ref == "" {
+		return fmt.Errorf("order fixture without ref and name")
+	}
+	if id := s.refs.Orders[ref]; id != 0 {
+		o, err := s.client.GetOrder(id)
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("order %q: %w", ref, err)
+		}
+		if o != nil {
+			s.add("order", ref, o.ID, SeedLocked)
+			return nil
+		}
+	}
+	if fixture.Name != "" {
+		orders, err := List(s.client, fixture.Name)
+		if err != nil {
+			return fmt.Errorf("order %q: %w", ref, err)
+		}
+		if len(orders) == 1 {
+			s.refs.Orders[ref] = orders[0].ID
+			s.add("order", ref, orders[0].ID, SeedFound)
+			return nil
+		}
+	}
+	resolved, err := ResolveRefs(raw, s.refs)
+	if err != nil {
+		return fmt.Errorf("order %q: %w", ref, err)
+	}
+	o := &goshopify.Order{}
+	if err := json.Unmarshal(resolved, o); err != nil {
+		return fmt.Errorf("order %q: %w", ref, err)
+	}
+	var id int64
+	if !s.dryRun {
+		created, err := Create(s.client, o, CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("order %q: %w", ref, err)
+		}
+		id = created.ID
+	}
+	s.refs.Orders[ref] = id
+	s.add("order", ref, id, SeedCreated)
+	return nil
+}
+
+// ResolveRefs replaces fixture refs in the JSON document data by the IDs
+// recorded in refs: variant_ref, product_ref, location_ref and order_ref
+// by variant_id, product_id, location_id and order_id, and customer_ref
+// by a customer object with ID.
+func ResolveRefs(data []byte, refs *SeedRefs) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	var missing []string
+	resolveRefs(v, refs, &missing)
+	if len(missing) != 0 {
+		return nil, fmt.Errorf("unknown fixture refs: %s", strings.Join(missing, ", "))
+	}
+	return json.Marshal(v)
+}
+
+func resolveRefs(v interface{}, refs *SeedRefs, missing *[]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedNames(v) {
+			f, ok := refFields[key]
+			if !ok {
+				resolveRefs(v[key], refs, missing)
+				continue
+			}
+			ref, _ := v[key].(string)
+			id, ok := f.ids(refs)[ref]
+			if !ok {
+				*missing = append(*missing, fmt.Sprintf("%s %q", key, ref))
+				continue
+			}
+			delete(v, key)
+			if f.idField == "customer" {
+				v[f.idField] = map[string]interface{}{"id": id}
+			} else {
+				v[f.idField] = id
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			resolveRefs(e, refs, missing)
+		}
+	}
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isNotFound(err error) bool {
+	var rerr goshopify.ResponseError
+	return errors.As(err, &rerr) && rerr.Status == http.StatusNotFound
+}
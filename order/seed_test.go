@@ -0,0 +1,76 @@
+package order
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeed(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	fixtures, err := LoadFixtures("../testdata/fixtures")
+	require.NoError(t, err)
+	require.Len(t, fixtures.Products, 1)
+	require.Len(t, fixtures.Products[0].Variants, 2)
+	require.Len(t, fixtures.Customers, 1)
+	require.Len(t, fixtures.Orders, 1)
+
+	refs := &SeedRefs{}
+	report, err := Seed(client, fixtures, refs, SeedOptions{DryRun: true})
+	require.NoError(t, err)
+	require.Len(t, report.Results, 5)
+	require.Empty(t, srv.Products())
+	require.Empty(t, srv.Orders())
+
+	lockfile := filepath.Join(t.TempDir(), "seed.lock.json")
+	l, err := ReadLockfile(lockfile)
+	require.NoError(t, err)
+	refs = l.Refs("eql-dev")
+	report, err = Seed(client, fixtures, refs, SeedOptions{})
+	require.NoError(t, err)
+	for _, r := range report.Results {
+		require.Equal(t, SeedCreated, r.Action, r.Ref)
+	}
+	products := srv.Products()
+	require.Len(t, products, 1)
+	variant := products[0].Variants[0]
+	require.Equal(t, variant.ID, refs.Variants["tshirt-red-m"])
+	require.Equal(t, 100, srv.Inventory(variant.InventoryItemId, srv.LocationID))
+	require.Equal(t, srv.LocationID, refs.Locations["Shop location"])
+	orders := srv.Orders()
+	require.Len(t, orders, 1)
+	require.Equal(t, variant.ID, orders[0].LineItems[0].VariantID)
+	require.Equal(t, refs.Customers["mary"], orders[0].Customer.ID)
+	require.NoError(t, l.Write(lockfile))
+
+	l, err = ReadLockfile(lockfile)
+	require.NoError(t, err)
+	refs = l.Refs("eql-dev")
+	report, err = Seed(client, fixtures, refs, SeedOptions{})
+	require.NoError(t, err)
+	require.Equal(t, SeedResult{Kind: "product", Ref: "tshirt", ID: products[0].ID, Action: SeedLocked}, report.Results[0])
+	require.Equal(t, SeedLocked, report.Results[4].Action)
+	require.Len(t, srv.Products(), 1)
+	require.Len(t, srv.Orders(), 1)
+
+	// another store without lockfile entries finds existing resources
+	report, err = Seed(client, fixtures, l.Refs("other"), SeedOptions{})
+	require.NoError(t, err)
+	for _, r := range report.Results {
+		require.Equal(t, SeedFound, r.Action, r.Ref)
+	}
+}
+
+func TestResolveRefs(t *testing.T) {
+	refs := &SeedRefs{Variants: map[string]int64{"tshirt-red-m": 43434424271066}, Customers: map[string]int64{"mary": 7}}
+	got, err := ResolveRefs([]byte(`{"name":"o1","customer_ref":"mary","line_items":[{"variant_ref":"tshirt-red-m","quantity":1}]}`), refs)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name":"o1","customer":{"id":7},"line_items":[{"variant_id":43434424271066,"quantity":1}]}`, string(got))
+
+	_, err = ResolveRefs([]byte(`{"line_items":[{"variant_ref":"nope"},{"product_ref":"x"}]}`), refs)
+	require.EqualError(t, err, `unknown fixture refs: variant_ref "nope", product_ref "x"`)
+}
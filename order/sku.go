@@ -0,0 +1,193 @@
+package order
+
+import (
+	"fmt"
+	"strings"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// SKUPolicy defines how line items with unresolvable or ambiguous SKUs
+// are handled.
+type SKUPolicy string
+
+const (
+	SKUPolicyFail   SKUPolicy = "fail"   // fail the order
+	SKUPolicySkip   SKUPolicy = "skip"   // drop the line item
+	SKUPolicyCustom SKUPolicy = "custom" // import as custom line item without variant
+)
+
+// skuBatchSize is the number of SKUs looked up in a single GraphQL request.
+const skuBatchSize = 50
+
+type SKUResolver struct {
+	gql    *GraphQL
+	Policy SKUPolicy
+	// cache maps SKUs to the IDs of all variants carrying that SKU.
+	cache map[string][]int64
+}
+
+// SKUError reports a line item whose SKU could not be resolved to
+// exactly one variant.
+type SKUError struct {
+	Line  int // zero based line item index
+	SKU   string
+	Count int // number of variants found for SKU
+}
+
+type SKUErrors []SKUError
+
+func NewSKUResolver(client Client, policy SKUPolicy) *SKUResolver {
+	return &SKUResolver{gql: NewGraphQL(client), Policy: policy, cache: map[string][]int64{}}
+}
+
+// WithClient returns a resolver sending its requests with client, sharing
+// the policy and the SKUs resolved so far with r.
+func (r *SKUResolver) WithClient(client Client) *SKUResolver {
+	return &SKUResolver{gql: NewGraphQL(client), Policy: r.Policy, cache: r.cache}
+}
+
+func (e SKUError) Error() string {
+	if e.Count == 0 {
+		return fmt.Sprintf("line item %d: no product variant found with sku %q", e.Line, e.SKU)
+	}
+	return fmt.Sprintf("line item %d: %d product variants found with sku %q", e.Line, e.Count, e.SKU)
+}
+
+func (e SKUErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Resolve sets the variant ID of all line items that have a SKU but no
+// variant ID. Line items that cannot be resolved are handled according
+// to the resolver's policy and returned as SKUErrors; the error is
+// non-nil only for lookup failures or with SKUPolicyFail.
+func (r *SKUResolver) Resolve(order *goshopify.Order) (SKUErrors, error) {
+	var skus []string
+	for _, lineItem := range order.LineItems {
+		if lineItem.VariantID == 0 && lineItem.SKU != "" {
+			skus = append(skus, lineItem.SKU)
+		}
+	}
+	if err := r.Lookup(skus); err != nil {
+		return nil, err
+	}
+	var skuErrs SKUErrors
+	lineItems := make([]goshopify.LineItem, 0, len(order.LineItems))
+	for i, lineItem := range order.LineItems {
+		if lineItem.VariantID != 0 || lineItem.SKU == "" {
+			lineItems = append(lineItems, lineItem)
+			continue
+		}
+		ids := r.cache[lineItem.SKU]
+		if len(ids) == 1 {
+			lineItem.VariantID = ids[0]
+			lineItems = append(lineItems, lineItem)
+			continue
+		}
+		skuErrs = append(skuErrs, SKUError{Line: i, SKU: lineItem.SKU, Count: len(ids)})
+		if r.Policy == SKUPolicyCustom {
+			if lineItem.Title == "" {
+				lineItem.Title = lineItem.SKU
+			}
+			lineItems = append(lineItems, lineItem)
+		}
+	}
+	if len(skuErrs) != 0 && r.Policy != SKUPolicySkip && r.Policy != SKUPolicyCustom {
+		return skuErrs, skuErrs
+	}
+	order.LineItems = lineItems
+	return skuErrs, nil
+}
+
+// Lookup queries the variant IDs for all given SKUs not yet cached,
+// batching multiple SKUs per GraphQL request.
+func (r *SKUResolver) Lookup(skus []string) error {
+	var missing []string
+	seen := map[string]bool{}
+	for _, sku := range skus {
+		if _, ok := r.cache[sku]; ok || seen[sku] {
+			continue
+		}
+		seen[sku] = true
+		missing = append(missing, sku)
+	}
+	for start := 0; start < len(missing); start += skuBatchSize {
+		end := start + skuBatchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		if err := r.lookupBatch(missing[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SKUResolver) lookupBatch(skus []string) error {
+	filters := make([]string, len(skus))
+	batch := make(map[string]bool, len(skus))
+	for i, sku := range skus {
+		filters[i] = "sku:" + quoteSearchValue(sku)
+		batch[sku] = true
+		r.cache[sku] = nil
+	}
+	query := `query($filter: String!, $first: Int!, $after: String) {
+  productVariants(first: $first, after: $after, query: $filter) {
+    edges { cursor node { id sku } }
+    pageInfo { hasNextPage }
+  }
+}`
+	vars := Vars{
+		"filter": strings.Join(filters, " OR "),
+		// allow for a few duplicate SKUs so that ambiguity can be detected
+		// in a single page
+		"first": 2 * len(skus),
+	}
+	// a SKU is only known to be missing after all pages have been read
+	for {
+		result := skuVariantsResult{}
+		if err := r.gql.Do(query, vars, &result); err != nil {
+			return err
+		}
+		for _, e := range result.ProductVariants.Edges {
+			vars["after"] = e.Cursor
+			if !batch[e.Node.SKU] {
+				continue // search matched a SKU we did not ask for
+			}
+			id, err := IDFromGID(e.Node.ID)
+			if err != nil {
+				return err
+			}
+			r.cache[e.Node.SKU] = append(r.cache[e.Node.SKU], id)
+		}
+		if !result.ProductVariants.PageInfo.HasNextPage {
+			return nil
+		}
+	}
+}
+
+type skuVariantsResult struct {
+	ProductVariants struct {
+		Edges []struct {
+			Cursor string
+			Node   struct {
+				ID  string
+				SKU string
+			}
+		}
+		PageInfo struct {
+			HasNextPage bool
+		}
+	}
+}
+
+func quoteSearchValue(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
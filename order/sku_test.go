@@ -0,0 +1,126 @@
+package order
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func skuHandler(t *testing.T, variants map[string][]int64, requests *int) http.HandlerFunc {
+	t.Helper()
+	return func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		req := struct {
+			Variables struct {
+				Filter string `json:"filter"`
+				First  int    `json:"first"`
+				After  string `json:"after"`
+			} `json:"variables"`
+		}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		var edges []string
+		for _, f := range strings.Split(req.Variables.Filter, " OR ") {
+			sku := strings.Trim(strings.TrimPrefix(f, "sku:"), `"`)
+			for _, id := range variants[sku] {
+				edges = append(edges, fmt.Sprintf(`{"cursor":"%d","node":{"id":"gid://shopify/ProductVariant/%d","sku":%q}}`, len(edges)+1, id, sku))
+			}
+		}
+		offset, _ := strconv.Atoi(req.Variables.After)
+		end := offset + req.Variables.First
+		if end > len(edges) {
+			end = len(edges)
+		}
+		fmt.Fprintf(w, `{"data":{"productVariants":{"edges":[%s],"pageInfo":{"hasNextPage":%t}}}}`, strings.Join(edges[offset:end], ","), end < len(edges))
+	}
+}
+
+func TestSKUResolverResolve(t *testing.T) {
+	variants := map[string][]int64{"TS-RED-M": {1}, "TS-RED-L": {2}, "DUP": {3, 4}}
+	requests := 0
+	client := testClient(t, skuHandler(t, variants, &requests))
+
+	newOrder := func() *goshopify.Order {
+		return &goshopify.Order{LineItems: []goshopify.LineItem{
+			{SKU: "TS-RED-M"},
+			{SKU: "TS-RED-L", VariantID: 9},
+			{SKU: "DUP"},
+			{SKU: "UNKNOWN", Title: "Mystery"},
+			{SKU: "TS-RED-L"},
+		}}
+	}
+
+	r := NewSKUResolver(client, SKUPolicyFail)
+	o := newOrder()
+	skuErrs, err := r.Resolve(o)
+	require.Error(t, err)
+	require.Equal(t, SKUErrors{{Line: 2, SKU: "DUP", Count: 2}, {Line: 3, SKU: "UNKNOWN"}}, skuErrs)
+	require.Equal(t, newOrder(), o)
+	require.Equal(t, 1, requests)
+
+	r.Policy = SKUPolicySkip
+	o = newOrder()
+	skuErrs, err = r.Resolve(o)
+	require.NoError(t, err)
+	require.Len(t, skuErrs, 2)
+	want := []goshopify.LineItem{
+		{SKU: "TS-RED-M", VariantID: 1},
+		{SKU: "TS-RED-L", VariantID: 9},
+		{SKU: "TS-RED-L", VariantID: 2},
+	}
+	require.Equal(t, want, o.LineItems)
+	require.Equal(t, 1, requests, "lookups should be cached")
+
+	r.Policy = SKUPolicyCustom
+	o = newOrder()
+	_, err = r.Resolve(o)
+	require.NoError(t, err)
+	require.Len(t, o.LineItems, 5)
+	require.Equal(t, goshopify.LineItem{SKU: "DUP", Title: "DUP"}, o.LineItems[2])
+	require.Equal(t, goshopify.LineItem{SKU: "UNKNOWN", Title: "Mystery"}, o.LineItems[3])
+
+	// a resolver for another client shares the lookups
+	otherRequests := 0
+	other := r.WithClient(testClient(t, skuHandler(t, variants, &otherRequests)))
+	o = newOrder()
+	_, err = other.Resolve(o)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), o.LineItems[0].VariantID)
+	require.Zero(t, otherRequests)
+	require.NoError(t, other.Lookup([]string{"NEW"}))
+	require.Equal(t, 1, otherRequests)
+	require.Contains(t, r.cache, "NEW")
+}
+
+func TestSKUResolverBatches(t *testing.T) {
+	variants := map[string][]int64{}
+	var skus []string
+	for i := 0; i < 2*skuBatchSize+1; i++ {
+		sku := fmt.Sprintf("SKU-%d", i)
+		variants[sku] = []int64{int64(i + 1)}
+		skus = append(skus, sku, sku)
+	}
+	requests := 0
+	r := NewSKUResolver(testClient(t, skuHandler(t, variants, &requests)), SKUPolicyFail)
+	require.NoError(t, r.Lookup(skus))
+	require.Equal(t, 3, requests)
+	require.Equal(t, []int64{int64(skuBatchSize + 1)}, r.cache[fmt.Sprintf("SKU-%d", skuBatchSize)])
+}
+
+func TestSKUResolverPaginates(t *testing.T) {
+	// more variants than fit on a single page, e.g. a SKU reused by many
+	// variants, must not leave the other SKUs of the batch unresolved
+	variants := map[string][]int64{"DUP": {1, 2, 3, 4, 5}, "A": {6}, "B": {7}}
+	requests := 0
+	r := NewSKUResolver(testClient(t, skuHandler(t, variants, &requests)), SKUPolicyFail)
+	require.NoError(t, r.Lookup([]string{"DUP", "A", "B"}))
+	require.Equal(t, 2, requests)
+	require.Equal(t, []int64{1, 2, 3, 4, 5}, r.cache["DUP"])
+	require.Equal(t, []int64{6}, r.cache["A"])
+	require.Equal(t, []int64{7}, r.cache["B"])
+}
@@ -0,0 +1,106 @@
+package order
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// StockShortfall reports an inventory item whose available quantity at a
+// location does not cover the line items of a batch of orders.
+type StockShortfall struct {
+	InventoryItemID int64    `json:"inventory_item_id"`
+	VariantID       int64    `json:"variant_id"`
+	LocationID      int64    `json:"location_id"`
+	Required        int      `json:"required"`
+	Available       int      `json:"available"`
+	Orders          []string `json:"orders"`
+}
+
+type StockShortfalls []StockShortfall
+
+// stockDemand is the quantity of an inventory item required by a batch
+// of orders.
+type stockDemand struct {
+	variantID int64
+	level     *InventoryLevel
+	required  int
+	orders    []string
+}
+
+func (s StockShortfall) Error() string {
+	return fmt.Sprintf("variant %d (inventory item %d) at location %d: %d required by %s, %d available", s.VariantID, s.InventoryItemID, s.LocationID, s.Required, strings.Join(s.Orders, ", "), s.Available)
+}
+
+func (s StockShortfalls) Error() string {
+	msgs := make([]string, len(s))
+	for i, shortfall := range s {
+		msgs[i] = shortfall.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// CheckStock adds up the quantities required by the line items of all
+// orders per inventory item and location and compares them with the
+// current inventory levels, so that a batch of orders drawing on the same
+// variant can be checked before any of them is created. Line items
+// without variant ID are ignored. As for CreateOptions.Inventory, each
+// variant must be stocked at a single location.
+func CheckStock(client Client, orders []goshopify.Order) (StockShortfalls, error) {
+	demands := map[int64]*stockDemand{}
+	for i, o := range orders {
+		name := o.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		for _, lineItem := range o.LineItems {
+			if lineItem.VariantID == 0 {
+				continue
+			}
+			d := demands[lineItem.VariantID]
+			if d == nil {
+				itemID, err := InventoryItemID(client, 0, lineItem.VariantID)
+				if err != nil {
+					return nil, fmt.Errorf("order %q: variant %d: %w", name, lineItem.VariantID, err)
+				}
+				levels, err := GetIventoryLevels(client, itemID, 0)
+				if err != nil {
+					return nil, fmt.Errorf("order %q: variant %d: %w", name, lineItem.VariantID, err)
+				}
+				if len(levels) > 1 {
+					return nil, fmt.Errorf("order %q: variant %d stocked at %d locations", name, lineItem.VariantID, len(levels))
+				}
+				d = &stockDemand{variantID: lineItem.VariantID, level: &InventoryLevel{InventoryItemID: itemID}}
+				if len(levels) == 1 {
+					d.level = levels[0]
+				}
+				demands[lineItem.VariantID] = d
+			}
+			quantity := lineItem.Quantity
+			if quantity < 1 {
+				quantity = 1
+			}
+			d.required += quantity
+			if len(d.orders) == 0 || d.orders[len(d.orders)-1] != name {
+				d.orders = append(d.orders, name)
+			}
+		}
+	}
+	var shortfalls StockShortfalls
+	for _, d := range demands {
+		if d.required > d.level.Available {
+			shortfalls = append(shortfalls, StockShortfall{
+				InventoryItemID: d.level.InventoryItemID,
+				VariantID:       d.variantID,
+				LocationID:      d.level.LocationID,
+				Required:        d.required,
+				Available:       d.level.Available,
+				Orders:          d.orders,
+			})
+		}
+	}
+	sort.Slice(shortfalls, func(i, j int) bool { return shortfalls[i].VariantID < shortfalls[j].VariantID })
+	return shortfalls, nil
+}
@@ -0,0 +1,51 @@
+package order
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckStock(t *testing.T) {
+	srv := fake.NewServer()
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+	srv.AddProducts(goshopify.Product{ID: 1, Title: "Tee", Variants: []goshopify.Variant{
+		{ID: 2, ProductID: 1, InventoryItemId: 3, Sku: "TEE-M"},
+		{ID: 4, ProductID: 1, InventoryItemId: 5, Sku: "TEE-L"},
+		{ID: 6, ProductID: 1, InventoryItemId: 7, Sku: "TEE-XL"},
+	}})
+	_, err := SetInventoryLevel(client, 1, 3, 2)
+	require.NoError(t, err)
+	_, err = SetInventoryLevel(client, 1, 5, 5)
+	require.NoError(t, err)
+
+	orders, err := ReadOrders(strings.NewReader(`
+{"name": "#1001", "line_items": [{"variant_id": 2, "quantity": 1}, {"variant_id": 4, "quantity": 2}]}
+{"name": "#1002", "line_items": [{"variant_id": 2, "quantity": 1}, {"title": "Gift wrap", "quantity": 1}]}
+`), "jsonl")
+	require.NoError(t, err)
+	shortfalls, err := CheckStock(client, orders)
+	require.NoError(t, err)
+	require.Empty(t, shortfalls)
+
+	orders = append(orders,
+		goshopify.Order{Name: "#1003", LineItems: []goshopify.LineItem{{VariantID: 2, Quantity: 1}, {VariantID: 2, Quantity: 1}}},
+		goshopify.Order{Name: "#1004", LineItems: []goshopify.LineItem{{VariantID: 6, Quantity: 1}}},
+	)
+	shortfalls, err = CheckStock(client, orders)
+	require.NoError(t, err)
+	require.Equal(t, StockShortfalls{
+		{InventoryItemID: 3, VariantID: 2, LocationID: 1, Required: 4, Available: 2, Orders: []string{"#1001", "#1002", "#1003"}},
+		{InventoryItemID: 7, VariantID: 6, Required: 1, Orders: []string{"#1004"}},
+	}, shortfalls)
+	require.EqualError(t, shortfalls[:1], "variant 2 (inventory item 3) at location 1: 4 required by #1001, #1002, #1003, 2 available")
+
+	_, err = SetInventoryLevel(client, 2, 5, 1)
+	require.NoError(t, err)
+	_, err = CheckStock(client, orders)
+	require.ErrorContains(t, err, `order "#1001": variant 4 stocked at 2 locations`)
+}
@@ -0,0 +1,138 @@
+package order
+
+import (
+	"fmt"
+	"strings"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/shopspring/decimal"
+)
+
+// TransactionSet holds transactions to be imported keyed by order name.
+type TransactionSet map[string][]goshopify.Transaction
+
+type TransactionErrors []string
+
+// transactionTotals sums up successful transaction amounts by kind.
+type transactionTotals struct {
+	authorized decimal.Decimal
+	captured   decimal.Decimal // sales and captures
+	refunded   decimal.Decimal
+	voided     decimal.Decimal
+}
+
+var transactionKinds = map[string]bool{"authorization": true, "capture": true, "sale": true, "void": true, "refund": true}
+
+var transactionStatuses = map[string]bool{"": true, "success": true, "pending": true, "failure": true, "error": true}
+
+func (e TransactionErrors) Error() string {
+	return "invalid transactions: " + strings.Join(e, "; ")
+}
+
+// Apply sets the transactions of order to the transactions with the
+// order's name, if there are any.
+func (s TransactionSet) Apply(order *goshopify.Order) {
+	if transactions, ok := s[order.Name]; ok {
+		order.Transactions = transactions
+	}
+}
+
+// ValidateTransactions checks that the order's transactions are
+// well-formed and that their amounts reconcile with the order's total
+// price and financial status. Orders without transactions or without
+// total price are not checked.
+func ValidateTransactions(order *goshopify.Order) error {
+	if len(order.Transactions) == 0 {
+		return nil
+	}
+	var errs TransactionErrors
+	totals := transactionTotals{}
+	for i, t := range order.Transactions {
+		if !transactionKinds[t.Kind] {
+			errs = append(errs, fmt.Sprintf("transaction %d: invalid kind %q", i, t.Kind))
+		}
+		if !transactionStatuses[t.Status] {
+			errs = append(errs, fmt.Sprintf("transaction %d: invalid status %q", i, t.Status))
+		}
+		if t.Amount == nil || !t.Amount.IsPositive() {
+			if t.Kind != "void" {
+				errs = append(errs, fmt.Sprintf("transaction %d: amount must be positive", i))
+			}
+			continue
+		}
+		if t.Status != "" && t.Status != "success" {
+			continue
+		}
+		switch t.Kind {
+		case "authorization":
+			totals.authorized = totals.authorized.Add(*t.Amount)
+		case "capture", "sale":
+			totals.captured = totals.captured.Add(*t.Amount)
+		case "refund":
+			totals.refunded = totals.refunded.Add(*t.Amount)
+		case "void":
+			totals.voided = totals.voided.Add(*t.Amount)
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	if order.TotalPrice == nil {
+		return nil
+	}
+	return validateTotals(order.FinancialStatus, *order.TotalPrice, totals)
+}
+
+func validateTotals(financialStatus string, total decimal.Decimal, t transactionTotals) error {
+	var errs TransactionErrors
+	if t.refunded.GreaterThan(t.captured) {
+		errs = append(errs, fmt.Sprintf("refunded %s exceeds paid %s", t.refunded, t.captured))
+	}
+	if t.captured.GreaterThan(total) {
+		errs = append(errs, fmt.Sprintf("paid %s exceeds total price %s", t.captured, total))
+	}
+	if t.authorized.GreaterThan(total) {
+		errs = append(errs, fmt.Sprintf("authorized %s exceeds total price %s", t.authorized, total))
+	}
+	// without financial status Shopify derives it from the transactions
+	switch financialStatus {
+	case "paid":
+		if !t.captured.Equal(total) {
+			errs = append(errs, fmt.Sprintf("paid %s does not match total price %s", t.captured, total))
+		}
+	case "partially_paid":
+		if !t.captured.IsPositive() || !t.captured.LessThan(total) {
+			errs = append(errs, fmt.Sprintf("paid %s is not a partial payment of total price %s", t.captured, total))
+		}
+	case "authorized":
+		if !t.authorized.Sub(t.voided).Equal(total) || !t.captured.IsZero() {
+			errs = append(errs, fmt.Sprintf("authorized %s, paid %s do not match authorized total price %s", t.authorized, t.captured, total))
+		}
+	case "refunded":
+		if !t.refunded.Equal(t.captured) || !t.captured.IsPositive() {
+			errs = append(errs, fmt.Sprintf("refunded %s does not match paid %s", t.refunded, t.captured))
+		}
+	case "partially_refunded":
+		if !t.refunded.IsPositive() || !t.refunded.LessThan(t.captured) {
+			errs = append(errs, fmt.Sprintf("refunded %s is not a partial refund of paid %s", t.refunded, t.captured))
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// AddTransactions posts transactions to an existing order, e.g. to
+// record a later payment of a partially paid order.
+func AddTransactions(client Client, orderID int64, transactions []goshopify.Transaction) ([]goshopify.Transaction, error) {
+	result := make([]goshopify.Transaction, 0, len(transactions))
+	for i, t := range transactions {
+		created, err := client.CreateTransaction(orderID, t)
+		if err != nil {
+			return result, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		result = append(result, *created)
+	}
+	return result, nil
+}
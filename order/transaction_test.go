@@ -0,0 +1,84 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/OfficiallyEQL/orderer/fake"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func tx(kind, amount string) goshopify.Transaction {
+	d := decimal.RequireFromString(amount)
+	return goshopify.Transaction{Kind: kind, Status: "success", Amount: &d, Gateway: "manual"}
+}
+
+func TestValidateTransactions(t *testing.T) {
+	total := decimal.RequireFromString("149.95")
+	tests := map[string]struct {
+		status       string
+		transactions []goshopify.Transaction
+		valid        bool
+	}{
+		"no transactions":       {status: "paid", valid: true},
+		"paid":                  {status: "paid", transactions: []goshopify.Transaction{tx("sale", "149.95")}, valid: true},
+		"multiple tenders":      {status: "paid", transactions: []goshopify.Transaction{tx("sale", "100"), tx("sale", "49.95")}, valid: true},
+		"authorize and capture": {status: "paid", transactions: []goshopify.Transaction{tx("authorization", "149.95"), tx("capture", "149.95")}, valid: true},
+		"underpaid":             {status: "paid", transactions: []goshopify.Transaction{tx("sale", "100")}},
+		"overpaid":              {transactions: []goshopify.Transaction{tx("sale", "200")}},
+		"partially paid":        {status: "partially_paid", transactions: []goshopify.Transaction{tx("sale", "100")}, valid: true},
+		"not partially paid":    {status: "partially_paid", transactions: []goshopify.Transaction{tx("sale", "149.95")}},
+		"authorized":            {status: "authorized", transactions: []goshopify.Transaction{tx("authorization", "149.95")}, valid: true},
+		"partially refunded":    {status: "partially_refunded", transactions: []goshopify.Transaction{tx("sale", "149.95"), tx("refund", "20")}, valid: true},
+		"refunded":              {status: "refunded", transactions: []goshopify.Transaction{tx("sale", "149.95"), tx("refund", "149.95")}, valid: true},
+		"over refunded":         {status: "refunded", transactions: []goshopify.Transaction{tx("sale", "149.95"), tx("refund", "150")}},
+		"invalid kind":          {transactions: []goshopify.Transaction{tx("gift", "149.95")}},
+		"failed ignored":        {status: "paid", transactions: []goshopify.Transaction{{Kind: "sale", Status: "failure", Amount: &total}, tx("sale", "149.95")}, valid: true},
+		"missing amount":        {transactions: []goshopify.Transaction{{Kind: "sale"}}},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			o := &goshopify.Order{TotalPrice: &total, FinancialStatus: tc.status, Transactions: tc.transactions}
+			err := ValidateTransactions(o)
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestTransactionSetApply(t *testing.T) {
+	set := TransactionSet{"order1": {tx("sale", "1")}}
+	o := &goshopify.Order{Name: "order1", Transactions: []goshopify.Transaction{tx("sale", "2")}}
+	set.Apply(o)
+	require.Equal(t, set["order1"], o.Transactions)
+	other := &goshopify.Order{Name: "order2", Transactions: []goshopify.Transaction{tx("sale", "2")}}
+	set.Apply(other)
+	require.Equal(t, []goshopify.Transaction{tx("sale", "2")}, other.Transactions)
+	TransactionSet(nil).Apply(o)
+	require.Equal(t, set["order1"], o.Transactions)
+}
+
+func TestAddTransactions(t *testing.T) {
+	srv := fake.NewServer(goshopify.Order{ID: 1, Name: "order1", Transactions: []goshopify.Transaction{tx("sale", "10")}})
+	defer srv.Close()
+	client := NewShopifyClient(srv.Client())
+
+	created, err := AddTransactions(client, 1, []goshopify.Transaction{tx("sale", "5"), tx("refund", "2")})
+	require.NoError(t, err)
+	require.Len(t, created, 2)
+	require.NotZero(t, created[0].ID)
+	require.Equal(t, int64(1), created[1].OrderID)
+	transactions, err := Transactions(client, 1)
+	require.NoError(t, err)
+	require.Len(t, transactions, 3)
+
+	created, err = AddTransactions(client, 2, []goshopify.Transaction{tx("sale", "5")})
+	require.ErrorContains(t, err, "transaction 0: ")
+	require.Empty(t, created)
+}
@@ -0,0 +1,277 @@
+package order
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// Watch folder subdirectories files are moved to after import.
+const (
+	WatchProcessedDir = "processed"
+	WatchFailedDir    = "failed"
+)
+
+// watchFormats maps the extensions of imported files to their format.
+var watchFormats = map[string]string{".json": "json", ".jsonl": "jsonl", ".csv": "csv"}
+
+// FolderWatcher imports order files dropped into Dir. Each file is moved
+// to the processed or failed subdirectory after import, together with a
+// sidecar <file>.result.json holding its WatchReport.
+//
+// While a file is imported, the orders imported so far are recorded in a
+// hidden state file next to it, so that a file interrupted by a restart
+// continues with the first order not yet imported instead of importing
+// all its orders again.
+type FolderWatcher struct {
+	Dir string
+	// Import imports a single order, e.g. with Merge.
+	Import func(o *goshopify.Order) (*MergeResult, error)
+	// Check, if set, is called with the orders of a file not imported
+	// yet before any of them is imported, e.g. to check their stock with
+	// CheckStock. If it fails, the file is moved to the failed
+	// subdirectory without importing them.
+	Check func(orders []goshopify.Order) error
+	// MinAge is the time since the last modification before a file is
+	// imported, so that files still being uploaded are skipped.
+	MinAge time.Duration
+	now    func() time.Time
+}
+
+// WatchReport is the result of importing an order file. Status is
+// processed if all orders have been imported and failed otherwise.
+type WatchReport struct {
+	File        string             `json:"file"`
+	SHA256      string             `json:"sha256"`
+	Status      string             `json:"status"`
+	Error       string             `json:"error,omitempty"`
+	Orders      []WatchOrderResult `json:"orders"`
+	ProcessedAt time.Time          `json:"processed_at"`
+	// Path is the path the file has been moved to.
+	Path string `json:"-"`
+}
+
+// WatchOrderResult is the import result of the order at Index in a file.
+// Label is created or updated as for MergeResult, or resumed if the order
+// had been imported before a restart.
+type WatchOrderResult struct {
+	Index   int    `json:"index"`
+	Name    string `json:"name"`
+	Label   string `json:"label,omitempty"`
+	OrderID int64  `json:"order_id,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// watchState is a line of the state file of a file being imported.
+type watchState struct {
+	SHA256 string           `json:"sha256"`
+	Result WatchOrderResult `json:"result"`
+}
+
+// NewFolderWatcher returns a watcher for dir, creating its processed and
+// failed subdirectories.
+func NewFolderWatcher(dir string, importOrder func(*goshopify.Order) (*MergeResult, error)) (*FolderWatcher, error) {
+	for _, sub := range []string{WatchProcessedDir, WatchFailedDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &FolderWatcher{Dir: dir, Import: importOrder, now: time.Now}, nil
+}
+
+// Poll imports all order files in Dir, ordered by name, and returns their
+// reports. Hidden files and files with extensions other than .json,
+// .jsonl and .csv are ignored.
+func (w *FolderWatcher) Poll() ([]WatchReport, error) {
+	return w.PollContext(context.Background())
+}
+
+// PollContext is like Poll but stops before the next order once ctx is
+// done. The file in progress is left in Dir and continued with the next
+// order by a later poll.
+func (w *FolderWatcher) PollContext(ctx context.Context) ([]WatchReport, error) {
+	entries, err := os.ReadDir(w.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var reports []WatchReport
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return reports, err
+		}
+		name := e.Name()
+		if !e.Type().IsRegular() || strings.HasPrefix(name, ".") || watchFormats[strings.ToLower(filepath.Ext(name))] == "" {
+			continue
+		}
+		info, err := e.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return reports, err
+		}
+		if w.now().Sub(info.ModTime()) < w.MinAge {
+			continue
+		}
+		report, err := w.importFile(ctx, name)
+		if err != nil {
+			return reports, fmt.Errorf("%s: %w", name, err)
+		}
+		reports = append(reports, *report)
+	}
+	return reports, nil
+}
+
+// importFile imports the orders of the file name and moves it. Errors of
+// the file's content or its orders are recorded in the report, only
+// file system errors and ctx.Err() are returned.
+func (w *FolderWatcher) importFile(ctx context.Context, name string) (*WatchReport, error) {
+	path := filepath.Join(w.Dir, name)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	report := &WatchReport{File: name, SHA256: hex.EncodeToString(sum[:]), Status: WatchProcessedDir}
+	statePath := filepath.Join(w.Dir, "."+name+".state.jsonl")
+	done, err := readWatchState(statePath, report.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := ReadOrders(bytes.NewReader(b), watchFormats[strings.ToLower(filepath.Ext(name))])
+	if err != nil {
+		report.Status = WatchFailedDir
+		report.Error = err.Error()
+	}
+	if err == nil && w.Check != nil {
+		var pending []goshopify.Order
+		for i, o := range orders {
+			if _, ok := done[i]; !ok {
+				pending = append(pending, o)
+			}
+		}
+		if err := w.Check(pending); err != nil {
+			report.Status = WatchFailedDir
+			report.Error = err.Error()
+			orders = nil
+		}
+	}
+	state, err := os.OpenFile(statePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer state.Close()
+	enc := json.NewEncoder(state)
+	for i := range orders {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if result, ok := done[i]; ok {
+			result.Label = "resumed"
+			report.Orders = append(report.Orders, result)
+			continue
+		}
+		result := w.importOrder(i, &orders[i])
+		report.Orders = append(report.Orders, result)
+		if result.Error != "" {
+			report.Status = WatchFailedDir
+			continue
+		}
+		if err := enc.Encode(watchState{SHA256: report.SHA256, Result: result}); err != nil {
+			return nil, err
+		}
+		if err := state.Sync(); err != nil {
+			return nil, err
+		}
+	}
+	report.ProcessedAt = w.now().UTC()
+	if report.Path, err = w.move(name, report.Status); err != nil {
+		return nil, err
+	}
+	result, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(report.Path+".result.json", append(result, '\n'), 0o644); err != nil {
+		return nil, err
+	}
+	if err := state.Close(); err != nil {
+		return nil, err
+	}
+	return report, os.Remove(statePath)
+}
+
+func (w *FolderWatcher) importOrder(i int, o *goshopify.Order) WatchOrderResult {
+	result := WatchOrderResult{Index: i, Name: o.Name}
+	merged, err := w.Import(o)
+	if merged != nil {
+		// also recorded for orders created or updated only partially
+		result.Label = merged.Label
+		result.OrderID = merged.OrderID
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// move moves the file name to the subdirectory dir, adding a timestamp to
+// its name if a file with the same name has been moved there before.
+func (w *FolderWatcher) move(name, dir string) (string, error) {
+	dest := filepath.Join(w.Dir, dir, name)
+	if _, err := os.Stat(dest); err == nil {
+		ext := filepath.Ext(name)
+		dest = filepath.Join(w.Dir, dir, strings.TrimSuffix(name, ext)+"-"+w.now().UTC().Format("20060102T150405")+ext)
+	}
+	return dest, os.Rename(filepath.Join(w.Dir, name), dest)
+}
+
+// readWatchState returns the results of the orders imported from the file
+// with the given SHA256 checksum by order index. State of a file with
+// different content is ignored.
+func readWatchState(path, sha string) (map[int]WatchOrderResult, error) {
+	done := map[int]WatchOrderResult{}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		state := watchState{}
+		if err := json.Unmarshal(scanner.Bytes(), &state); err != nil {
+			// The last line may be incomplete after a crash.
+			break
+		}
+		if state.SHA256 == sha {
+			done[state.Result.Index] = state.Result
+		}
+	}
+	return done, scanner.Err()
+}
+
+// Failed returns the number of orders that could not be imported.
+func (r WatchReport) Failed() int {
+	n := 0
+	for _, o := range r.Orders {
+		if o.Error != "" {
+			n++
+		}
+	}
+	return n
+}
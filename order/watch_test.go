@@ -0,0 +1,168 @@
+package order
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFolderWatcher(t *testing.T) {
+	dir := t.TempDir()
+	var imported []string
+	w, err := NewFolderWatcher(dir, func(o *goshopify.Order) (*MergeResult, error) {
+		if o.Name == "#bad" {
+			return nil, fmt.Errorf("invalid order")
+		}
+		if o.Name == "#partial" {
+			return &MergeResult{Label: "created", OrderID: 99}, fmt.Errorf("order 99 created but not fulfilled")
+		}
+		imported = append(imported, o.Name)
+		return &MergeResult{Label: "created", OrderID: int64(len(imported))}, nil
+	})
+	require.NoError(t, err)
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	write("a.jsonl", `{"name": "#1"}`+"\n"+`{"name": "#2"}`)
+	write("b.csv", "name,sku\n#3,A\n#bad,B\n#partial,C\n")
+	write("c.json", "{")
+	write("notes.txt", "ignored")
+	write(".hidden.json", `{"name": "#4"}`)
+
+	reports, err := w.Poll()
+	require.NoError(t, err)
+	require.Len(t, reports, 3)
+	require.Equal(t, []string{"#1", "#2", "#3"}, imported)
+	require.Equal(t, "processed", reports[0].Status)
+	require.Equal(t, []WatchOrderResult{{Index: 0, Name: "#1", Label: "created", OrderID: 1}, {Index: 1, Name: "#2", Label: "created", OrderID: 2}}, reports[0].Orders)
+	require.Equal(t, "failed", reports[1].Status)
+	require.Equal(t, 2, reports[1].Failed())
+	require.Equal(t, "invalid order", reports[1].Orders[1].Error)
+	require.Equal(t, WatchOrderResult{Index: 2, Name: "#partial", Label: "created", OrderID: 99, Error: "order 99 created but not fulfilled"}, reports[1].Orders[2])
+	require.Equal(t, "failed", reports[2].Status)
+	require.NotEmpty(t, reports[2].Error)
+
+	require.FileExists(t, filepath.Join(dir, "processed", "a.jsonl"))
+	require.FileExists(t, filepath.Join(dir, "failed", "b.csv"))
+	require.FileExists(t, filepath.Join(dir, "failed", "c.json"))
+	require.FileExists(t, filepath.Join(dir, "notes.txt"))
+	require.FileExists(t, filepath.Join(dir, ".hidden.json"))
+	b, err := os.ReadFile(filepath.Join(dir, "processed", "a.jsonl.result.json"))
+	require.NoError(t, err)
+	report := WatchReport{}
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Equal(t, reports[0].SHA256, report.SHA256)
+	require.Len(t, report.Orders, 2)
+	matches, err := filepath.Glob(filepath.Join(dir, ".*.state.jsonl"))
+	require.NoError(t, err)
+	require.Empty(t, matches)
+
+	// A file with the same name is moved next to the first one.
+	write("a.jsonl", `{"name": "#5"}`)
+	reports, err = w.Poll()
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	require.NotEqual(t, filepath.Join(dir, "processed", "a.jsonl"), reports[0].Path)
+	require.FileExists(t, reports[0].Path)
+
+	// Files modified recently are skipped.
+	w.MinAge = time.Hour
+	write("d.json", `{"name": "#6"}`)
+	reports, err = w.Poll()
+	require.NoError(t, err)
+	require.Empty(t, reports)
+	require.FileExists(t, filepath.Join(dir, "d.json"))
+}
+
+func TestFolderWatcherCheck(t *testing.T) {
+	dir := t.TempDir()
+	imported := 0
+	w, err := NewFolderWatcher(dir, func(o *goshopify.Order) (*MergeResult, error) {
+		imported++
+		return &MergeResult{Label: "created", OrderID: int64(imported)}, nil
+	})
+	require.NoError(t, err)
+	var checked []string
+	w.Check = func(orders []goshopify.Order) error {
+		for _, o := range orders {
+			checked = append(checked, o.Name)
+			if o.Name == "#3" {
+				return fmt.Errorf("insufficient inventory")
+			}
+		}
+		return nil
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.jsonl"), []byte(`{"name": "#1"}`+"\n"+`{"name": "#2"}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.jsonl"), []byte(`{"name": "#3"}`+"\n"+`{"name": "#4"}`), 0o644))
+
+	reports, err := w.Poll()
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	require.Equal(t, []string{"#1", "#2", "#3"}, checked)
+	require.Equal(t, 2, imported)
+	require.Equal(t, "processed", reports[0].Status)
+	require.Equal(t, "failed", reports[1].Status)
+	require.Equal(t, "insufficient inventory", reports[1].Error)
+	require.Empty(t, reports[1].Orders)
+	require.FileExists(t, filepath.Join(dir, "failed", "b.jsonl"))
+}
+
+func TestFolderWatcherResume(t *testing.T) {
+	dir := t.TempDir()
+	var imported []string
+	w, err := NewFolderWatcher(dir, func(o *goshopify.Order) (*MergeResult, error) {
+		imported = append(imported, o.Name)
+		return &MergeResult{Label: "updated", OrderID: 9}, nil
+	})
+	require.NoError(t, err)
+	content := []byte(`[{"name": "#1"}, {"name": "#2"}]`)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.json"), content, 0o644))
+	sum := fmt.Sprintf("%x", sha256.Sum256(content))
+	// State of an import of #1 interrupted by a restart, with an
+	// incomplete last line.
+	state := fmt.Sprintf(`{"sha256": %q, "result": {"index": 0, "name": "#1", "label": "created", "order_id": 7}}`+"\n"+`{"sha256": "`, sum)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".a.json.state.jsonl"), []byte(state), 0o600))
+
+	reports, err := w.Poll()
+	require.NoError(t, err)
+	require.Equal(t, []string{"#2"}, imported)
+	require.Equal(t, []WatchOrderResult{
+		{Index: 0, Name: "#1", Label: "resumed", OrderID: 7},
+		{Index: 1, Name: "#2", Label: "updated", OrderID: 9},
+	}, reports[0].Orders)
+	require.NoFileExists(t, filepath.Join(dir, ".a.json.state.jsonl"))
+}
+
+func TestFolderWatcherPollContextInterrupted(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var imported []string
+	w, err := NewFolderWatcher(dir, func(o *goshopify.Order) (*MergeResult, error) {
+		imported = append(imported, o.Name)
+		cancel()
+		return &MergeResult{Label: "created", OrderID: int64(len(imported))}, nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.jsonl"), []byte(`{"name": "#1"}`+"\n"+`{"name": "#2"}`), 0o644))
+
+	_, err = w.PollContext(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, []string{"#1"}, imported)
+	require.FileExists(t, filepath.Join(dir, "a.jsonl"))
+	require.FileExists(t, filepath.Join(dir, ".a.jsonl.state.jsonl"))
+
+	reports, err := w.Poll()
+	require.NoError(t, err)
+	require.Equal(t, []string{"#1", "#2"}, imported)
+	require.Equal(t, "resumed", reports[0].Orders[0].Label)
+	require.FileExists(t, filepath.Join(dir, "processed", "a.jsonl"))
+}
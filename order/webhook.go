@@ -0,0 +1,151 @@
+package order
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	goshopify "github.com/bold-commerce/go-shopify/v3"
+)
+
+// maxWebhookSize is the maximum accepted webhook payload size.
+const maxWebhookSize = 10 << 20
+
+// Shopify webhook request headers.
+const (
+	WebhookHMACHeader  = "X-Shopify-Hmac-Sha256"
+	WebhookTopicHeader = "X-Shopify-Topic"
+	WebhookShopHeader  = "X-Shopify-Shop-Domain"
+	WebhookIDHeader    = "X-Shopify-Webhook-Id"
+)
+
+// WebhookEvent is a verified webhook delivery. The payload of order,
+// customer and inventory topics is decoded into the corresponding field,
+// the payload of other topics is kept as is.
+type WebhookEvent struct {
+	ID             string                   `json:"id,omitempty"`
+	Topic          string                   `json:"topic"`
+	Shop           string                   `json:"shop,omitempty"`
+	ReceivedAt     time.Time                `json:"received_at"`
+	Order          *goshopify.Order         `json:"order,omitempty"`
+	Customer       *goshopify.Customer      `json:"customer,omitempty"`
+	InventoryLevel *InventoryLevel          `json:"inventory_level,omitempty"`
+	InventoryItem  *goshopify.InventoryItem `json:"inventory_item,omitempty"`
+	Payload        json.RawMessage          `json:"payload,omitempty"`
+}
+
+// WebhookHandler receives Shopify webhooks, verifies their HMAC signature
+// and passes them on to Handle. Deliveries with an invalid signature are
+// rejected with 401, deliveries Handle fails for with 500 so that Shopify
+// retries them.
+type WebhookHandler struct {
+	Secret string
+	Handle func(*WebhookEvent) error
+	now    func() time.Time
+}
+
+// WebhookLog appends webhook events as JSON lines to a writer. It is safe
+// for concurrent use.
+type WebhookLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewWebhookHandler(secret string, handle func(*WebhookEvent) error) *WebhookHandler {
+	return &WebhookHandler{Secret: secret, Handle: handle, now: time.Now}
+}
+
+func NewWebhookLog(w io.Writer) *WebhookLog {
+	return &WebhookLog{enc: json.NewEncoder(w)}
+}
+
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookSize+1))
+	if err != nil {
+		http.Error(w, "cannot read body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxWebhookSize {
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if ok, err := (goshopify.App{ApiSecret: h.Secret}).VerifyWebhookRequestVerbose(r); !ok {
+		http.Error(w, fmt.Sprintf("invalid webhook signature: %v", err), http.StatusUnauthorized)
+		return
+	}
+	event, err := DecodeWebhook(r.Header.Get(WebhookTopicHeader), body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	event.ID = r.Header.Get(WebhookIDHeader)
+	event.Shop = r.Header.Get(WebhookShopHeader)
+	event.ReceivedAt = h.now().UTC()
+	if err := h.Handle(event); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// DecodeWebhook decodes the payload of a webhook for topic, e.g.
+// "orders/paid".
+func DecodeWebhook(topic string, payload []byte) (*WebhookEvent, error) {
+	if topic == "" {
+		return nil, fmt.Errorf("webhook topic is empty")
+	}
+	event := &WebhookEvent{Topic: topic}
+	var v interface{}
+	switch resource, _, _ := strings.Cut(topic, "/"); resource {
+	case "orders":
+		event.Order = &goshopify.Order{}
+		v = event.Order
+	case "customers":
+		event.Customer = &goshopify.Customer{}
+		v = event.Customer
+	case "inventory_levels":
+		event.InventoryLevel = &InventoryLevel{}
+		v = event.InventoryLevel
+	case "inventory_items":
+		event.InventoryItem = &goshopify.InventoryItem{}
+		v = event.InventoryItem
+	default:
+		if !json.Valid(payload) {
+			return nil, fmt.Errorf("%s webhook: invalid JSON payload", topic)
+		}
+		event.Payload = payload
+		return event, nil
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return nil, fmt.Errorf("%s webhook: %w", topic, err)
+	}
+	return event, nil
+}
+
+// SignWebhook returns the HMAC signature Shopify sends for payload in the
+// X-Shopify-Hmac-Sha256 header.
+func SignWebhook(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Append writes event as a single JSON line.
+func (l *WebhookLog) Append(event *WebhookEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(event)
+}
@@ -0,0 +1,79 @@
+package order
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func webhookRequest(topic, payload, signature string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+	r.Header.Set(WebhookTopicHeader, topic)
+	r.Header.Set(WebhookShopHeader, "eql-dev.myshopify.com")
+	r.Header.Set(WebhookIDHeader, "b54557e4")
+	r.Header.Set(WebhookHMACHeader, signature)
+	return r
+}
+
+func TestWebhookHandler(t *testing.T) {
+	var events []*WebhookEvent
+	var handleErr error
+	h := NewWebhookHandler("secret", func(e *WebhookEvent) error {
+		events = append(events, e)
+		return handleErr
+	})
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	h.now = func() time.Time { return now }
+
+	payload := `{"id": 1001, "name": "#1001", "financial_status": "paid"}`
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, webhookRequest("orders/paid", payload, SignWebhook("secret", []byte(payload))))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, events, 1)
+	e := events[0]
+	require.Equal(t, "orders/paid", e.Topic)
+	require.Equal(t, "eql-dev.myshopify.com", e.Shop)
+	require.Equal(t, "b54557e4", e.ID)
+	require.Equal(t, now, e.ReceivedAt)
+	require.Equal(t, "#1001", e.Order.Name)
+	require.Equal(t, "paid", e.Order.FinancialStatus)
+	require.Nil(t, e.Payload)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, webhookRequest("orders/paid", payload, SignWebhook("other secret", []byte(payload))))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, webhookRequest("orders/paid", payload, ""))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	require.Len(t, events, 1)
+
+	handleErr = errors.New("disk full")
+	payload = `{"inventory_item_id": 3, "location_id": 1, "available": 7}`
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, webhookRequest("inventory_levels/update", payload, SignWebhook("secret", []byte(payload))))
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Equal(t, &InventoryLevel{InventoryItemID: 3, LocationID: 1, Available: 7}, events[1].InventoryLevel)
+}
+
+func TestDecodeWebhook(t *testing.T) {
+	e, err := DecodeWebhook("customers/update", []byte(`{"id": 5, "email": "mary@example.com"}`))
+	require.NoError(t, err)
+	require.Equal(t, "mary@example.com", e.Customer.Email)
+
+	e, err = DecodeWebhook("app/uninstalled", []byte(`{"id": 1}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id": 1}`, string(e.Payload))
+
+	_, err = DecodeWebhook("orders/create", []byte(`{"id": "x"}`))
+	require.ErrorContains(t, err, "orders/create webhook")
+	_, err = DecodeWebhook("", []byte(`{}`))
+	require.Error(t, err)
+}